@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,7 +18,35 @@ type Config struct {
 	DBName     string
 	
 	// Security
-	JWTSecret string
+	JWTAlgorithm     string        // "EdDSA" or "RS256"; keys are generated and stored in the database
+	JWTKeyRotation   time.Duration // How long one key signs tokens before the next one takes over
+	JWTIssuer        string        // "iss" claim other services check when verifying tokens
+	AccessTokenTTL   time.Duration // Lifetime of the JWT sent with every request
+	RefreshTokenTTL  time.Duration // Lifetime of a session's refresh token, renewed on every refresh
+	ImpersonationTTL time.Duration // Lifetime of a read-only "view as student" token
+
+	// Login throttling - failed logins are counted per account and per client IP
+	LoginMaxFailures   int64         // Failures on one account before it is locked
+	LoginIPMaxFailures int64         // Failures from one IP before it is locked; higher because schools share NAT
+	LoginLockout       time.Duration // How long a lockout lasts
+	LoginBackoffBase   time.Duration // Delay after the first failure, doubled on every further failure
+	LoginFailureWindow time.Duration // Failures older than this are forgotten
+	TrustProxyHeaders  bool          // Take the client IP from X-Forwarded-For when behind a reverse proxy
+	TrustedProxyHops   int64         // Reverse proxies in front of the server, each appending to X-Forwarded-For
+
+	// Two-factor authentication for staff
+	TOTPIssuer            string        // Name authenticator apps show next to the account
+	TwoFactorChallengeTTL time.Duration // Time between a correct password and entering the TOTP code
+
+	// Password recovery - one-time codes sent by email or WhatsApp
+	OTPTTL              time.Duration // How long a code can be used
+	OTPMaxAttempts      int64         // Wrong guesses before a code is discarded
+	OTPMaxRequests      int64         // Codes one account can request per OTPRequestWindow
+	OTPIPMaxRequests    int64         // Code requests one client IP can make per OTPRequestWindow, for any accounts
+	OTPRequestWindow    time.Duration
+	MessagingProvider   string // "log", "file" or "webhook"
+	MessagingOutbox     string // File the "file" provider appends messages to
+	MessagingWebhookURL string // Gateway the "webhook" provider posts messages to
 	
 	// Server
 	ServerPort string
@@ -24,6 +54,25 @@ type Config struct {
 	
 	// CORS
 	AllowedOrigins string // Comma-separated origins for CORS
+
+	// Reports
+	ReportCacheTTL time.Duration // How long report responses are cached in memory
+	AgingBuckets   string        // Comma-separated upper bounds (days past due) of aging buckets
+
+	// Uploads
+	UploadDir       string // Directory where expense attachments are stored
+	MaxUploadSizeMB int64
+
+	// Ledger - account codes used when payments and refunds are posted automatically
+	LedgerCashAccount    string
+	LedgerBankAccount    string
+	LedgerIncomeAccount  string
+	LedgerOpeningAccount string
+
+	// Schools - the school existing single-school data is assigned to
+	DefaultSchoolCode string
+	DefaultSchoolName string
+	StudentLoginURL   string // Printed on students' credential slips
 }
 
 var AppConfig *Config
@@ -44,7 +93,35 @@ func Load() {
 		DBName:     getEnv("DB_NAME", "komite_sekolah"),
 		
 		// Security
-		JWTSecret: getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		JWTAlgorithm:     getEnv("JWT_ALGORITHM", "EdDSA"),
+		JWTKeyRotation:   getEnvDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+		JWTIssuer:        getEnv("JWT_ISSUER", "komite-sekolah"),
+		AccessTokenTTL:   getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:  getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		ImpersonationTTL: getEnvDuration("IMPERSONATION_TTL", 15*time.Minute),
+
+		// Login throttling
+		LoginMaxFailures:   getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures: getEnvInt("LOGIN_IP_MAX_FAILURES", 50),
+		LoginLockout:       getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginBackoffBase:   getEnvDuration("LOGIN_BACKOFF_BASE", time.Second),
+		LoginFailureWindow: getEnvDuration("LOGIN_FAILURE_WINDOW", time.Hour),
+		TrustProxyHeaders:  getEnv("TRUST_PROXY_HEADERS", "false") == "true",
+		TrustedProxyHops:   getEnvInt("TRUSTED_PROXY_HOPS", 1),
+
+		// Two-factor authentication
+		TOTPIssuer:            getEnv("TOTP_ISSUER", "Komite Sekolah"),
+		TwoFactorChallengeTTL: getEnvDuration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute),
+
+		// Password recovery
+		OTPTTL:              getEnvDuration("OTP_TTL", 10*time.Minute),
+		OTPMaxAttempts:      getEnvInt("OTP_MAX_ATTEMPTS", 5),
+		OTPMaxRequests:      getEnvInt("OTP_MAX_REQUESTS", 3),
+		OTPIPMaxRequests:    getEnvInt("OTP_IP_MAX_REQUESTS", 30),
+		OTPRequestWindow:    getEnvDuration("OTP_REQUEST_WINDOW", time.Hour),
+		MessagingProvider:   getEnv("MESSAGING_PROVIDER", "log"),
+		MessagingOutbox:     getEnv("MESSAGING_OUTBOX", "outbox.jsonl"),
+		MessagingWebhookURL: getEnv("MESSAGING_WEBHOOK_URL", ""),
 		
 		// Server
 		ServerPort:  getEnv("SERVER_PORT", "8080"),
@@ -54,6 +131,25 @@ func Load() {
 		// Default: http://localhost:3000 (frontend dev server)
 		// In production, you MUST specify exact origins (e.g., "https://yourdomain.com,https://www.yourdomain.com")
 		AllowedOrigins: getEnv("ALLOWED_ORIGINS", "http://localhost:3000"),
+
+		// Reports - set to 0 to disable caching
+		ReportCacheTTL: getEnvDuration("REPORT_CACHE_TTL", 60*time.Second),
+		AgingBuckets:   getEnv("AGING_BUCKETS", "30,60,90"),
+
+		// Uploads
+		UploadDir:       getEnv("UPLOAD_DIR", "uploads"),
+		MaxUploadSizeMB: getEnvInt("MAX_UPLOAD_SIZE_MB", 10),
+
+		// Ledger
+		LedgerCashAccount:    getEnv("LEDGER_CASH_ACCOUNT", "1101"),
+		LedgerBankAccount:    getEnv("LEDGER_BANK_ACCOUNT", "1102"),
+		LedgerIncomeAccount:  getEnv("LEDGER_INCOME_ACCOUNT", "4101"),
+		LedgerOpeningAccount: getEnv("LEDGER_OPENING_ACCOUNT", "3101"),
+
+		// Schools
+		DefaultSchoolCode: getEnv("DEFAULT_SCHOOL_CODE", "SEKOLAH"),
+		DefaultSchoolName: getEnv("DEFAULT_SCHOOL_NAME", "Sekolah"),
+		StudentLoginURL:   getEnv("STUDENT_LOGIN_URL", "http://localhost:3000/login"),
 	}
 }
 
@@ -64,3 +160,28 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s=%q, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvInt(key string, defaultValue int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("Invalid number for %s=%q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"komite-sekolah/models"
+)
+
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// hashAPIKey returns the hex SHA-256 of an API key; like refresh tokens, keys are random
+// enough that a fast hash is safe and only the hash is stored
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey stores a new API key of the school. Only its hash and prefix are kept.
+func CreateAPIKey(k *models.APIKey, key string) error {
+	permissions, err := json.Marshal(k.Permissions)
+	if err != nil {
+		return err
+	}
+	allowedIPs, err := json.Marshal(k.AllowedIPs)
+	if err != nil {
+		return err
+	}
+
+	k.CreatedAt = time.Now()
+	result, err := DB.Exec(`
+		INSERT INTO api_keys (school_id, name, prefix, key_hash, permissions, allowed_ips, expires_at, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, k.SchoolID, k.Name, k.Prefix, hashAPIKey(key), permissions, allowedIPs, k.ExpiresAt, k.CreatedBy, k.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	k.ID, _ = result.LastInsertId()
+	return nil
+}
+
+const apiKeyColumns = `id, school_id, name, prefix, permissions, allowed_ips, expires_at, last_used_at,
+	COALESCE(last_used_ip, ''), use_count, created_by, created_at, revoked_at`
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	k := &models.APIKey{}
+	var permissions, allowedIPs []byte
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&k.ID, &k.SchoolID, &k.Name, &k.Prefix, &permissions, &allowedIPs, &k.ExpiresAt, &lastUsedAt,
+		&k.LastUsedIP, &k.UseCount, &k.CreatedBy, &k.CreatedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(permissions, &k.Permissions); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(allowedIPs, &k.AllowedIPs); err != nil {
+		return nil, err
+	}
+	if k.AllowedIPs == nil {
+		k.AllowedIPs = []string{}
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	return k, nil
+}
+
+// GetAPIKeys returns the school's API keys, newest first, revoked and expired ones included
+func GetAPIKeys(schoolID int64) ([]models.APIKey, error) {
+	rows, err := DB.Query(`SELECT `+apiKeyColumns+` FROM api_keys WHERE school_id = ? ORDER BY id DESC`, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByID returns one of the school's API keys
+func GetAPIKeyByID(schoolID, id int64) (*models.APIKey, error) {
+	return scanAPIKey(DB.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ? AND school_id = ?`, id, schoolID))
+}
+
+// GetAPIKeyByKey looks up the API key a request presented. Callers still have to check
+// that it is neither revoked nor expired.
+func GetAPIKeyByKey(key string) (*models.APIKey, error) {
+	return scanAPIKey(DB.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`, hashAPIKey(key)))
+}
+
+// RecordAPIKeyUse notes when and from where an API key was last used
+func RecordAPIKeyUse(id int64, ip string) error {
+	_, err := DB.Exec(`
+		UPDATE api_keys SET last_used_at = ?, last_used_ip = ?, use_count = use_count + 1
+		WHERE id = ?
+	`, time.Now(), ip, id)
+	return err
+}
+
+// RevokeAPIKey stops a key from being accepted. It reports false when the key had already
+// been revoked.
+func RevokeAPIKey(schoolID, id int64) (bool, error) {
+	result, err := DB.Exec(`
+		UPDATE api_keys SET revoked_at = ?
+		WHERE id = ? AND school_id = ? AND revoked_at IS NULL
+	`, time.Now(), id, schoolID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+
+	"komite-sekolah/models"
+)
+
+// CreateAuditLog appends an entry to the audit trail. There is deliberately no function to
+// change or remove entries, and the table's triggers refuse UPDATE and DELETE.
+func CreateAuditLog(entry models.AuditLog) error {
+	_, err := DB.Exec(`
+		INSERT INTO audit_logs (school_id, actor_id, actor_role, action, entity_type, entity_id, before_value, after_value, ip, user_agent)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.SchoolID, entry.ActorID, entry.ActorRole, entry.Action, entry.EntityType, entry.EntityID,
+		nullJSON(entry.Before), nullJSON(entry.After), entry.IP, entry.UserAgent)
+	return err
+}
+
+// GetAuditLogs returns the audit trail of a school, newest first, with the number of
+// entries matching the filter. schoolID 0 returns the foundation-level entries, which belong
+// to no school, such as schools being created and foundation admins' own changes.
+func GetAuditLogs(schoolID int64, filter models.AuditFilter) ([]models.AuditLog, int64, error) {
+	where := []string{"a.school_id IS NULL"}
+	var args []interface{}
+	if schoolID != 0 {
+		where = []string{"a.school_id = ?"}
+		args = append(args, schoolID)
+	}
+	if filter.ActorID != 0 {
+		where = append(where, "a.actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		where = append(where, "a.action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.EntityType != "" {
+		where = append(where, "a.entity_type = ?")
+		args = append(args, filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		where = append(where, "a.entity_id = ?")
+		args = append(args, filter.EntityID)
+	}
+	if filter.From != "" {
+		where = append(where, "a.created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		where = append(where, "a.created_at < DATE_ADD(?, INTERVAL 1 DAY)")
+		args = append(args, filter.To)
+	}
+	whereSQL := " WHERE " + strings.Join(where, " AND ")
+
+	var total int64
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM audit_logs a`+whereSQL, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT a.id, a.school_id, a.actor_id, COALESCE(u.name, ''), a.actor_role, a.action, a.entity_type, a.entity_id,
+			a.before_value, a.after_value, a.ip, a.user_agent, a.created_at
+		FROM audit_logs a
+		LEFT JOIN users u ON u.id = a.actor_id` + whereSQL + `
+		ORDER BY a.id DESC`
+	if filter.PageSize > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditLog
+	for rows.Next() {
+		var e models.AuditLog
+		var entrySchoolID, entityID sql.NullInt64
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &entrySchoolID, &e.ActorID, &e.ActorName, &e.ActorRole, &e.Action, &e.EntityType, &entityID,
+			&before, &after, &e.IP, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		e.SchoolID = nullInt64Ptr(entrySchoolID)
+		e.EntityID = nullInt64Ptr(entityID)
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+func nullJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"komite-sekolah/models"
+)
+
+var (
+	ErrFeeCategoryNotFound = errors.New("Fee category not found")
+	ErrBillNotFound        = errors.New("Bill not found")
+)
+
+// CreateFeeCategory creates a new fee category in a school
+func CreateFeeCategory(schoolID int64, req models.CreateFeeCategoryRequest) (*models.FeeCategory, error) {
+	result, err := DB.Exec(`
+		INSERT INTO fee_categories (code, name, default_nominal, school_id)
+		VALUES (?, ?, ?, ?)
+	`, req.Code, req.Name, req.DefaultNominal, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetFeeCategoryByID(schoolID, id)
+}
+
+// GetFeeCategoryByID retrieves a fee category of a school by ID
+func GetFeeCategoryByID(schoolID, id int64) (*models.FeeCategory, error) {
+	category := &models.FeeCategory{}
+	err := DB.QueryRow(`
+		SELECT id, code, name, default_nominal, created_at, updated_at
+		FROM fee_categories WHERE id = ? AND school_id = ?
+	`, id, schoolID).Scan(
+		&category.ID, &category.Code, &category.Name, &category.DefaultNominal,
+		&category.CreatedAt, &category.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrFeeCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// GetAllFeeCategories retrieves all fee categories of a school
+func GetAllFeeCategories(schoolID int64) ([]models.FeeCategory, error) {
+	rows, err := DB.Query(`
+		SELECT id, code, name, default_nominal, created_at, updated_at
+		FROM fee_categories
+		WHERE school_id = ?
+		ORDER BY name
+	`, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.FeeCategory
+	for rows.Next() {
+		var category models.FeeCategory
+		err := rows.Scan(
+			&category.ID, &category.Code, &category.Name, &category.DefaultNominal,
+			&category.CreatedAt, &category.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+// GenerateBills creates one bill per active student of a school for the given category and
+// period. Students that already have a bill for the same category and period are skipped.
+func GenerateBills(schoolID int64, req models.GenerateBillsRequest) (*models.GenerateBillsResponse, error) {
+	query := `
+		INSERT IGNORE INTO bills (user_id, fee_category_id, periode, nominal, jatuh_tempo, keterangan, school_id)
+		SELECT u.id, ?, ?, ?, ?, ?, u.school_id
+		FROM users u
+		WHERE u.role = 'student' AND u.status = 'active' AND u.school_id = ?`
+	args := []interface{}{req.FeeCategoryID, req.Periode, req.Nominal, req.JatuhTempo, req.Keterangan, schoolID}
+	countQuery := `SELECT COUNT(*) FROM users u WHERE u.role = 'student' AND u.status = 'active' AND u.school_id = ?`
+	countArgs := []interface{}{schoolID}
+
+	if req.ClassID != nil {
+		query += " AND u.class_id = ?"
+		args = append(args, *req.ClassID)
+		countQuery += " AND u.class_id = ?"
+		countArgs = append(countArgs, *req.ClassID)
+	}
+
+	var eligible int64
+	if err := DB.QueryRow(countQuery, countArgs...).Scan(&eligible); err != nil {
+		return nil, err
+	}
+
+	result, err := DB.Exec(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	created, _ := result.RowsAffected()
+	return &models.GenerateBillsResponse{
+		Created: created,
+		Skipped: eligible - created,
+	}, nil
+}
+
+// GetBillByID retrieves a bill of a school by ID, including the amount already paid against it
+func GetBillByID(schoolID, id int64) (*models.Bill, error) {
+	bill := &models.Bill{}
+	err := DB.QueryRow(`
+		SELECT b.id, b.user_id, b.fee_category_id, fc.name, b.periode, b.nominal,
+			   DATE_FORMAT(b.jatuh_tempo, '%Y-%m-%d'), COALESCE(b.keterangan, ''),
+			   COALESCE((SELECT SUM(p.nominal) FROM payments p WHERE p.bill_id = b.id), 0),
+			   b.created_at, b.updated_at
+		FROM bills b
+		JOIN fee_categories fc ON fc.id = b.fee_category_id
+		WHERE b.id = ? AND b.school_id = ?
+	`, id, schoolID).Scan(
+		&bill.ID, &bill.UserID, &bill.FeeCategoryID, &bill.FeeCategoryName, &bill.Periode, &bill.Nominal,
+		&bill.JatuhTempo, &bill.Keterangan, &bill.Terbayar,
+		&bill.CreatedAt, &bill.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrBillNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bill, nil
+}
+
+// GetBillsByUserID retrieves all bills for a student, oldest due date first
+func GetBillsByUserID(userID int64) ([]models.Bill, error) {
+	rows, err := DB.Query(`
+		SELECT b.id, b.user_id, b.fee_category_id, fc.name, b.periode, b.nominal,
+			   DATE_FORMAT(b.jatuh_tempo, '%Y-%m-%d'), COALESCE(b.keterangan, ''),
+			   COALESCE((SELECT SUM(p.nominal) FROM payments p WHERE p.bill_id = b.id), 0),
+			   b.created_at, b.updated_at
+		FROM bills b
+		JOIN fee_categories fc ON fc.id = b.fee_category_id
+		WHERE b.user_id = ?
+		ORDER BY b.jatuh_tempo, b.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bills []models.Bill
+	for rows.Next() {
+		var bill models.Bill
+		err := rows.Scan(
+			&bill.ID, &bill.UserID, &bill.FeeCategoryID, &bill.FeeCategoryName, &bill.Periode, &bill.Nominal,
+			&bill.JatuhTempo, &bill.Keterangan, &bill.Terbayar,
+			&bill.CreatedAt, &bill.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		bills = append(bills, bill)
+	}
+	return bills, nil
+}
+
+// GetTotalBilledByUserID sums every bill issued to a student
+func GetTotalBilledByUserID(userID int64) (int64, error) {
+	var total int64
+	err := DB.QueryRow(`SELECT COALESCE(SUM(nominal), 0) FROM bills WHERE user_id = ?`, userID).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,270 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"komite-sekolah/models"
+)
+
+var (
+	ErrClassNotFound        = errors.New("Class not found")
+	ErrAcademicYearNotFound = errors.New("Academic year not found")
+	ErrClassExists          = errors.New("Class already exists in this academic year")
+	ErrAcademicYearExists   = errors.New("Academic year already exists")
+)
+
+// CreateAcademicYear creates an academic year for a school. Marking it active deactivates
+// every other year of the school.
+func CreateAcademicYear(schoolID int64, req models.CreateAcademicYearRequest) (*models.AcademicYear, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if req.IsActive {
+		if _, err := tx.Exec(`UPDATE academic_years SET is_active = 0 WHERE school_id = ?`, schoolID); err != nil {
+			return nil, err
+		}
+	}
+	result, err := tx.Exec(`
+		INSERT INTO academic_years (name, start_date, end_date, is_active, school_id)
+		VALUES (?, ?, ?, ?, ?)
+	`, req.Name, req.StartDate, req.EndDate, req.IsActive, schoolID)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, ErrAcademicYearExists
+		}
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetAcademicYearByID(schoolID, id)
+}
+
+// ActivateAcademicYear makes one academic year the active one of its school
+func ActivateAcademicYear(schoolID, id int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE academic_years SET is_active = (id = ?) WHERE school_id = ?`, id, schoolID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const academicYearColumns = `id, name, DATE_FORMAT(start_date, '%Y-%m-%d'), DATE_FORMAT(end_date, '%Y-%m-%d'), is_active, created_at`
+
+func scanAcademicYear(row rowScanner) (*models.AcademicYear, error) {
+	year := &models.AcademicYear{}
+	err := row.Scan(&year.ID, &year.Name, &year.StartDate, &year.EndDate, &year.IsActive, &year.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrAcademicYearNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return year, nil
+}
+
+// GetAcademicYearByID retrieves an academic year of a school by ID
+func GetAcademicYearByID(schoolID, id int64) (*models.AcademicYear, error) {
+	return scanAcademicYear(DB.QueryRow(`SELECT `+academicYearColumns+` FROM academic_years WHERE id = ? AND school_id = ?`, id, schoolID))
+}
+
+// GetActiveAcademicYear retrieves the active academic year of a school
+func GetActiveAcademicYear(schoolID int64) (*models.AcademicYear, error) {
+	return scanAcademicYear(DB.QueryRow(`SELECT `+academicYearColumns+` FROM academic_years WHERE is_active = 1 AND school_id = ? LIMIT 1`, schoolID))
+}
+
+// GetAllAcademicYears retrieves all academic years of a school, newest first
+func GetAllAcademicYears(schoolID int64) ([]models.AcademicYear, error) {
+	rows, err := DB.Query(`SELECT `+academicYearColumns+` FROM academic_years WHERE school_id = ? ORDER BY start_date DESC`, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var years []models.AcademicYear
+	for rows.Next() {
+		year, err := scanAcademicYear(rows)
+		if err != nil {
+			return nil, err
+		}
+		years = append(years, *year)
+	}
+	return years, nil
+}
+
+// CreateClass creates a new class (rombel) in a school
+func CreateClass(schoolID int64, req models.CreateClassRequest) (*models.Class, error) {
+	result, err := DB.Exec(`
+		INSERT INTO classes (name, grade_level, academic_year_id, homeroom_teacher, school_id)
+		VALUES (?, ?, ?, ?, ?)
+	`, req.Name, req.GradeLevel, req.AcademicYearID, req.HomeroomTeacher, schoolID)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, ErrClassExists
+		}
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetClassByID(schoolID, id)
+}
+
+const classColumns = `c.id, c.name, c.grade_level, c.academic_year_id, COALESCE(ay.name, ''), COALESCE(c.homeroom_teacher, ''),
+	c.created_at, c.updated_at`
+
+const classFrom = `FROM classes c LEFT JOIN academic_years ay ON ay.id = c.academic_year_id`
+
+func scanClass(row rowScanner) (*models.Class, error) {
+	class := &models.Class{}
+	var academicYearID sql.NullInt64
+	err := row.Scan(
+		&class.ID, &class.Name, &class.GradeLevel, &academicYearID, &class.AcademicYearName, &class.HomeroomTeacher,
+		&class.CreatedAt, &class.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	class.AcademicYearID = nullInt64Ptr(academicYearID)
+	return class, nil
+}
+
+// GetClassByID retrieves a class of a school by ID
+func GetClassByID(schoolID, id int64) (*models.Class, error) {
+	class, err := scanClass(DB.QueryRow(`SELECT `+classColumns+` `+classFrom+` WHERE c.id = ? AND c.school_id = ?`, id, schoolID))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrClassNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return class, nil
+}
+
+// GetAllClasses retrieves the classes of a school ordered by grade level and name. When
+// academicYearID is non-zero only classes of that academic year are returned.
+func GetAllClasses(schoolID, academicYearID int64) ([]models.Class, error) {
+	query := `SELECT ` + classColumns + ` ` + classFrom + ` WHERE c.school_id = ?`
+	args := []interface{}{schoolID}
+	if academicYearID != 0 {
+		query += ` AND c.academic_year_id = ?`
+		args = append(args, academicYearID)
+	}
+	query += ` ORDER BY c.grade_level, c.name`
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var classes []models.Class
+	for rows.Next() {
+		class, err := scanClass(rows)
+		if err != nil {
+			return nil, err
+		}
+		classes = append(classes, *class)
+	}
+	return classes, nil
+}
+
+// enrollInTx places a student in a class from startDate. The current enrollment, if any,
+// is closed the day before so the history shows every class the student was in.
+// users.class_id always points at the current class.
+func enrollInTx(tx *sql.Tx, userID, classID int64, startDate string) error {
+	_, err := tx.Exec(`
+		UPDATE enrollments
+		SET end_date = GREATEST(start_date, DATE_SUB(?, INTERVAL 1 DAY))
+		WHERE user_id = ? AND end_date IS NULL
+	`, startDate, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO enrollments (user_id, class_id, start_date)
+		VALUES (?, ?, ?)
+	`, userID, classID, startDate)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET class_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, classID, userID)
+	return err
+}
+
+// EnrollStudent enrolls a student in a class, or moves them to a new class
+func EnrollStudent(req models.EnrollStudentRequest) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := enrollInTx(tx, req.UserID, req.ClassID, req.StartDate); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetEnrollmentsByUserID returns a student's enrollment history, most recent first
+func GetEnrollmentsByUserID(userID int64) ([]models.Enrollment, error) {
+	rows, err := DB.Query(`
+		SELECT e.id, e.user_id, e.class_id, c.name, COALESCE(ay.name, ''),
+			   DATE_FORMAT(e.start_date, '%Y-%m-%d'), DATE_FORMAT(e.end_date, '%Y-%m-%d'), e.created_at
+		FROM enrollments e
+		JOIN classes c ON c.id = e.class_id
+		LEFT JOIN academic_years ay ON ay.id = c.academic_year_id
+		WHERE e.user_id = ?
+		ORDER BY e.start_date DESC, e.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var enrollments []models.Enrollment
+	for rows.Next() {
+		var e models.Enrollment
+		var endDate sql.NullString
+		err := rows.Scan(&e.ID, &e.UserID, &e.ClassID, &e.ClassName, &e.AcademicYearName, &e.StartDate, &endDate, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if endDate.Valid {
+			e.EndDate = &endDate.String
+		}
+		enrollments = append(enrollments, e)
+	}
+	return enrollments, nil
+}
+
+// backfillEnrollments opens an enrollment for students that were given a class before
+// enrollment history was recorded
+func backfillEnrollments() error {
+	_, err := DB.Exec(`
+		INSERT INTO enrollments (user_id, class_id, start_date)
+		SELECT u.id, u.class_id, DATE(u.created_at)
+		FROM users u
+		WHERE u.class_id IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM enrollments e WHERE e.user_id = u.id)
+	`)
+	return err
+}
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
@@ -2,12 +2,15 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	"komite-sekolah/config"
+	"komite-sekolah/models"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -32,21 +35,30 @@ func Init() {
 	}
 
 	createTables()
+	migrateSchema()
 	seedAdmin()
+	seedAccounts()
+	backfillLedger()
 	log.Println("Database initialized successfully")
 }
 
 func createTables() {
 	// Create tables first
 	tableQueries := []string{
+		`CREATE TABLE IF NOT EXISTS schools (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			code VARCHAR(20) NOT NULL UNIQUE,
+			name VARCHAR(255) NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
 		`CREATE TABLE IF NOT EXISTS users (
 			id INT AUTO_INCREMENT PRIMARY KEY,
 			username VARCHAR(255) UNIQUE,
-			nis VARCHAR(255) UNIQUE,
-			virtual_account VARCHAR(255) UNIQUE,
+			nis VARCHAR(255),
+			virtual_account VARCHAR(255),
 			name VARCHAR(255) NOT NULL,
 			password VARCHAR(255) NOT NULL,
-			role ENUM('admin', 'student') NOT NULL,
+			role VARCHAR(20) NOT NULL,
 			must_change_password TINYINT(1) DEFAULT 1,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
@@ -65,6 +77,311 @@ func createTables() {
 			INDEX idx_payments_user_id (user_id),
 			INDEX idx_payments_tanggal (tanggal)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS academic_years (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(9) NOT NULL,
+			start_date DATE NOT NULL,
+			end_date DATE NOT NULL,
+			is_active TINYINT(1) NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS classes (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			grade_level INT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS enrollments (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			user_id INT NOT NULL,
+			class_id INT NOT NULL,
+			start_date DATE NOT NULL,
+			end_date DATE NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (class_id) REFERENCES classes(id),
+			INDEX idx_enrollments_user (user_id, end_date)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS parent_students (
+			parent_id INT NOT NULL,
+			student_id INT NOT NULL,
+			relationship VARCHAR(50) NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (parent_id, student_id),
+			FOREIGN KEY (parent_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (student_id) REFERENCES users(id) ON DELETE CASCADE,
+			INDEX idx_parent_students_student (student_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS fee_categories (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			code VARCHAR(50) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			default_nominal BIGINT NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS bills (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			user_id INT NOT NULL,
+			fee_category_id INT NOT NULL,
+			periode VARCHAR(20) NOT NULL,
+			nominal BIGINT NOT NULL,
+			jatuh_tempo DATE NOT NULL,
+			keterangan TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (fee_category_id) REFERENCES fee_categories(id),
+			UNIQUE KEY uq_bills_user_category_periode (user_id, fee_category_id, periode),
+			INDEX idx_bills_jatuh_tempo (jatuh_tempo)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS accounts (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			code VARCHAR(20) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			type ENUM('asset', 'liability', 'equity', 'income', 'expense') NOT NULL,
+			is_cash TINYINT(1) NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS journal_entries (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			tanggal DATE NOT NULL,
+			keterangan TEXT,
+			source_type VARCHAR(30) NOT NULL,
+			source_id INT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_journal_entries_tanggal (tanggal),
+			INDEX idx_journal_entries_source (source_type, source_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS journal_lines (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			entry_id INT NOT NULL,
+			account_id INT NOT NULL,
+			debit BIGINT NOT NULL DEFAULT 0,
+			credit BIGINT NOT NULL DEFAULT 0,
+			memo VARCHAR(255),
+			FOREIGN KEY (entry_id) REFERENCES journal_entries(id),
+			FOREIGN KEY (account_id) REFERENCES accounts(id),
+			INDEX idx_journal_lines_account (account_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS refunds (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			user_id INT NOT NULL,
+			tanggal DATE NOT NULL,
+			nominal BIGINT NOT NULL,
+			metode VARCHAR(20) NOT NULL,
+			keterangan TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			INDEX idx_refunds_tanggal (tanggal)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS budget_lines (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			tahun_ajaran VARCHAR(9) NOT NULL,
+			code VARCHAR(50) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			pagu BIGINT NOT NULL,
+			account_id INT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS expense_requests (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			budget_line_id INT NOT NULL,
+			judul VARCHAR(255) NOT NULL,
+			keterangan TEXT,
+			nominal BIGINT NOT NULL,
+			status ENUM('pending_treasurer', 'pending_chair', 'pending_principal', 'approved', 'rejected', 'disbursed') NOT NULL DEFAULT 'pending_treasurer',
+			requested_by INT NOT NULL,
+			override_by INT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			FOREIGN KEY (budget_line_id) REFERENCES budget_lines(id),
+			FOREIGN KEY (requested_by) REFERENCES users(id),
+			INDEX idx_expense_requests_status (status)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS expense_approvals (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			expense_request_id INT NOT NULL,
+			step VARCHAR(20) NOT NULL,
+			decision VARCHAR(20) NOT NULL,
+			approver_id INT NOT NULL,
+			note TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (expense_request_id) REFERENCES expense_requests(id),
+			FOREIGN KEY (approver_id) REFERENCES users(id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS expense_attachments (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			expense_request_id INT NOT NULL,
+			file_name VARCHAR(255) NOT NULL,
+			content_type VARCHAR(100) NOT NULL,
+			size BIGINT NOT NULL,
+			storage_path VARCHAR(500) NOT NULL,
+			uploaded_by INT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (expense_request_id) REFERENCES expense_requests(id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS expense_disbursements (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			expense_request_id INT NOT NULL UNIQUE,
+			tanggal DATE NOT NULL,
+			nominal BIGINT NOT NULL,
+			metode VARCHAR(20) NOT NULL,
+			journal_entry_id INT NOT NULL,
+			disbursed_by INT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (expense_request_id) REFERENCES expense_requests(id),
+			FOREIGN KEY (journal_entry_id) REFERENCES journal_entries(id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS transparency_reports (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			periode VARCHAR(50) NOT NULL,
+			dari_tanggal DATE NOT NULL,
+			sampai_tanggal DATE NOT NULL,
+			snapshot LONGTEXT NOT NULL,
+			published_by INT NOT NULL,
+			published_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (published_by) REFERENCES users(id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			user_id INT NOT NULL,
+			refresh_token_hash CHAR(64) NOT NULL UNIQUE,
+			previous_token_hash CHAR(64) NULL,
+			expires_at DATETIME NOT NULL,
+			revoked_at DATETIME NULL,
+			last_used_at DATETIME NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			INDEX idx_sessions_user (user_id),
+			INDEX idx_sessions_previous_token (previous_token_hash)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS login_attempts (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			scope VARCHAR(20) NOT NULL,
+			subject VARCHAR(191) NOT NULL,
+			school_id INT NOT NULL DEFAULT 0,
+			failures INT NOT NULL DEFAULT 0,
+			locked TINYINT(1) NOT NULL DEFAULT 0,
+			last_failure_at DATETIME NULL,
+			blocked_until DATETIME NULL,
+			UNIQUE KEY uq_login_attempts_subject (scope, school_id, subject),
+			INDEX idx_login_attempts_blocked (blocked_until)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS password_reset_codes (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			user_id INT NOT NULL,
+			code_hash CHAR(64) NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			expires_at DATETIME NOT NULL,
+			used_at DATETIME NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			INDEX idx_password_reset_codes_user (user_id, created_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS password_reset_requests (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			ip VARCHAR(45) NOT NULL,
+			created_at DATETIME NOT NULL,
+			INDEX idx_password_reset_requests_ip (ip, created_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			user_id INT NOT NULL,
+			code_hash CHAR(64) NOT NULL,
+			used_at DATETIME NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			INDEX idx_totp_recovery_codes_user (user_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS signing_keys (
+			kid VARCHAR(64) PRIMARY KEY,
+			algorithm VARCHAR(10) NOT NULL,
+			private_key BLOB NOT NULL,
+			public_key BLOB NOT NULL,
+			not_before DATETIME NOT NULL,
+			not_after DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_signing_keys_expires (expires_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS audit_logs (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			school_id INT NULL,
+			actor_id INT NOT NULL,
+			actor_role VARCHAR(20) NOT NULL,
+			action VARCHAR(30) NOT NULL,
+			entity_type VARCHAR(30) NOT NULL,
+			entity_id INT NULL,
+			before_value JSON NULL,
+			after_value JSON NULL,
+			ip VARCHAR(45) NOT NULL,
+			user_agent VARCHAR(255) NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_audit_logs_school_created (school_id, created_at),
+			INDEX idx_audit_logs_entity (entity_type, entity_id),
+			INDEX idx_audit_logs_actor (actor_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS payment_events (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			school_id INT NOT NULL,
+			payment_id INT NOT NULL,
+			event VARCHAR(10) NOT NULL,
+			user_id INT NOT NULL,
+			tanggal DATE NOT NULL,
+			nominal BIGINT NOT NULL,
+			keterangan TEXT NOT NULL,
+			bill_id INT NULL,
+			metode VARCHAR(20) NOT NULL,
+			recorded_at DATETIME NOT NULL,
+			prev_hash CHAR(64) NOT NULL,
+			hash CHAR(64) NOT NULL,
+			UNIQUE KEY uq_payment_events_school_prev (school_id, prev_hash),
+			INDEX idx_payment_events_school_recorded (school_id, recorded_at),
+			INDEX idx_payment_events_payment (payment_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS impersonations (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			school_id INT NOT NULL,
+			staff_id INT NOT NULL,
+			student_id INT NOT NULL,
+			session_id INT NOT NULL,
+			started_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			ended_at DATETIME NULL,
+			end_reason VARCHAR(10) NULL,
+			UNIQUE KEY uq_impersonations_session (session_id),
+			INDEX idx_impersonations_open (ended_at, expires_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			school_id INT NOT NULL,
+			name VARCHAR(100) NOT NULL,
+			prefix VARCHAR(12) NOT NULL,
+			key_hash CHAR(64) NOT NULL,
+			permissions JSON NOT NULL,
+			allowed_ips JSON NOT NULL,
+			expires_at DATETIME NOT NULL,
+			last_used_at DATETIME NULL,
+			last_used_ip VARCHAR(45) NULL,
+			use_count INT NOT NULL DEFAULT 0,
+			created_by INT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			revoked_at DATETIME NULL,
+			UNIQUE KEY uq_api_keys_hash (key_hash),
+			INDEX idx_api_keys_school (school_id),
+			FOREIGN KEY (school_id) REFERENCES schools(id),
+			FOREIGN KEY (created_by) REFERENCES users(id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+		`CREATE TABLE IF NOT EXISTS two_factor_roles (
+			school_id INT NOT NULL,
+			role VARCHAR(20) NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (school_id, role),
+			FOREIGN KEY (school_id) REFERENCES schools(id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
 	}
 
 	for _, query := range tableQueries {
@@ -75,26 +392,208 @@ func createTables() {
 	}
 }
 
+// migrateSchema adds columns and indexes introduced after the initial schema,
+// so existing databases are upgraded in place instead of being recreated.
+func migrateSchema() {
+	columns := []struct{ table, column, definition string }{
+		{"users", "class_id", "INT NULL"},
+		{"users", "status", "ENUM('active', 'inactive', 'transferred', 'graduated') NOT NULL DEFAULT 'active'"},
+		{"payments", "bill_id", "INT NULL"},
+		{"payments", "metode", "VARCHAR(20) NOT NULL DEFAULT 'tunai'"},
+		{"classes", "academic_year_id", "INT NULL"},
+		{"classes", "homeroom_teacher", "VARCHAR(255) NULL"},
+		{"users", "phone", "VARCHAR(20) NULL"},
+		{"users", "email", "VARCHAR(255) NULL"},
+		{"users", "totp_secret", "VARCHAR(64) NULL"},
+		{"users", "totp_enabled", "TINYINT(1) NOT NULL DEFAULT 0"},
+		{"users", "totp_last_step", "BIGINT NOT NULL DEFAULT 0"},
+	}
+	// Every tenant-owned table records the school it belongs to
+	for _, table := range tenantTables {
+		columns = append(columns, struct{ table, column, definition string }{table, "school_id", "INT NULL"})
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(c.table, c.column, c.definition); err != nil {
+			log.Fatal("Failed to migrate tables:", err)
+		}
+	}
+
+	// Roles used to be an ENUM of admin and student; new roles should not need a schema change
+	if err := modifyColumnIfType("users", "role", "enum", "VARCHAR(20) NOT NULL"); err != nil {
+		log.Fatal("Failed to migrate tables:", err)
+	}
+
+	// The single admin role was split into finer staff roles; existing admins keep full access
+	if _, err := DB.Exec(`UPDATE users SET role = ? WHERE role = ?`, models.RoleSuperAdmin, models.RoleAdmin); err != nil {
+		log.Fatal("Failed to migrate roles:", err)
+	}
+
+	if err := backfillSchools(); err != nil {
+		log.Fatal("Failed to assign data to the default school:", err)
+	}
+
+	// Class names used to be unique across the whole school; they are now unique per academic year.
+	// Identifiers and codes that were unique in a single-school deployment are now unique per school.
+	globalUniques := []struct{ table, name string }{
+		{"classes", "name"},
+		{"users", "nis"},
+		{"users", "virtual_account"},
+		{"users", "phone"},
+		{"users", "email"},
+		{"academic_years", "name"},
+		{"fee_categories", "code"},
+		{"accounts", "code"},
+		{"budget_lines", "uq_budget_lines_year_code"},
+		{"transparency_reports", "periode"},
+	}
+	for _, idx := range globalUniques {
+		if err := dropIndexIfExists(idx.table, idx.name); err != nil {
+			log.Fatal("Failed to migrate indexes:", err)
+		}
+	}
+
+	indexes := []struct{ table, name, kind, columns string }{
+		{"users", "idx_users_class_id", "INDEX", "class_id"},
+		{"payments", "idx_payments_bill_id", "INDEX", "bill_id"},
+		{"classes", "uq_classes_year_name", "UNIQUE INDEX", "academic_year_id, name"},
+		{"users", "uq_users_school_nis", "UNIQUE INDEX", "school_id, nis"},
+		{"users", "uq_users_school_va", "UNIQUE INDEX", "school_id, virtual_account"},
+		{"users", "uq_users_school_phone", "UNIQUE INDEX", "school_id, phone"},
+		{"users", "uq_users_school_email", "UNIQUE INDEX", "school_id, email"},
+		{"academic_years", "uq_academic_years_school_name", "UNIQUE INDEX", "school_id, name"},
+		{"fee_categories", "uq_fee_categories_school_code", "UNIQUE INDEX", "school_id, code"},
+		{"accounts", "uq_accounts_school_code", "UNIQUE INDEX", "school_id, code"},
+		{"budget_lines", "uq_budget_lines_school_year_code", "UNIQUE INDEX", "school_id, tahun_ajaran, code"},
+		{"transparency_reports", "uq_transparency_reports_school_periode", "UNIQUE INDEX", "school_id, periode"},
+		{"payments", "idx_payments_school_tanggal", "INDEX", "school_id, tanggal"},
+		{"bills", "idx_bills_school_jatuh_tempo", "INDEX", "school_id, jatuh_tempo"},
+		{"classes", "idx_classes_school", "INDEX", "school_id"},
+		{"journal_entries", "idx_journal_entries_school_tanggal", "INDEX", "school_id, tanggal"},
+		{"refunds", "idx_refunds_school_tanggal", "INDEX", "school_id, tanggal"},
+		{"expense_requests", "idx_expense_requests_school", "INDEX", "school_id"},
+	}
+	for _, idx := range indexes {
+		if err := addIndexIfMissing(idx.table, idx.name, idx.kind, idx.columns); err != nil {
+			log.Fatal("Failed to migrate indexes:", err)
+		}
+	}
+
+	if err := backfillEnrollments(); err != nil {
+		log.Fatal("Failed to backfill enrollments:", err)
+	}
+
+	if err := backfillPaymentChain(); err != nil {
+		log.Fatal("Failed to start the payment hash chain:", err)
+	}
+
+	// The audit trail and the payment chain are append-only; the application never changes
+	// entries and these triggers stop anyone else from doing it through the application's
+	// database user
+	for _, table := range []string{"audit_logs", "payment_events"} {
+		for _, event := range []string{"UPDATE", "DELETE"} {
+			name := table + "_no_" + strings.ToLower(event)
+			if err := createTriggerIfMissing(name, fmt.Sprintf(
+				"BEFORE %s ON %s FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = '%s is append-only'", event, table, table,
+			)); err != nil {
+				// Creating triggers needs the TRIGGER privilege (and SUPER with binary logging on)
+				log.Printf("Could not protect %s with trigger %s: %v", table, name, err)
+			}
+		}
+	}
+}
+
+func addColumnIfMissing(table, column, definition string) error {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?
+	`, table, column).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// modifyColumnIfType redefines a column whose current data type is still oldType
+func modifyColumnIfType(table, column, oldType, definition string) error {
+	var dataType string
+	err := DB.QueryRow(`
+		SELECT DATA_TYPE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?
+	`, table, column).Scan(&dataType)
+	if err != nil || dataType != oldType {
+		return err
+	}
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func indexExists(table, name string) (bool, error) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?
+	`, table, name).Scan(&count)
+	return count > 0, err
+}
+
+func addIndexIfMissing(table, name, kind, columns string) error {
+	exists, err := indexExists(table, name)
+	if err != nil || exists {
+		return err
+	}
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD %s %s (%s)", table, kind, name, columns))
+	return err
+}
+
+func createTriggerIfMissing(name, definition string) error {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM information_schema.TRIGGERS
+		WHERE TRIGGER_SCHEMA = DATABASE() AND TRIGGER_NAME = ?
+	`, name).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+	_, err = DB.Exec(fmt.Sprintf("CREATE TRIGGER %s %s", name, definition))
+	return err
+}
+
+func dropIndexIfExists(table, name string) error {
+	exists, err := indexExists(table, name)
+	if err != nil || !exists {
+		return err
+	}
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", table, name))
+	return err
+}
+
 // For development, recreate the database if you need schema changes (delete `komite_sekolah.db` and restart).
 
 func seedAdmin() {
 	// Check if admin exists
 	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'admin'").Scan(&count)
+	err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE role = ?", models.RoleSuperAdmin).Scan(&count)
 	if err != nil {
 		log.Fatal("Failed to check admin:", err)
 	}
 
 	if count == 0 {
+		schoolID, err := defaultSchoolID()
+		if err != nil {
+			log.Fatal("Failed to find default school:", err)
+		}
+
 		// Create default admin (password: admin123)
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
 		if err != nil {
 			log.Fatal("Failed to hash admin password:", err)
 		}
 		_, err = DB.Exec(`
-			INSERT INTO users (username, virtual_account, name, password, role, must_change_password)
-			VALUES (?, ?, ?, ?, ?, ?)
-		`, "admin", "", "Administrator", string(hashedPassword), "admin", 0)
+			INSERT INTO users (username, virtual_account, name, password, role, must_change_password, school_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, "admin", "", "Administrator", string(hashedPassword), models.RoleSuperAdmin, 0, schoolID)
 		if err != nil {
 			log.Fatal("Failed to seed admin:", err)
 		}
@@ -108,3 +607,8 @@ func Close() {
 	}
 }
 
+// isDuplicateKey reports whether err is a MySQL unique constraint violation
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
@@ -0,0 +1,476 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"komite-sekolah/models"
+)
+
+var (
+	ErrBudgetLineNotFound = errors.New("Budget line not found")
+	ErrExpenseNotFound    = errors.New("Expense request not found")
+	ErrAttachmentNotFound = errors.New("Attachment not found")
+	ErrExpenseNotPending  = errors.New("Expense request is not awaiting approval")
+	ErrExpenseNotApproved = errors.New("Expense request is not approved")
+	ErrOverBudget         = errors.New("Expense exceeds the remaining budget")
+
+	ErrStepNotPermitted     = errors.New("Your role cannot decide this approval step")
+	ErrOverrideNotPermitted = errors.New("Your role cannot override the budget")
+	ErrOwnExpense           = errors.New("You cannot decide your own expense request")
+	ErrAlreadyDecided       = errors.New("You already decided another step of this expense request")
+)
+
+// approvalSteps maps a pending status to the approval step it waits for and the status that follows approval
+var approvalSteps = map[models.ExpenseStatus]struct {
+	step string
+	next models.ExpenseStatus
+}{
+	models.ExpensePendingTreasurer: {"treasurer", models.ExpensePendingChair},
+	models.ExpensePendingChair:     {"chair", models.ExpensePendingPrincipal},
+	models.ExpensePendingPrincipal: {"principal", models.ExpenseApproved},
+}
+
+// CreateBudgetLine creates a budget line of a school for an academic year
+func CreateBudgetLine(schoolID int64, req models.CreateBudgetLineRequest) (*models.BudgetLine, error) {
+	result, err := DB.Exec(`
+		INSERT INTO budget_lines (tahun_ajaran, code, name, pagu, account_id, school_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, req.TahunAjaran, req.Code, req.Name, req.Pagu, req.AccountID, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetBudgetLineByID(schoolID, id)
+}
+
+// GetBudgetLineByID retrieves a school's budget line by ID
+func GetBudgetLineByID(schoolID, id int64) (*models.BudgetLine, error) {
+	line := &models.BudgetLine{}
+	err := DB.QueryRow(`
+		SELECT id, tahun_ajaran, code, name, pagu, account_id, created_at
+		FROM budget_lines WHERE id = ? AND school_id = ?
+	`, id, schoolID).Scan(&line.ID, &line.TahunAjaran, &line.Code, &line.Name, &line.Pagu, &line.AccountID, &line.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrBudgetLineNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+// GetBudgetLines retrieves a school's budget lines of an academic year
+func GetBudgetLines(schoolID int64, tahunAjaran string) ([]models.BudgetLine, error) {
+	rows, err := DB.Query(`
+		SELECT id, tahun_ajaran, code, name, pagu, account_id, created_at
+		FROM budget_lines
+		WHERE school_id = ? AND tahun_ajaran = ?
+		ORDER BY code
+	`, schoolID, tahunAjaran)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.BudgetLine
+	for rows.Next() {
+		var line models.BudgetLine
+		if err := rows.Scan(&line.ID, &line.TahunAjaran, &line.Code, &line.Name, &line.Pagu, &line.AccountID, &line.CreatedAt); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// CreateExpenseRequest submits a new expense request; it starts waiting for the treasurer
+func CreateExpenseRequest(schoolID int64, req models.CreateExpenseRequest, requestedBy int64) (*models.ExpenseRequest, error) {
+	result, err := DB.Exec(`
+		INSERT INTO expense_requests (budget_line_id, judul, keterangan, nominal, status, requested_by, school_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, req.BudgetLineID, req.Judul, req.Keterangan, req.Nominal, models.ExpensePendingTreasurer, requestedBy, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetExpenseRequestByID(schoolID, id)
+}
+
+const expenseColumns = `e.id, e.budget_line_id, b.name, e.judul, COALESCE(e.keterangan, ''), e.nominal, e.status,
+	e.requested_by, e.override_by, e.created_at, e.updated_at`
+
+func scanExpense(row rowScanner) (*models.ExpenseRequest, error) {
+	expense := &models.ExpenseRequest{}
+	var overrideBy sql.NullInt64
+	err := row.Scan(
+		&expense.ID, &expense.BudgetLineID, &expense.BudgetLineName, &expense.Judul, &expense.Keterangan,
+		&expense.Nominal, &expense.Status, &expense.RequestedBy, &overrideBy,
+		&expense.CreatedAt, &expense.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	expense.OverrideBy = nullInt64Ptr(overrideBy)
+	return expense, nil
+}
+
+// GetExpenseRequestByID retrieves a school's expense request with its approvals, attachments and disbursement
+func GetExpenseRequestByID(schoolID, id int64) (*models.ExpenseRequest, error) {
+	expense, err := scanExpense(DB.QueryRow(`
+		SELECT `+expenseColumns+`
+		FROM expense_requests e
+		JOIN budget_lines b ON b.id = e.budget_line_id
+		WHERE e.id = ? AND e.school_id = ?
+	`, id, schoolID))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrExpenseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if expense.Approvals, err = getExpenseApprovals(id); err != nil {
+		return nil, err
+	}
+	if expense.Attachments, err = GetExpenseAttachments(id); err != nil {
+		return nil, err
+	}
+
+	d := &models.ExpenseDisbursement{}
+	err = DB.QueryRow(`
+		SELECT id, DATE_FORMAT(tanggal, '%Y-%m-%d'), nominal, metode, journal_entry_id, disbursed_by, created_at
+		FROM expense_disbursements WHERE expense_request_id = ?
+	`, id).Scan(&d.ID, &d.Tanggal, &d.Nominal, &d.Metode, &d.JournalEntryID, &d.DisbursedBy, &d.CreatedAt)
+	if err == nil {
+		expense.Disbursement = d
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+	return expense, nil
+}
+
+// GetExpenseRequests lists a school's expense requests, optionally filtered by status and academic year
+func GetExpenseRequests(schoolID int64, status, tahunAjaran string) ([]models.ExpenseRequest, error) {
+	query := `
+		SELECT ` + expenseColumns + `
+		FROM expense_requests e
+		JOIN budget_lines b ON b.id = e.budget_line_id
+		WHERE e.school_id = ?`
+	args := []interface{}{schoolID}
+	if status != "" {
+		query += " AND e.status = ?"
+		args = append(args, status)
+	}
+	if tahunAjaran != "" {
+		query += " AND b.tahun_ajaran = ?"
+		args = append(args, tahunAjaran)
+	}
+	query += " ORDER BY e.created_at DESC, e.id DESC"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expenses []models.ExpenseRequest
+	for rows.Next() {
+		expense, err := scanExpense(rows)
+		if err != nil {
+			return nil, err
+		}
+		expenses = append(expenses, *expense)
+	}
+	return expenses, nil
+}
+
+func getExpenseApprovals(expenseID int64) ([]models.ExpenseApproval, error) {
+	rows, err := DB.Query(`
+		SELECT id, step, decision, approver_id, COALESCE(note, ''), created_at
+		FROM expense_approvals
+		WHERE expense_request_id = ?
+		ORDER BY id
+	`, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvals []models.ExpenseApproval
+	for rows.Next() {
+		var a models.ExpenseApproval
+		if err := rows.Scan(&a.ID, &a.Step, &a.Decision, &a.ApproverID, &a.Note, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, a)
+	}
+	return approvals, nil
+}
+
+// remainingBudget returns the pagu of a budget line minus everything approved or disbursed against it,
+// excluding the expense request being decided. The budget line row is locked so concurrent approvals
+// can't both spend the same remainder.
+func remainingBudget(tx *sql.Tx, budgetLineID, excludeExpenseID int64) (int64, error) {
+	var pagu int64
+	if err := tx.QueryRow(`SELECT pagu FROM budget_lines WHERE id = ? FOR UPDATE`, budgetLineID).Scan(&pagu); err != nil {
+		return 0, err
+	}
+
+	var used int64
+	err := tx.QueryRow(`
+		SELECT COALESCE(SUM(nominal), 0)
+		FROM expense_requests
+		WHERE budget_line_id = ? AND status IN (?, ?) AND id <> ?
+	`, budgetLineID, models.ExpenseApproved, models.ExpenseDisbursed, excludeExpenseID).Scan(&used)
+	if err != nil {
+		return 0, err
+	}
+	return pagu - used, nil
+}
+
+// DecideExpenseRequest records the approval or rejection of the step an expense is waiting for.
+// The approver's role must grant that step's permission, checked against the locked row so a
+// concurrent decision cannot move the request to a step the approver may not decide.
+// Approving beyond the remaining budget fails with ErrOverBudget unless overrideBudget is set
+// by a role allowed to override budgets.
+func DecideExpenseRequest(schoolID int64, req models.ExpenseDecisionRequest, approverID int64, approverRole models.UserRole) (*models.ExpenseRequest, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status models.ExpenseStatus
+	var budgetLineID, nominal, requestedBy int64
+	var overrideBy sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT status, budget_line_id, nominal, requested_by, override_by
+		FROM expense_requests WHERE id = ? AND school_id = ? FOR UPDATE
+	`, req.ExpenseID, schoolID).Scan(&status, &budgetLineID, &nominal, &requestedBy, &overrideBy)
+	if err == sql.ErrNoRows {
+		return nil, ErrExpenseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := approvalSteps[status]
+	if !ok {
+		return nil, ErrExpenseNotPending
+	}
+	if !approverRole.HasPermission(models.ApprovalPermission(status)) {
+		return nil, ErrStepNotPermitted
+	}
+
+	// Each step needs a different person, so an admin holding every approval permission
+	// cannot approve alone and nobody approves their own request
+	if approverID == requestedBy {
+		return nil, ErrOwnExpense
+	}
+	var decided int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM expense_approvals WHERE expense_request_id = ? AND approver_id = ?
+	`, req.ExpenseID, approverID).Scan(&decided)
+	if err != nil {
+		return nil, err
+	}
+	if decided > 0 {
+		return nil, ErrAlreadyDecided
+	}
+
+	decision := "rejected"
+	next := models.ExpenseRejected
+	if req.Approve {
+		decision = "approved"
+		next = step.next
+
+		if !overrideBy.Valid {
+			remaining, err := remainingBudget(tx, budgetLineID, req.ExpenseID)
+			if err != nil {
+				return nil, err
+			}
+			if nominal > remaining {
+				if !req.OverrideBudget {
+					return nil, ErrOverBudget
+				}
+				if !approverRole.HasPermission(models.PermBudgetOverride) {
+					return nil, ErrOverrideNotPermitted
+				}
+				if _, err := tx.Exec(`UPDATE expense_requests SET override_by = ? WHERE id = ?`, approverID, req.ExpenseID); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO expense_approvals (expense_request_id, step, decision, approver_id, note)
+		VALUES (?, ?, ?, ?, ?)
+	`, req.ExpenseID, step.step, decision, approverID, req.Note)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE expense_requests SET status = ? WHERE id = ?`, next, req.ExpenseID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetExpenseRequestByID(schoolID, req.ExpenseID)
+}
+
+// DisburseExpenseRequest pays out an approved expense and posts it to the ledger:
+// debit the budget line's expense account, credit cash or bank.
+func DisburseExpenseRequest(schoolID int64, req models.DisburseExpenseRequest, disbursedBy int64) (*models.ExpenseRequest, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status models.ExpenseStatus
+	var nominal, accountID int64
+	var judul string
+	err = tx.QueryRow(`
+		SELECT e.status, e.nominal, e.judul, b.account_id
+		FROM expense_requests e
+		JOIN budget_lines b ON b.id = e.budget_line_id
+		WHERE e.id = ? AND e.school_id = ? FOR UPDATE
+	`, req.ExpenseID, schoolID).Scan(&status, &nominal, &judul, &accountID)
+	if err == sql.ErrNoRows {
+		return nil, ErrExpenseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != models.ExpenseApproved {
+		return nil, ErrExpenseNotApproved
+	}
+
+	cashID, err := cashAccountIDForMetode(tx, schoolID, req.Metode)
+	if err != nil {
+		return nil, err
+	}
+	id := req.ExpenseID
+	entryID, err := insertJournalEntry(tx, schoolID, req.Tanggal, fmt.Sprintf("Pengeluaran #%d: %s", req.ExpenseID, judul), models.SourceExpense, &id, []models.JournalLine{
+		{AccountID: accountID, Debit: nominal},
+		{AccountID: cashID, Credit: nominal},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO expense_disbursements (expense_request_id, tanggal, nominal, metode, journal_entry_id, disbursed_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, req.ExpenseID, req.Tanggal, nominal, req.Metode, entryID, disbursedBy)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE expense_requests SET status = ? WHERE id = ?`, models.ExpenseDisbursed, req.ExpenseID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetExpenseRequestByID(schoolID, req.ExpenseID)
+}
+
+// CreateExpenseAttachment records a file uploaded for one of a school's expense requests
+func CreateExpenseAttachment(schoolID, expenseID int64, a models.ExpenseAttachment) (*models.ExpenseAttachment, error) {
+	result, err := DB.Exec(`
+		INSERT INTO expense_attachments (expense_request_id, file_name, content_type, size, storage_path, uploaded_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, expenseID, a.FileName, a.ContentType, a.Size, a.StoragePath, a.UploadedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetExpenseAttachmentByID(schoolID, id)
+}
+
+// GetExpenseAttachmentByID retrieves an attachment of a school's expense request by ID
+func GetExpenseAttachmentByID(schoolID, id int64) (*models.ExpenseAttachment, error) {
+	a := &models.ExpenseAttachment{}
+	err := DB.QueryRow(`
+		SELECT a.id, a.file_name, a.content_type, a.size, a.storage_path, a.uploaded_by, a.created_at
+		FROM expense_attachments a
+		JOIN expense_requests e ON e.id = a.expense_request_id
+		WHERE a.id = ? AND e.school_id = ?
+	`, id, schoolID).Scan(&a.ID, &a.FileName, &a.ContentType, &a.Size, &a.StoragePath, &a.UploadedBy, &a.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetExpenseAttachments retrieves all attachments of an expense request
+func GetExpenseAttachments(expenseID int64) ([]models.ExpenseAttachment, error) {
+	rows, err := DB.Query(`
+		SELECT id, file_name, content_type, size, storage_path, uploaded_by, created_at
+		FROM expense_attachments
+		WHERE expense_request_id = ?
+		ORDER BY id
+	`, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []models.ExpenseAttachment
+	for rows.Next() {
+		var a models.ExpenseAttachment
+		if err := rows.Scan(&a.ID, &a.FileName, &a.ContentType, &a.Size, &a.StoragePath, &a.UploadedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
+
+// GetBudgetReport compares every budget line of a school's academic year with committed and disbursed spending
+func GetBudgetReport(schoolID int64, tahunAjaran string) (*models.BudgetReport, error) {
+	rows, err := DB.Query(`
+		SELECT b.id, b.code, b.name, b.pagu,
+			   COALESCE(SUM(CASE WHEN e.status = ? THEN e.nominal END), 0),
+			   COALESCE(SUM(CASE WHEN e.status = ? THEN e.nominal END), 0)
+		FROM budget_lines b
+		LEFT JOIN expense_requests e ON e.budget_line_id = b.id
+		WHERE b.school_id = ? AND b.tahun_ajaran = ?
+		GROUP BY b.id, b.code, b.name, b.pagu
+		ORDER BY b.code
+	`, models.ExpenseApproved, models.ExpenseDisbursed, schoolID, tahunAjaran)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &models.BudgetReport{TahunAjaran: tahunAjaran, Lines: []models.BudgetRealization{}}
+	for rows.Next() {
+		var line models.BudgetRealization
+		if err := rows.Scan(&line.BudgetLineID, &line.Code, &line.Name, &line.Pagu, &line.Komitmen, &line.Realisasi); err != nil {
+			return nil, err
+		}
+		line.Sisa = line.Pagu - line.Komitmen - line.Realisasi
+		line.Persentase = percentOf(line.Realisasi, line.Pagu)
+		report.TotalPagu += line.Pagu
+		report.TotalKomitmen += line.Komitmen
+		report.TotalRealisasi += line.Realisasi
+		report.TotalSisa += line.Sisa
+		report.Lines = append(report.Lines, line)
+	}
+	return report, nil
+}
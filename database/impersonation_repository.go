@@ -0,0 +1,114 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"komite-sekolah/models"
+)
+
+var ErrImpersonationNotFound = errors.New("Impersonation not found")
+
+// CreateImpersonation records that a staff member started viewing the app as a student
+// through the given session
+func CreateImpersonation(schoolID, staffID, studentID, sessionID int64, expiresAt time.Time) (*models.Impersonation, error) {
+	now := time.Now()
+	result, err := DB.Exec(`
+		INSERT INTO impersonations (school_id, staff_id, student_id, session_id, started_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, schoolID, staffID, studentID, sessionID, now, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return &models.Impersonation{
+		ID: id, SchoolID: schoolID, StaffID: staffID, StudentID: studentID, SessionID: sessionID,
+		StartedAt: now, ExpiresAt: expiresAt,
+	}, nil
+}
+
+const impersonationColumns = `id, school_id, staff_id, student_id, session_id, started_at, expires_at, ended_at, COALESCE(end_reason, '')`
+
+func scanImpersonation(row rowScanner) (*models.Impersonation, error) {
+	imp := &models.Impersonation{}
+	var endedAt sql.NullTime
+	err := row.Scan(&imp.ID, &imp.SchoolID, &imp.StaffID, &imp.StudentID, &imp.SessionID,
+		&imp.StartedAt, &imp.ExpiresAt, &endedAt, &imp.EndReason)
+	if err == sql.ErrNoRows {
+		return nil, ErrImpersonationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if endedAt.Valid {
+		imp.EndedAt = &endedAt.Time
+	}
+	return imp, nil
+}
+
+// GetImpersonationBySession returns the impersonation an access token's session belongs to
+func GetImpersonationBySession(sessionID int64) (*models.Impersonation, error) {
+	return scanImpersonation(DB.QueryRow(`SELECT `+impersonationColumns+` FROM impersonations WHERE session_id = ?`, sessionID))
+}
+
+// GetExpiredImpersonations returns impersonations that ran out without being ended
+func GetExpiredImpersonations() ([]models.Impersonation, error) {
+	rows, err := DB.Query(`
+		SELECT `+impersonationColumns+` FROM impersonations
+		WHERE ended_at IS NULL AND expires_at <= ?
+	`, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Impersonation
+	for rows.Next() {
+		imp, err := scanImpersonation(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *imp)
+	}
+	return list, rows.Err()
+}
+
+// EndImpersonation closes an impersonation and revokes its session. It reports false when
+// the impersonation had already been closed, so its end is only recorded once even with
+// several instances running.
+func EndImpersonation(imp *models.Impersonation, reason string) (bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE impersonations SET ended_at = ?, end_reason = ?
+		WHERE id = ? AND ended_at IS NULL
+	`, now, reason, imp.ID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sessions SET revoked_at = ?
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, now, imp.SessionID, imp.StudentID)
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	imp.EndedAt = &now
+	imp.EndReason = reason
+	return true, nil
+}
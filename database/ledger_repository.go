@@ -0,0 +1,593 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"komite-sekolah/config"
+	"komite-sekolah/models"
+)
+
+var (
+	ErrAccountNotFound     = errors.New("Account not found")
+	ErrUnbalancedJournal   = errors.New("Journal entry is not balanced")
+	ErrInvalidJournalLines = errors.New("Each journal line needs an account and either a debit or a credit")
+)
+
+// defaultAccounts is the chart of accounts every school starts with. More accounts can be added through the API.
+var defaultAccounts = []models.CreateAccountRequest{
+	{Code: "1101", Name: "Kas Tunai", Type: models.AccountAsset, IsCash: true},
+	{Code: "1102", Name: "Kas di Bank", Type: models.AccountAsset, IsCash: true},
+	{Code: "3101", Name: "Saldo Awal", Type: models.AccountEquity},
+	{Code: "4101", Name: "Pendapatan Iuran Komite", Type: models.AccountIncome},
+	{Code: "5101", Name: "Beban Kegiatan Sekolah", Type: models.AccountExpense},
+	{Code: "5102", Name: "Beban Administrasi", Type: models.AccountExpense},
+}
+
+// seedAccounts gives every school the default chart of accounts. The chart used to be shared
+// by all schools, so journal and budget lines still pointing at another school's account are
+// moved to the school's own account with the same code.
+func seedAccounts() {
+	rows, err := DB.Query(`SELECT id FROM schools`)
+	if err != nil {
+		log.Fatal("Failed to seed accounts:", err)
+	}
+	var schoolIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			log.Fatal("Failed to seed accounts:", err)
+		}
+		schoolIDs = append(schoolIDs, id)
+	}
+	rows.Close()
+
+	for _, id := range schoolIDs {
+		if err := seedSchoolAccounts(id); err != nil {
+			log.Fatal("Failed to seed accounts:", err)
+		}
+	}
+
+	if err := splitSharedAccounts(); err != nil {
+		log.Fatal("Failed to give each school its own accounts:", err)
+	}
+}
+
+// seedSchoolAccounts creates the default chart of accounts of one school
+func seedSchoolAccounts(schoolID int64) error {
+	for _, a := range defaultAccounts {
+		_, err := DB.Exec(`
+			INSERT IGNORE INTO accounts (school_id, code, name, type, is_cash)
+			VALUES (?, ?, ?, ?, ?)
+		`, schoolID, a.Code, a.Name, a.Type, a.IsCash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitSharedAccounts copies accounts that other schools posted to while the chart was shared
+// into those schools' charts, and points their journal and budget lines at the copies
+func splitSharedAccounts() error {
+	queries := []string{
+		`INSERT IGNORE INTO accounts (school_id, code, name, type, is_cash)
+		SELECT DISTINCT e.school_id, a.code, a.name, a.type, a.is_cash
+		FROM journal_lines l
+		JOIN journal_entries e ON e.id = l.entry_id
+		JOIN accounts a ON a.id = l.account_id
+		WHERE a.school_id <> e.school_id`,
+		`UPDATE journal_lines l
+		JOIN journal_entries e ON e.id = l.entry_id
+		JOIN accounts a ON a.id = l.account_id
+		JOIN accounts own ON own.school_id = e.school_id AND own.code = a.code
+		SET l.account_id = own.id
+		WHERE a.school_id <> e.school_id`,
+		`INSERT IGNORE INTO accounts (school_id, code, name, type, is_cash)
+		SELECT DISTINCT b.school_id, a.code, a.name, a.type, a.is_cash
+		FROM budget_lines b
+		JOIN accounts a ON a.id = b.account_id
+		WHERE a.school_id <> b.school_id`,
+		`UPDATE budget_lines b
+		JOIN accounts a ON a.id = b.account_id
+		JOIN accounts own ON own.school_id = b.school_id AND own.code = a.code
+		SET b.account_id = own.id
+		WHERE a.school_id <> b.school_id`,
+	}
+	for _, q := range queries {
+		if _, err := DB.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillLedger posts an opening entry for every payment recorded before the ledger existed,
+// debiting the cash or bank account and crediting the opening balance account.
+func backfillLedger() {
+	rows, err := DB.Query(`
+		SELECT p.id, p.school_id, DATE_FORMAT(p.tanggal, '%Y-%m-%d'), p.nominal, p.metode
+		FROM payments p
+		WHERE NOT EXISTS (
+			SELECT 1 FROM journal_entries e
+			WHERE e.source_id = p.id AND e.source_type IN (?, ?)
+		)
+		ORDER BY p.tanggal, p.id
+	`, models.SourcePayment, models.SourceOpening)
+	if err != nil {
+		log.Fatal("Failed to find payments to backfill:", err)
+	}
+
+	var payments []models.Payment
+	var schoolIDs []int64
+	for rows.Next() {
+		var p models.Payment
+		var schoolID int64
+		if err := rows.Scan(&p.ID, &schoolID, &p.Tanggal, &p.Nominal, &p.Metode); err != nil {
+			rows.Close()
+			log.Fatal("Failed to read payments to backfill:", err)
+		}
+		payments = append(payments, p)
+		schoolIDs = append(schoolIDs, schoolID)
+	}
+	rows.Close()
+
+	if len(payments) == 0 {
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Fatal("Failed to backfill ledger:", err)
+	}
+	defer tx.Rollback()
+
+	for i, p := range payments {
+		openingID, err := accountIDByCode(tx, schoolIDs[i], config.AppConfig.LedgerOpeningAccount)
+		if err != nil {
+			log.Fatal("Failed to backfill ledger:", err)
+		}
+		cashID, err := cashAccountIDForMetode(tx, schoolIDs[i], p.Metode)
+		if err != nil {
+			log.Fatal("Failed to backfill ledger:", err)
+		}
+		id := p.ID
+		_, err = insertJournalEntry(tx, schoolIDs[i], p.Tanggal, fmt.Sprintf("Saldo awal pembayaran #%d", p.ID), models.SourceOpening, &id, []models.JournalLine{
+			{AccountID: cashID, Debit: p.Nominal},
+			{AccountID: openingID, Credit: p.Nominal},
+		})
+		if err != nil {
+			log.Fatal("Failed to backfill ledger:", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Fatal("Failed to backfill ledger:", err)
+	}
+	log.Printf("Ledger backfilled with %d opening entries", len(payments))
+}
+
+// CreateAccount adds an account to a school's chart of accounts
+func CreateAccount(schoolID int64, req models.CreateAccountRequest) (*models.Account, error) {
+	result, err := DB.Exec(`
+		INSERT INTO accounts (school_id, code, name, type, is_cash)
+		VALUES (?, ?, ?, ?, ?)
+	`, schoolID, req.Code, req.Name, req.Type, req.IsCash)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetAccountByID(schoolID, id)
+}
+
+// GetAccountByID retrieves one of a school's accounts by ID
+func GetAccountByID(schoolID, id int64) (*models.Account, error) {
+	account := &models.Account{}
+	err := DB.QueryRow(`
+		SELECT id, code, name, type, is_cash, created_at
+		FROM accounts WHERE id = ? AND school_id = ?
+	`, id, schoolID).Scan(&account.ID, &account.Code, &account.Name, &account.Type, &account.IsCash, &account.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// GetAllAccounts retrieves a school's chart of accounts ordered by code
+func GetAllAccounts(schoolID int64) ([]models.Account, error) {
+	rows, err := DB.Query(`
+		SELECT id, code, name, type, is_cash, created_at
+		FROM accounts
+		WHERE school_id = ?
+		ORDER BY code
+	`, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []models.Account
+	for rows.Next() {
+		var account models.Account
+		if err := rows.Scan(&account.ID, &account.Code, &account.Name, &account.Type, &account.IsCash, &account.CreatedAt); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+func accountIDByCode(tx *sql.Tx, schoolID int64, code string) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`SELECT id FROM accounts WHERE school_id = ? AND code = ?`, schoolID, code).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("account %s: %w", code, ErrAccountNotFound)
+	}
+	return id, err
+}
+
+func cashAccountIDForMetode(tx *sql.Tx, schoolID int64, metode string) (int64, error) {
+	if metode == models.MetodeTransfer {
+		return accountIDByCode(tx, schoolID, config.AppConfig.LedgerBankAccount)
+	}
+	return accountIDByCode(tx, schoolID, config.AppConfig.LedgerCashAccount)
+}
+
+// insertJournalEntry writes a balanced journal entry of a school and its lines inside tx
+func insertJournalEntry(tx *sql.Tx, schoolID int64, tanggal, keterangan, sourceType string, sourceID *int64, lines []models.JournalLine) (int64, error) {
+	if len(lines) < 2 {
+		return 0, ErrInvalidJournalLines
+	}
+	var debit, credit int64
+	for _, l := range lines {
+		if l.AccountID == 0 || l.Debit < 0 || l.Credit < 0 || (l.Debit == 0) == (l.Credit == 0) {
+			return 0, ErrInvalidJournalLines
+		}
+		debit += l.Debit
+		credit += l.Credit
+	}
+	if debit != credit {
+		return 0, ErrUnbalancedJournal
+	}
+	for _, l := range lines {
+		var n int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM accounts WHERE id = ? AND school_id = ?`, l.AccountID, schoolID).Scan(&n)
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, fmt.Errorf("account %d: %w", l.AccountID, ErrAccountNotFound)
+		}
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO journal_entries (tanggal, keterangan, source_type, source_id, school_id)
+		VALUES (?, ?, ?, ?, ?)
+	`, tanggal, keterangan, sourceType, sourceID, schoolID)
+	if err != nil {
+		return 0, err
+	}
+	entryID, _ := result.LastInsertId()
+
+	for _, l := range lines {
+		_, err := tx.Exec(`
+			INSERT INTO journal_lines (entry_id, account_id, debit, credit, memo)
+			VALUES (?, ?, ?, ?, ?)
+		`, entryID, l.AccountID, l.Debit, l.Credit, l.Memo)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return entryID, nil
+}
+
+// postPaymentJournal records a student payment: debit cash or bank, credit fee income
+func postPaymentJournal(tx *sql.Tx, schoolID int64, p *models.Payment) error {
+	cashID, err := cashAccountIDForMetode(tx, schoolID, p.Metode)
+	if err != nil {
+		return err
+	}
+	incomeID, err := accountIDByCode(tx, schoolID, config.AppConfig.LedgerIncomeAccount)
+	if err != nil {
+		return err
+	}
+	id := p.ID
+	_, err = insertJournalEntry(tx, schoolID, p.Tanggal, fmt.Sprintf("Pembayaran #%d", p.ID), models.SourcePayment, &id, []models.JournalLine{
+		{AccountID: cashID, Debit: p.Nominal},
+		{AccountID: incomeID, Credit: p.Nominal},
+	})
+	return err
+}
+
+// reversePaymentJournal cancels the ledger effect of a payment that is being edited or deleted
+func reversePaymentJournal(tx *sql.Tx, schoolID int64, p *models.Payment, tanggal string) error {
+	cashID, err := cashAccountIDForMetode(tx, schoolID, p.Metode)
+	if err != nil {
+		return err
+	}
+	incomeID, err := accountIDByCode(tx, schoolID, config.AppConfig.LedgerIncomeAccount)
+	if err != nil {
+		return err
+	}
+	id := p.ID
+	_, err = insertJournalEntry(tx, schoolID, tanggal, fmt.Sprintf("Koreksi pembayaran #%d", p.ID), models.SourcePaymentReversal, &id, []models.JournalLine{
+		{AccountID: incomeID, Debit: p.Nominal},
+		{AccountID: cashID, Credit: p.Nominal},
+	})
+	return err
+}
+
+// CreateJournalEntry records a manual journal entry, e.g. a direct expense
+func CreateJournalEntry(schoolID int64, req models.CreateJournalEntryRequest) (*models.JournalEntry, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id, err := insertJournalEntry(tx, schoolID, req.Tanggal, req.Keterangan, models.SourceManual, nil, req.Lines)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetJournalEntryByID(schoolID, id)
+}
+
+// CreateRefund records money returned to a student: debit fee income, credit cash or bank
+func CreateRefund(schoolID int64, req models.CreateRefundRequest) (*models.Refund, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO refunds (user_id, tanggal, nominal, metode, keterangan, school_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, req.UserID, req.Tanggal, req.Nominal, req.Metode, req.Keterangan, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+
+	cashID, err := cashAccountIDForMetode(tx, schoolID, req.Metode)
+	if err != nil {
+		return nil, err
+	}
+	incomeID, err := accountIDByCode(tx, schoolID, config.AppConfig.LedgerIncomeAccount)
+	if err != nil {
+		return nil, err
+	}
+	_, err = insertJournalEntry(tx, schoolID, req.Tanggal, fmt.Sprintf("Pengembalian dana #%d", id), models.SourceRefund, &id, []models.JournalLine{
+		{AccountID: incomeID, Debit: req.Nominal},
+		{AccountID: cashID, Credit: req.Nominal},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	refund := &models.Refund{}
+	err = DB.QueryRow(`
+		SELECT id, user_id, DATE_FORMAT(tanggal, '%Y-%m-%d'), nominal, metode, COALESCE(keterangan, ''), created_at
+		FROM refunds WHERE id = ?
+	`, id).Scan(&refund.ID, &refund.UserID, &refund.Tanggal, &refund.Nominal, &refund.Metode, &refund.Keterangan, &refund.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// CreateTransfer records money moved between two cash accounts
+func CreateTransfer(schoolID int64, req models.CreateTransferRequest) (*models.JournalEntry, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	keterangan := req.Keterangan
+	if keterangan == "" {
+		keterangan = "Pemindahbukuan kas"
+	}
+	id, err := insertJournalEntry(tx, schoolID, req.Tanggal, keterangan, models.SourceTransfer, nil, []models.JournalLine{
+		{AccountID: req.ToAccountID, Debit: req.Nominal},
+		{AccountID: req.FromAccountID, Credit: req.Nominal},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetJournalEntryByID(schoolID, id)
+}
+
+const journalEntryColumns = `e.id, DATE_FORMAT(e.tanggal, '%Y-%m-%d'), COALESCE(e.keterangan, ''), e.source_type, e.source_id, e.created_at`
+
+func scanJournalEntry(row rowScanner) (*models.JournalEntry, error) {
+	entry := &models.JournalEntry{}
+	var sourceID sql.NullInt64
+	err := row.Scan(&entry.ID, &entry.Tanggal, &entry.Keterangan, &entry.SourceType, &sourceID, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	entry.SourceID = nullInt64Ptr(sourceID)
+	return entry, nil
+}
+
+// GetJournalEntryByID retrieves a school's journal entry with its lines
+func GetJournalEntryByID(schoolID, id int64) (*models.JournalEntry, error) {
+	entry, err := scanJournalEntry(DB.QueryRow(`SELECT `+journalEntryColumns+` FROM journal_entries e WHERE e.id = ? AND e.school_id = ?`, id, schoolID))
+	if err != nil {
+		return nil, err
+	}
+	if err := loadJournalLines([]*models.JournalEntry{entry}); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// GetJournalEntries retrieves a school's general ledger between from and to. When accountID is
+// non-zero only entries touching that account are returned.
+func GetJournalEntries(schoolID int64, from, to string, accountID int64) ([]models.JournalEntry, error) {
+	query := `SELECT ` + journalEntryColumns + ` FROM journal_entries e WHERE e.school_id = ? AND e.tanggal BETWEEN ? AND ?`
+	args := []interface{}{schoolID, from, to}
+	if accountID != 0 {
+		query += ` AND EXISTS (SELECT 1 FROM journal_lines l WHERE l.entry_id = e.id AND l.account_id = ?)`
+		args = append(args, accountID)
+	}
+	query += ` ORDER BY e.tanggal, e.id`
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.JournalEntry
+	for rows.Next() {
+		entry, err := scanJournalEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+
+	if err := loadJournalLines(entries); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.JournalEntry, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, *e)
+	}
+	return result, nil
+}
+
+func loadJournalLines(entries []*models.JournalEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*models.JournalEntry, len(entries))
+	placeholders := ""
+	args := make([]interface{}, 0, len(entries))
+	for i, e := range entries {
+		byID[e.ID] = e
+		e.Lines = []models.JournalLine{}
+		if i > 0 {
+			placeholders += ", "
+		}
+		placeholders += "?"
+		args = append(args, e.ID)
+	}
+
+	rows, err := DB.Query(`
+		SELECT l.entry_id, l.id, l.account_id, a.code, a.name, l.debit, l.credit, COALESCE(l.memo, '')
+		FROM journal_lines l
+		JOIN accounts a ON a.id = l.account_id
+		WHERE l.entry_id IN (`+placeholders+`)
+		ORDER BY l.entry_id, l.id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entryID int64
+		var l models.JournalLine
+		if err := rows.Scan(&entryID, &l.ID, &l.AccountID, &l.AccountCode, &l.AccountName, &l.Debit, &l.Credit, &l.Memo); err != nil {
+			return err
+		}
+		byID[entryID].Lines = append(byID[entryID].Lines, l)
+	}
+	return nil
+}
+
+// GetTrialBalance sums debits and credits per account for a school's entries dated on or before asOf
+func GetTrialBalance(schoolID int64, asOf string) (*models.TrialBalance, error) {
+	rows, err := DB.Query(`
+		SELECT a.id, a.code, a.name, a.type,
+			   COALESCE(SUM(CASE WHEN e.id IS NOT NULL THEN l.debit END), 0),
+			   COALESCE(SUM(CASE WHEN e.id IS NOT NULL THEN l.credit END), 0)
+		FROM accounts a
+		LEFT JOIN journal_lines l ON l.account_id = a.id
+		LEFT JOIN journal_entries e ON e.id = l.entry_id AND e.school_id = ? AND e.tanggal <= ?
+		WHERE a.school_id = ?
+		GROUP BY a.id, a.code, a.name, a.type
+		ORDER BY a.code
+	`, schoolID, asOf, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tb := &models.TrialBalance{AsOf: asOf, Rows: []models.TrialBalanceRow{}}
+	for rows.Next() {
+		var row models.TrialBalanceRow
+		if err := rows.Scan(&row.AccountID, &row.Code, &row.Name, &row.Type, &row.Debit, &row.Credit); err != nil {
+			return nil, err
+		}
+		row.Saldo = row.Debit - row.Credit
+		tb.TotalDebit += row.Debit
+		tb.TotalCredit += row.Credit
+		tb.Rows = append(tb.Rows, row)
+	}
+	tb.Balanced = tb.TotalDebit == tb.TotalCredit
+	return tb, nil
+}
+
+// GetCashBook builds a school's buku kas umum for one account between from and to, with running balances
+func GetCashBook(schoolID int64, account *models.Account, from, to string) (*models.CashBook, error) {
+	book := &models.CashBook{Account: *account, DariTanggal: from, SampaiTanggal: to, Rows: []models.CashBookRow{}}
+
+	err := DB.QueryRow(`
+		SELECT COALESCE(SUM(l.debit - l.credit), 0)
+		FROM journal_lines l
+		JOIN journal_entries e ON e.id = l.entry_id
+		WHERE l.account_id = ? AND e.school_id = ? AND e.tanggal < ?
+	`, account.ID, schoolID, from).Scan(&book.SaldoAwal)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`
+		SELECT e.id, DATE_FORMAT(e.tanggal, '%Y-%m-%d'), COALESCE(e.keterangan, ''), e.source_type, l.debit, l.credit
+		FROM journal_lines l
+		JOIN journal_entries e ON e.id = l.entry_id
+		WHERE l.account_id = ? AND e.school_id = ? AND e.tanggal BETWEEN ? AND ?
+		ORDER BY e.tanggal, e.id, l.id
+	`, account.ID, schoolID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	saldo := book.SaldoAwal
+	for rows.Next() {
+		var row models.CashBookRow
+		if err := rows.Scan(&row.EntryID, &row.Tanggal, &row.Keterangan, &row.SourceType, &row.Masuk, &row.Keluar); err != nil {
+			return nil, err
+		}
+		saldo += row.Masuk - row.Keluar
+		row.Saldo = saldo
+		book.Rows = append(book.Rows, row)
+	}
+	book.SaldoAkhir = saldo
+	return book, nil
+}
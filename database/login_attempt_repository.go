@@ -0,0 +1,165 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"komite-sekolah/config"
+	"komite-sekolah/models"
+)
+
+var ErrLockoutNotFound = errors.New("Lockout not found")
+
+// LoginBlockedUntil returns when the subject may try to log in again, or the zero time when
+// it may try now. schoolID is 0 for subjects that are not tied to a school (IPs, foundation
+// admins and usernames that do not exist).
+func LoginBlockedUntil(scope string, schoolID int64, subject string) (time.Time, error) {
+	var blockedUntil sql.NullTime
+	err := DB.QueryRow(`
+		SELECT blocked_until FROM login_attempts
+		WHERE scope = ? AND school_id = ? AND subject = ?
+	`, scope, schoolID, subject).Scan(&blockedUntil)
+	if err == sql.ErrNoRows || (err == nil && (!blockedUntil.Valid || blockedUntil.Time.Before(time.Now()))) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return blockedUntil.Time, nil
+}
+
+// RecordLoginFailure counts a failed login and returns until when the subject must wait, or
+// the zero time when it need not. Every failure on an account doubles the wait, starting at
+// LOGIN_BACKOFF_BASE; an IP, which a whole school may share behind NAT, only has to wait once
+// it reaches its limit. Reaching the scope's failure limit locks the subject for
+// LOGIN_LOCKOUT_DURATION. The row is locked while it is
+// updated so concurrent attempts on several server instances are all counted.
+func RecordLoginFailure(scope string, schoolID int64, subject string) (time.Time, error) {
+	cfg := config.AppConfig
+	maxFailures := cfg.LoginMaxFailures
+	if scope == models.LoginScopeIP {
+		maxFailures = cfg.LoginIPMaxFailures
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT IGNORE INTO login_attempts (scope, school_id, subject) VALUES (?, ?, ?)
+	`, scope, schoolID, subject)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var id, failures int64
+	var lastFailure sql.NullTime
+	err = tx.QueryRow(`
+		SELECT id, failures, last_failure_at FROM login_attempts
+		WHERE scope = ? AND school_id = ? AND subject = ?
+		FOR UPDATE
+	`, scope, schoolID, subject).Scan(&id, &failures, &lastFailure)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now()
+	if lastFailure.Valid && now.Sub(lastFailure.Time) > cfg.LoginFailureWindow {
+		failures = 0
+	}
+	failures++
+
+	locked := failures >= maxFailures
+	var blockedUntil time.Time
+	switch {
+	case locked:
+		blockedUntil = now.Add(cfg.LoginLockout)
+	case scope != models.LoginScopeIP:
+		wait := cfg.LoginBackoffBase
+		for i := int64(1); i < failures && wait < cfg.LoginLockout; i++ {
+			wait *= 2
+		}
+		if wait > cfg.LoginLockout {
+			wait = cfg.LoginLockout
+		}
+		blockedUntil = now.Add(wait)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE login_attempts
+		SET failures = ?, locked = ?, last_failure_at = ?, blocked_until = ?
+		WHERE id = ?
+	`, failures, locked, now, nullTime(blockedUntil), id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, err
+	}
+	return blockedUntil, nil
+}
+
+// nullTime stores the zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// ClearLoginFailures forgets the failed logins of a subject after it logs in successfully
+func ClearLoginFailures(scope string, schoolID int64, subject string) error {
+	_, err := DB.Exec(`
+		DELETE FROM login_attempts WHERE scope = ? AND school_id = ? AND subject = ?
+	`, scope, schoolID, subject)
+	return err
+}
+
+// GetLoginLockouts lists the accounts of a school that currently have to wait before logging
+// in again, longest wait first. withUnscoped adds the IPs, foundation admins and unknown
+// usernames, which are stored under school 0 and only foundation admins may see.
+func GetLoginLockouts(schoolID int64, withUnscoped bool) ([]models.LoginLockout, error) {
+	rows, err := DB.Query(`
+		SELECT id, scope, subject, failures, locked, last_failure_at, blocked_until
+		FROM login_attempts
+		WHERE school_id IN (?, ?) AND blocked_until > ?
+		ORDER BY blocked_until DESC
+	`, schoolID, unscopedSchool(schoolID, withUnscoped), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lockouts []models.LoginLockout
+	for rows.Next() {
+		var l models.LoginLockout
+		if err := rows.Scan(&l.ID, &l.Scope, &l.Subject, &l.Failures, &l.Locked, &l.LastFailureAt, &l.BlockedUntil); err != nil {
+			return nil, err
+		}
+		lockouts = append(lockouts, l)
+	}
+	return lockouts, nil
+}
+
+// ClearLoginLockout lifts a lockout and resets its failure count. Lockouts of school 0 can
+// only be lifted withUnscoped.
+func ClearLoginLockout(schoolID int64, withUnscoped bool, id int64) error {
+	result, err := DB.Exec(`DELETE FROM login_attempts WHERE id = ? AND school_id IN (?, ?)`,
+		id, schoolID, unscopedSchool(schoolID, withUnscoped))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrLockoutNotFound
+	}
+	return nil
+}
+
+// unscopedSchool returns the second school_id a lockout query matches: 0 when the unscoped
+// lockouts are included, otherwise the school itself again
+func unscopedSchool(schoolID int64, withUnscoped bool) int64 {
+	if withUnscoped {
+		return 0
+	}
+	return schoolID
+}
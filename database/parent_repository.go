@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"komite-sekolah/models"
+)
+
+var (
+	ErrPhoneTaken = errors.New("Phone number is already registered")
+	ErrEmailTaken = errors.New("Email is already registered")
+)
+
+// CreateParent creates a parent account in a school. Phone and email are optional
+// individually but at least one is needed to log in; empty values are stored as NULL so
+// they stay unique within the school.
+func CreateParent(schoolID int64, req models.CreateParentRequest, hashedPassword string) (*models.User, error) {
+	if req.Phone != "" {
+		if taken, err := valueTakenByOther(schoolID, "phone", req.Phone, 0); err != nil {
+			return nil, err
+		} else if taken {
+			return nil, ErrPhoneTaken
+		}
+	}
+	if req.Email != "" {
+		if taken, err := valueTakenByOther(schoolID, "email", req.Email, 0); err != nil {
+			return nil, err
+		} else if taken {
+			return nil, ErrEmailTaken
+		}
+	}
+
+	result, err := DB.Exec(`
+		INSERT INTO users (phone, email, name, password, role, must_change_password, school_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, nullString(req.Phone), nullString(req.Email), req.Name, hashedPassword, models.RoleParent, 1, schoolID)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, ErrDuplicateUser
+		}
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetSchoolUser(schoolID, id)
+}
+
+// GetParentByLogin finds a parent of a school by phone number or email
+func GetParentByLogin(schoolID int64, phone, email string) (*models.User, error) {
+	column, value := "phone", phone
+	if phone == "" {
+		column, value = "email", email
+	}
+
+	user, err := scanUser(DB.QueryRow(`SELECT `+userColumns+` `+userFrom+` WHERE u.`+column+` = ? AND u.role = ? AND u.school_id = ?`, value, models.RoleParent, schoolID))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetAllParents returns every parent account of a school with its linked children
+func GetAllParents(schoolID int64) ([]models.ParentWithChildren, error) {
+	rows, err := DB.Query(`SELECT `+userColumns+` `+userFrom+` WHERE u.role = ? AND u.school_id = ? ORDER BY u.name`, models.RoleParent, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parents []models.ParentWithChildren
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, models.ParentWithChildren{Parent: *user})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range parents {
+		children, err := GetChildrenByParentID(parents[i].Parent.ID)
+		if err != nil {
+			return nil, err
+		}
+		if children == nil {
+			children = []models.ParentChild{}
+		}
+		parents[i].Children = children
+	}
+	return parents, nil
+}
+
+// LinkParentStudent links a parent to a student. Linking an existing pair updates the relationship.
+func LinkParentStudent(req models.LinkParentRequest) error {
+	_, err := DB.Exec(`
+		INSERT INTO parent_students (parent_id, student_id, relationship)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE relationship = VALUES(relationship)
+	`, req.ParentID, req.StudentID, nullString(req.Relationship))
+	return err
+}
+
+// UnlinkParentStudent removes the link between a parent and a student
+func UnlinkParentStudent(parentID, studentID int64) error {
+	_, err := DB.Exec(`DELETE FROM parent_students WHERE parent_id = ? AND student_id = ?`, parentID, studentID)
+	return err
+}
+
+// GetChildrenByParentID returns the students linked to a parent, ordered by name
+func GetChildrenByParentID(parentID int64) ([]models.ParentChild, error) {
+	rows, err := DB.Query(`
+		SELECT `+userColumns+`, COALESCE(ps.relationship, ''), ps.created_at
+		`+userFrom+`
+		JOIN parent_students ps ON ps.student_id = u.id
+		WHERE ps.parent_id = ?
+		ORDER BY u.name
+	`, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var children []models.ParentChild
+	for rows.Next() {
+		var child models.ParentChild
+		student, err := scanUser(withExtraColumns(rows, &child.Relationship, &child.LinkedAt))
+		if err != nil {
+			return nil, err
+		}
+		child.Student = *student
+		children = append(children, child)
+	}
+	return children, nil
+}
+
+// GetParentsByStudentID returns the parents linked to a student; a student's recovery code
+// is sent to them because students have no contact details of their own
+func GetParentsByStudentID(studentID int64) ([]models.User, error) {
+	rows, err := DB.Query(`
+		SELECT `+userColumns+`
+		`+userFrom+`
+		JOIN parent_students ps ON ps.parent_id = u.id
+		WHERE ps.student_id = ? AND u.status = ?
+		ORDER BY u.name
+	`, studentID, models.StatusActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parents []models.User
+	for rows.Next() {
+		parent, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, *parent)
+	}
+	return parents, rows.Err()
+}
+
+// IsParentOf reports whether a student is linked to a parent
+func IsParentOf(parentID, studentID int64) (bool, error) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM parent_students WHERE parent_id = ? AND student_id = ?
+	`, parentID, studentID).Scan(&count)
+	return count > 0, err
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
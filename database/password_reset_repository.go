@@ -0,0 +1,151 @@
+package database
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"komite-sekolah/config"
+)
+
+var (
+	ErrTooManyResetRequests = errors.New("Too many reset requests, try again later")
+	ErrInvalidResetCode     = errors.New("Invalid or expired code")
+)
+
+// hashResetCode binds a code to its user, so the same digits issued to two users hash differently
+func hashResetCode(userID int64, code string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", userID, code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateResetCode stores a new password recovery code for a user, replacing any earlier
+// unused one. Only OTPMaxRequests codes can be issued per OTPRequestWindow; past that
+// ErrTooManyResetRequests is returned.
+func CreateResetCode(userID int64, code string, expiresAt time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the user row so concurrent requests cannot both slip under the limit
+	var id int64
+	if err := tx.QueryRow(`SELECT id FROM users WHERE id = ? FOR UPDATE`, userID).Scan(&id); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var recent int64
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM password_reset_codes WHERE user_id = ? AND created_at > ?
+	`, userID, now.Add(-config.AppConfig.OTPRequestWindow)).Scan(&recent)
+	if err != nil {
+		return err
+	}
+	if recent >= config.AppConfig.OTPMaxRequests {
+		return ErrTooManyResetRequests
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE password_reset_codes SET used_at = ? WHERE user_id = ? AND used_at IS NULL
+	`, now, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO password_reset_codes (user_id, code_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?)
+	`, userID, hashResetCode(userID, code), expiresAt, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordResetRequest counts a recovery code request from a client IP, whichever account it
+// names. Past OTPIPMaxRequests per OTPRequestWindow ErrTooManyResetRequests is returned;
+// refused requests still count, so an IP that keeps trying stays refused.
+func RecordResetRequest(ip string) error {
+	now := time.Now()
+	since := now.Add(-config.AppConfig.OTPRequestWindow)
+	if _, err := DB.Exec(`DELETE FROM password_reset_requests WHERE ip = ? AND created_at <= ?`, ip, since); err != nil {
+		return err
+	}
+	if _, err := DB.Exec(`INSERT INTO password_reset_requests (ip, created_at) VALUES (?, ?)`, ip, now); err != nil {
+		return err
+	}
+
+	var recent int64
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM password_reset_requests WHERE ip = ? AND created_at > ?
+	`, ip, since).Scan(&recent)
+	if err != nil {
+		return err
+	}
+	if recent > config.AppConfig.OTPIPMaxRequests {
+		return ErrTooManyResetRequests
+	}
+	return nil
+}
+
+// ResetPasswordWithCode checks a recovery code and, if it matches, sets the user's new
+// password and ends every session. A code works once; after OTPMaxAttempts wrong guesses
+// it is discarded and a new one has to be requested.
+func ResetPasswordWithCode(userID int64, code, hashedPassword string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var id, attempts int64
+	var codeHash string
+	err = tx.QueryRow(`
+		SELECT id, code_hash, attempts FROM password_reset_codes
+		WHERE user_id = ? AND used_at IS NULL AND expires_at > ?
+		ORDER BY id DESC LIMIT 1
+		FOR UPDATE
+	`, userID, now).Scan(&id, &codeHash, &attempts)
+	if err == sql.ErrNoRows {
+		return ErrInvalidResetCode
+	}
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(codeHash), []byte(hashResetCode(userID, code))) != 1 {
+		attempts++
+		var usedAt interface{}
+		if attempts >= config.AppConfig.OTPMaxAttempts {
+			usedAt = now
+		}
+		if _, err := tx.Exec(`
+			UPDATE password_reset_codes SET attempts = ?, used_at = ? WHERE id = ?
+		`, attempts, usedAt, id); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return ErrInvalidResetCode
+	}
+
+	if _, err := tx.Exec(`UPDATE password_reset_codes SET used_at = ? WHERE id = ?`, now, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE users
+		SET password = ?, must_change_password = 0, updated_at = ?
+		WHERE id = ?
+	`, hashedPassword, now, userID); err != nil {
+		return err
+	}
+	if err := revokeUserSessions(tx, userID, 0); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
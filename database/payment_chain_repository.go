@@ -0,0 +1,267 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"komite-sekolah/models"
+)
+
+// genesisHash is the previous hash of a school's first payment event
+var genesisHash = strings.Repeat("0", 64)
+
+// chainedPayment is exactly what a payment event's hash covers. Changing its fields or their
+// order changes every hash, so existing chains would no longer verify.
+type chainedPayment struct {
+	PrevHash   string `json:"prev_hash"`
+	SchoolID   int64  `json:"school_id"`
+	PaymentID  int64  `json:"payment_id"`
+	Event      string `json:"event"`
+	UserID     int64  `json:"user_id"`
+	Tanggal    string `json:"tanggal"`
+	Nominal    int64  `json:"nominal"`
+	Keterangan string `json:"keterangan"`
+	BillID     *int64 `json:"bill_id"`
+	Metode     string `json:"metode"`
+	RecordedAt string `json:"recorded_at"`
+}
+
+func paymentEventHash(e *models.PaymentEvent) string {
+	b, _ := json.Marshal(chainedPayment{
+		PrevHash:   e.PrevHash,
+		SchoolID:   e.SchoolID,
+		PaymentID:  e.PaymentID,
+		Event:      e.Event,
+		UserID:     e.UserID,
+		Tanggal:    e.Tanggal,
+		Nominal:    e.Nominal,
+		Keterangan: e.Keterangan,
+		BillID:     e.BillID,
+		Metode:     e.Metode,
+		RecordedAt: e.RecordedAt,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// appendPaymentEvent links an event for a payment, as it is after the event, to the end of
+// the school's chain. The school row is locked so concurrent payments cannot fork the chain.
+func appendPaymentEvent(tx *sql.Tx, schoolID int64, event string, p *models.Payment) error {
+	var locked int64
+	if err := tx.QueryRow(`SELECT id FROM schools WHERE id = ? FOR UPDATE`, schoolID).Scan(&locked); err != nil {
+		return err
+	}
+
+	prevHash := genesisHash
+	err := tx.QueryRow(`
+		SELECT hash FROM payment_events WHERE school_id = ? ORDER BY id DESC LIMIT 1
+	`, schoolID).Scan(&prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	e := &models.PaymentEvent{
+		SchoolID:   schoolID,
+		PaymentID:  p.ID,
+		Event:      event,
+		UserID:     p.UserID,
+		Tanggal:    p.Tanggal,
+		Nominal:    p.Nominal,
+		Keterangan: p.Keterangan,
+		BillID:     p.BillID,
+		Metode:     p.Metode,
+		RecordedAt: time.Now().Format("2006-01-02 15:04:05"),
+		PrevHash:   prevHash,
+	}
+	e.Hash = paymentEventHash(e)
+
+	_, err = tx.Exec(`
+		INSERT INTO payment_events (school_id, payment_id, event, user_id, tanggal, nominal, keterangan, bill_id, metode, recorded_at, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.SchoolID, e.PaymentID, e.Event, e.UserID, e.Tanggal, e.Nominal, e.Keterangan, e.BillID, e.Metode, e.RecordedAt, e.PrevHash, e.Hash)
+	return err
+}
+
+// VerifyPaymentChain walks a school's payment chain from the first event, recomputing every
+// hash, then checks that each payment row still matches its last event. It stops at the
+// first broken link.
+func VerifyPaymentChain(schoolID int64) (*models.PaymentChainVerification, error) {
+	rows, err := DB.Query(`
+		SELECT id, school_id, payment_id, event, user_id, DATE_FORMAT(tanggal, '%Y-%m-%d'), nominal, keterangan,
+			bill_id, metode, DATE_FORMAT(recorded_at, '%Y-%m-%d %H:%i:%s'), prev_hash, hash
+		FROM payment_events WHERE school_id = ? ORDER BY id
+	`, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &models.PaymentChainVerification{}
+	latest := make(map[int64]models.PaymentEvent)
+	prevHash := genesisHash
+	for rows.Next() {
+		var e models.PaymentEvent
+		var billID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.SchoolID, &e.PaymentID, &e.Event, &e.UserID, &e.Tanggal, &e.Nominal, &e.Keterangan,
+			&billID, &e.Metode, &e.RecordedAt, &e.PrevHash, &e.Hash); err != nil {
+			return nil, err
+		}
+		e.BillID = nullInt64Ptr(billID)
+
+		switch {
+		case e.PrevHash != prevHash:
+			result.Broken = &models.PaymentChainBreak{EventID: e.ID, PaymentID: e.PaymentID, Reason: models.ChainPrevHashMismatch}
+		case paymentEventHash(&e) != e.Hash:
+			result.Broken = &models.PaymentChainBreak{EventID: e.ID, PaymentID: e.PaymentID, Reason: models.ChainHashMismatch}
+		}
+		if result.Broken != nil {
+			return result, nil
+		}
+
+		result.EventsChecked++
+		result.LastHash = e.Hash
+		prevHash = e.Hash
+		latest[e.PaymentID] = e
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	payments, err := DB.Query(`
+		SELECT id, user_id, DATE_FORMAT(tanggal, '%Y-%m-%d'), nominal, COALESCE(keterangan, ''), bill_id, metode
+		FROM payments WHERE school_id = ? ORDER BY id
+	`, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer payments.Close()
+
+	for payments.Next() {
+		var p models.Payment
+		var billID sql.NullInt64
+		if err := payments.Scan(&p.ID, &p.UserID, &p.Tanggal, &p.Nominal, &p.Keterangan, &billID, &p.Metode); err != nil {
+			return nil, err
+		}
+		p.BillID = nullInt64Ptr(billID)
+
+		e, ok := latest[p.ID]
+		delete(latest, p.ID)
+		switch {
+		case !ok:
+			result.Broken = &models.PaymentChainBreak{PaymentID: p.ID, Reason: models.ChainPaymentUnchained}
+		case e.Event == models.PaymentEventReverse || !paymentMatchesEvent(&p, &e):
+			result.Broken = &models.PaymentChainBreak{EventID: e.ID, PaymentID: p.ID, Reason: models.ChainPaymentChanged}
+		}
+		if result.Broken != nil {
+			return result, nil
+		}
+		result.PaymentsChecked++
+	}
+	if err := payments.Err(); err != nil {
+		return nil, err
+	}
+
+	// Whatever is left was chained as a live payment but is gone from the table
+	for _, e := range latest {
+		if e.Event == models.PaymentEventReverse {
+			continue
+		}
+		if result.Broken == nil || e.PaymentID < result.Broken.PaymentID {
+			result.Broken = &models.PaymentChainBreak{EventID: e.ID, PaymentID: e.PaymentID, Reason: models.ChainPaymentMissing}
+		}
+	}
+
+	result.Valid = result.Broken == nil
+	return result, nil
+}
+
+func paymentMatchesEvent(p *models.Payment, e *models.PaymentEvent) bool {
+	sameBill := (p.BillID == nil && e.BillID == nil) || (p.BillID != nil && e.BillID != nil && *p.BillID == *e.BillID)
+	return sameBill && p.UserID == e.UserID && p.Tanggal == e.Tanggal && p.Nominal == e.Nominal &&
+		p.Keterangan == e.Keterangan && p.Metode == e.Metode
+}
+
+// GetPaymentChainAnchors returns the chain hash at the end of every day between from and to
+// (inclusive) on which payment events were recorded
+func GetPaymentChainAnchors(schoolID int64, from, to string) ([]models.PaymentChainAnchor, error) {
+	rows, err := DB.Query(`
+		SELECT d.tanggal, d.events, d.last_id, e.hash
+		FROM (
+			SELECT DATE_FORMAT(recorded_at, '%Y-%m-%d') AS tanggal, COUNT(*) AS events, MAX(id) AS last_id
+			FROM payment_events
+			WHERE school_id = ? AND recorded_at >= ? AND recorded_at < DATE_ADD(?, INTERVAL 1 DAY)
+			GROUP BY tanggal
+		) d
+		JOIN payment_events e ON e.id = d.last_id
+		ORDER BY d.tanggal
+	`, schoolID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var anchors []models.PaymentChainAnchor
+	for rows.Next() {
+		var a models.PaymentChainAnchor
+		if err := rows.Scan(&a.Tanggal, &a.EventCount, &a.LastEventID, &a.Hash); err != nil {
+			return nil, err
+		}
+		anchors = append(anchors, a)
+	}
+	return anchors, rows.Err()
+}
+
+// backfillPaymentChain starts the chain with a create event for every existing payment. It
+// only runs while the chain is empty: afterwards a payment without events is exactly what
+// verification has to report, not something to adopt.
+func backfillPaymentChain() error {
+	var count int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM payment_events`).Scan(&count); err != nil || count > 0 {
+		return err
+	}
+
+	rows, err := DB.Query(`SELECT id, school_id FROM payments WHERE school_id IS NOT NULL ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	type ref struct{ id, schoolID int64 }
+	var refs []ref
+	for rows.Next() {
+		var r ref
+		if err := rows.Scan(&r.id, &r.schoolID); err != nil {
+			rows.Close()
+			return err
+		}
+		refs = append(refs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(refs) == 0 {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range refs {
+		payment, err := lockPayment(tx, r.schoolID, r.id)
+		if err != nil {
+			return err
+		}
+		if err := appendPaymentEvent(tx, r.schoolID, models.PaymentEventCreate, payment); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("Started the payment hash chain with %d existing payments", len(refs))
+	return nil
+}
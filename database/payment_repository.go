@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"log"
+	"strconv"
 	"strings"
 
 	"komite-sekolah/models"
@@ -13,30 +14,76 @@ var (
 	ErrPaymentNotFound = errors.New("Payment not found")
 )
 
-// CreatePayment creates a new payment record
-func CreatePayment(req models.CreatePaymentRequest) (*models.Payment, error) {
-	result, err := DB.Exec(`
-		INSERT INTO payments (user_id, tanggal, nominal, keterangan)
-		VALUES (?, ?, ?, ?)
-	`, req.UserID, req.Tanggal, req.Nominal, req.Keterangan)
+// CreatePayment creates a new payment record in a school and posts it to the school's ledger
+func CreatePayment(schoolID int64, req models.CreatePaymentRequest) (*models.Payment, error) {
+	if req.Metode == "" {
+		req.Metode = models.MetodeTunai
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO payments (user_id, tanggal, nominal, keterangan, bill_id, metode, school_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, req.UserID, req.Tanggal, req.Nominal, req.Keterangan, req.BillID, req.Metode, schoolID)
 
 	if err != nil {
 		return nil, err
 	}
 
 	id, _ := result.LastInsertId()
-	return GetPaymentByID(id)
+	err = postPaymentJournal(tx, schoolID, &models.Payment{ID: id, Tanggal: req.Tanggal, Nominal: req.Nominal, Metode: req.Metode})
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := lockPayment(tx, schoolID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := appendPaymentEvent(tx, schoolID, models.PaymentEventCreate, created); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetPaymentByID(schoolID, id)
 }
 
-// GetPaymentByID retrieves a payment by ID
-func GetPaymentByID(id int64) (*models.Payment, error) {
+// lockPayment reads the ledger and hash chain fields of a school's payment inside tx and locks the row
+func lockPayment(tx *sql.Tx, schoolID, id int64) (*models.Payment, error) {
 	payment := &models.Payment{}
+	var billID sql.NullInt64
+	err := tx.QueryRow(`
+		SELECT id, user_id, DATE_FORMAT(tanggal, '%Y-%m-%d'), nominal, COALESCE(keterangan, ''), bill_id, metode
+		FROM payments WHERE id = ? AND school_id = ? FOR UPDATE
+	`, id, schoolID).Scan(&payment.ID, &payment.UserID, &payment.Tanggal, &payment.Nominal, &payment.Keterangan, &billID, &payment.Metode)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	payment.BillID = nullInt64Ptr(billID)
+	return payment, nil
+}
+
+// GetPaymentByID retrieves a payment of a school by ID
+func GetPaymentByID(schoolID, id int64) (*models.Payment, error) {
+	payment := &models.Payment{}
+	var billID sql.NullInt64
 	err := DB.QueryRow(`
-		SELECT id, user_id, tanggal, nominal, COALESCE(keterangan, ''), created_at, updated_at
-		FROM payments WHERE id = ?
-	`, id).Scan(
+		SELECT id, user_id, tanggal, nominal, COALESCE(keterangan, ''), bill_id, metode, created_at, updated_at
+		FROM payments WHERE id = ? AND school_id = ?
+	`, id, schoolID).Scan(
 		&payment.ID, &payment.UserID, &payment.Tanggal, &payment.Nominal,
-		&payment.Keterangan,
+		&payment.Keterangan, &billID, &payment.Metode,
 		&payment.CreatedAt, &payment.UpdatedAt,
 	)
 
@@ -46,13 +93,14 @@ func GetPaymentByID(id int64) (*models.Payment, error) {
 	if err != nil {
 		return nil, err
 	}
+	payment.BillID = nullInt64Ptr(billID)
 	return payment, nil
 }
 
 // GetPaymentsByUserID retrieves all payments for a specific user
 func GetPaymentsByUserID(userID int64) ([]models.Payment, error) {
 	rows, err := DB.Query(`
-		SELECT p.id, p.user_id, p.tanggal, p.nominal, COALESCE(p.keterangan, ''), p.created_at, p.updated_at
+		SELECT p.id, p.user_id, p.tanggal, p.nominal, COALESCE(p.keterangan, ''), p.bill_id, p.metode, p.created_at, p.updated_at
 		FROM payments p
 		WHERE p.user_id = ?
 		ORDER BY p.tanggal DESC, p.created_at DESC
@@ -65,67 +113,139 @@ func GetPaymentsByUserID(userID int64) ([]models.Payment, error) {
 	var payments []models.Payment
 	for rows.Next() {
 		var payment models.Payment
+		var billID sql.NullInt64
 		err := rows.Scan(
 			&payment.ID, &payment.UserID, &payment.Tanggal, &payment.Nominal,
-			&payment.Keterangan,
+			&payment.Keterangan, &billID, &payment.Metode,
 			&payment.CreatedAt, &payment.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
+		payment.BillID = nullInt64Ptr(billID)
 		payments = append(payments, payment)
 	}
 	return payments, nil
 }
 
-// GetPaymentsByUserIDWithUser retrieves all payments for a user with user info
-func GetPaymentsByUserIDWithUser(userID int64) ([]models.Payment, error) {
-	rows, err := DB.Query(`
-		SELECT p.id, p.user_id, p.tanggal, p.nominal, COALESCE(p.keterangan, ''), p.created_at, p.updated_at,
-			   u.id, COALESCE(u.username, ''), COALESCE(u.nis, ''), u.name, u.role
+// paymentSortColumns are the columns ListPayments can order by. Each must be a payments
+// column so the keyset cursor can look it up by payment ID.
+var paymentSortColumns = map[string]string{
+	"tanggal":    "tanggal",
+	"nominal":    "nominal",
+	"created_at": "created_at",
+}
+
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// ListPayments returns one page of a school's payments matching the filter, joined with the
+// paying user, together with totals over every matching payment.
+//
+// Pagination is keyset-based: the cursor is the ID of the last payment on the previous
+// page, and the next page starts strictly after that row in sort order, so payments
+// inserted meanwhile never shift rows between pages.
+func ListPayments(schoolID int64, filter models.PaymentFilter) ([]models.Payment, *models.PaymentListMeta, error) {
+	where := []string{"p.school_id = ?"}
+	args := []interface{}{schoolID}
+	if filter.From != "" {
+		where = append(where, "p.tanggal >= ?")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		where = append(where, "p.tanggal <= ?")
+		args = append(args, filter.To)
+	}
+	if filter.MinNominal > 0 {
+		where = append(where, "p.nominal >= ?")
+		args = append(args, filter.MinNominal)
+	}
+	if filter.MaxNominal > 0 {
+		where = append(where, "p.nominal <= ?")
+		args = append(args, filter.MaxNominal)
+	}
+	if filter.UserID != 0 {
+		where = append(where, "p.user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.ClassID != 0 {
+		where = append(where, "u.class_id = ?")
+		args = append(args, filter.ClassID)
+	}
+	if filter.FeeCategoryID != 0 {
+		where = append(where, "b.fee_category_id = ?")
+		args = append(args, filter.FeeCategoryID)
+	}
+	if filter.Metode != "" {
+		where = append(where, "p.metode = ?")
+		args = append(args, filter.Metode)
+	}
+	if filter.Search != "" {
+		where = append(where, "p.keterangan LIKE ?")
+		args = append(args, "%"+escapeLike(filter.Search)+"%")
+	}
+
+	from := `
 		FROM payments p
 		JOIN users u ON p.user_id = u.id
-		WHERE p.user_id = ?
-		ORDER BY p.tanggal DESC, p.created_at DESC
-	`, userID)
+		LEFT JOIN bills b ON b.id = p.bill_id`
+	whereSQL := " WHERE " + strings.Join(where, " AND ")
+
+	meta := &models.PaymentListMeta{Limit: filter.Limit}
+	err := DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(p.nominal), 0),
+			   COALESCE(SUM(CASE WHEN p.metode = ? THEN p.nominal ELSE 0 END), 0),
+			   COALESCE(SUM(CASE WHEN p.metode = ? THEN p.nominal ELSE 0 END), 0)
+	`+from+whereSQL, append([]interface{}{models.MetodeTunai, models.MetodeTransfer}, args...)...).Scan(
+		&meta.Total, &meta.TotalNominal, &meta.TotalTunai, &meta.TotalTransfer,
+	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer rows.Close()
 
-	var payments []models.Payment
-	count := 0
-	for rows.Next() {
-		var payment models.Payment
-		var user models.User
-		err := rows.Scan(
-			&payment.ID, &payment.UserID, &payment.Tanggal, &payment.Nominal,
-			&payment.Keterangan,
-			&payment.CreatedAt, &payment.UpdatedAt,
-			&user.ID, &user.Username, &user.NIS, &user.Name, &user.Role,
-		)
+	sortField, direction, comparison := "tanggal", "DESC", "<"
+	if filter.Sort != "" {
+		sortField = filter.Sort
+		if strings.HasPrefix(sortField, "-") {
+			sortField = sortField[1:]
+		} else {
+			direction, comparison = "ASC", ">"
+		}
+	}
+	column, ok := paymentSortColumns[sortField]
+	if !ok {
+		return nil, nil, ErrInvalidSort
+	}
+
+	if filter.Cursor != "" {
+		cursorID, err := strconv.ParseInt(filter.Cursor, 10, 64)
 		if err != nil {
-			return nil, err
+			return nil, nil, ErrInvalidCursor
 		}
-		payment.User = &user
-		payments = append(payments, payment)
-		count++
+		// The cursor must be one of the school's payments, or the page would silently be empty
+		var exists bool
+		err = DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM payments WHERE id = ? AND school_id = ?)`, cursorID, schoolID).Scan(&exists)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !exists {
+			return nil, nil, ErrInvalidCursor
+		}
+		whereSQL += " AND (p." + column + ", p.id) " + comparison + " (SELECT " + column + ", id FROM payments WHERE id = ? AND school_id = ?)"
+		args = append(args, cursorID, schoolID)
 	}
-	log.Printf("GetPaymentsByUserIDWithUser: userID=%d returns %d payments", userID, count)
-	return payments, nil
-}
 
-// GetAllPayments retrieves all payments (admin only)
-func GetAllPayments() ([]models.Payment, error) {
-	rows, err := DB.Query(`
-		SELECT p.id, p.user_id, p.tanggal, p.nominal, COALESCE(p.keterangan, ''), p.created_at, p.updated_at,
+	// Fetch one extra row to know whether another page follows
+	query := `
+		SELECT p.id, p.user_id, p.tanggal, p.nominal, COALESCE(p.keterangan, ''), p.bill_id, p.metode, p.created_at, p.updated_at,
 			   u.id, COALESCE(u.username, ''), COALESCE(u.nis, ''), u.name, u.role
-		FROM payments p
-		JOIN users u ON p.user_id = u.id
-		ORDER BY p.tanggal DESC, p.created_at DESC
-	`)
+	` + from + whereSQL + `
+		ORDER BY p.` + column + ` ` + direction + `, p.id ` + direction + `
+		LIMIT ?`
+	args = append(args, filter.Limit+1)
+
+	rows, err := DB.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
@@ -133,29 +253,57 @@ func GetAllPayments() ([]models.Payment, error) {
 	for rows.Next() {
 		var payment models.Payment
 		var user models.User
+		var billID sql.NullInt64
 		err := rows.Scan(
 			&payment.ID, &payment.UserID, &payment.Tanggal, &payment.Nominal,
-			&payment.Keterangan,
+			&payment.Keterangan, &billID, &payment.Metode,
 			&payment.CreatedAt, &payment.UpdatedAt,
 			&user.ID, &user.Username, &user.NIS, &user.Name, &user.Role,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		payment.BillID = nullInt64Ptr(billID)
 		payment.User = &user
 		payments = append(payments, payment)
 	}
-	return payments, nil
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(payments) > filter.Limit {
+		payments = payments[:filter.Limit]
+		meta.NextCursor = strconv.FormatInt(payments[len(payments)-1].ID, 10)
+	}
+	return payments, meta, nil
 }
 
-// DeletePayment deletes a payment record
-func DeletePayment(paymentID int64) error {
-	_, err := DB.Exec(`DELETE FROM payments WHERE id = ?`, paymentID)
-	return err
+// DeletePayment deletes a school's payment record and reverses its ledger entry
+func DeletePayment(schoolID, paymentID int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	payment, err := lockPayment(tx, schoolID, paymentID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM payments WHERE id = ? AND school_id = ?`, paymentID, schoolID); err != nil {
+		return err
+	}
+	if err := reversePaymentJournal(tx, schoolID, payment, payment.Tanggal); err != nil {
+		return err
+	}
+	if err := appendPaymentEvent(tx, schoolID, models.PaymentEventReverse, payment); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// UpdatePayment updates a payment record
-func UpdatePayment(paymentID int64, req models.UpdatePaymentRequest) (*models.Payment, error) {
+// UpdatePayment updates a school's payment record
+func UpdatePayment(schoolID, paymentID int64, req models.UpdatePaymentRequest) (*models.Payment, error) {
 	var sets []string
 	var args []interface{}
 
@@ -171,21 +319,61 @@ func UpdatePayment(paymentID int64, req models.UpdatePaymentRequest) (*models.Pa
 		sets = append(sets, "keterangan = ?")
 		args = append(args, *req.Keterangan)
 	}
+	if req.BillID != nil {
+		sets = append(sets, "bill_id = ?")
+		args = append(args, *req.BillID)
+	}
+	if req.Metode != nil {
+		sets = append(sets, "metode = ?")
+		args = append(args, *req.Metode)
+	}
 
 	if len(sets) == 0 {
 		return nil, errors.New("No fields to update")
 	}
 
-	query := "UPDATE payments SET " + strings.Join(sets, ", ") + ", updated_at = CURRENT_TIMESTAMP WHERE id = ?"
-	args = append(args, paymentID)
+	query := "UPDATE payments SET " + strings.Join(sets, ", ") + ", updated_at = CURRENT_TIMESTAMP WHERE id = ? AND school_id = ?"
+	args = append(args, paymentID, schoolID)
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockPayment(tx, schoolID, paymentID)
+	if err != nil {
+		return nil, err
+	}
 
 	log.Printf("UpdatePayment query=%q args=%v", query, args)
-	_, err := DB.Exec(query, args...)
+	_, err = tx.Exec(query, args...)
 	if err != nil {
 		log.Printf("UpdatePayment: exec error: %v", err)
 		return nil, err
 	}
-	return GetPaymentByID(paymentID)
+
+	// Re-post the ledger entry only when an amount, date or method changed
+	after, err := lockPayment(tx, schoolID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if after.Tanggal != before.Tanggal || after.Nominal != before.Nominal || after.Metode != before.Metode {
+		if err := reversePaymentJournal(tx, schoolID, before, before.Tanggal); err != nil {
+			return nil, err
+		}
+		if err := postPaymentJournal(tx, schoolID, after); err != nil {
+			return nil, err
+		}
+	}
+	if err := appendPaymentEvent(tx, schoolID, models.PaymentEventUpdate, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetPaymentByID(schoolID, paymentID)
 }
 
 // GetPaymentSummaryByUserID calculates payment summary for a user
@@ -222,3 +410,10 @@ func GetTotalPaymentsByUserID(userID int64) (int64, error) {
 	return total, err
 }
 
+
+func nullInt64Ptr(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
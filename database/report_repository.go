@@ -0,0 +1,235 @@
+package database
+
+import (
+	"database/sql"
+	"math"
+
+	"komite-sekolah/models"
+)
+
+// GetCollectionPeriod sums a school's payments made and bills falling due between from and to
+// (inclusive, YYYY-MM-DD)
+func GetCollectionPeriod(schoolID int64, from, to string) (*models.CollectionPeriod, error) {
+	period := &models.CollectionPeriod{DariTanggal: from, SampaiTanggal: to}
+
+	err := DB.QueryRow(`
+		SELECT COALESCE(SUM(nominal), 0), COUNT(*)
+		FROM payments
+		WHERE school_id = ? AND tanggal BETWEEN ? AND ?
+	`, schoolID, from, to).Scan(&period.Terkumpul, &period.JumlahTransaksi)
+	if err != nil {
+		return nil, err
+	}
+
+	err = DB.QueryRow(`
+		SELECT COALESCE(SUM(nominal), 0)
+		FROM bills
+		WHERE school_id = ? AND jatuh_tempo BETWEEN ? AND ?
+	`, schoolID, from, to).Scan(&period.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	period.PersentaseTagih = percentOf(period.Terkumpul, period.Target)
+	return period, nil
+}
+
+// GetCollectionByClass compares payments made with bills due between from and to for every class
+// of a school in its active academic year, plus classes not tied to any year
+func GetCollectionByClass(schoolID int64, from, to string) ([]models.CollectionBreakdown, error) {
+	rows, err := DB.Query(`
+		SELECT c.id, c.name,
+			   COALESCE((SELECT SUM(p.nominal) FROM payments p JOIN users u ON u.id = p.user_id
+						 WHERE u.class_id = c.id AND p.tanggal BETWEEN ? AND ?), 0),
+			   COALESCE((SELECT SUM(b.nominal) FROM bills b JOIN users u ON u.id = b.user_id
+						 WHERE u.class_id = c.id AND b.jatuh_tempo BETWEEN ? AND ?), 0)
+		FROM classes c
+		LEFT JOIN academic_years ay ON ay.id = c.academic_year_id
+		WHERE c.school_id = ? AND (c.academic_year_id IS NULL OR ay.is_active = 1)
+		ORDER BY c.grade_level, c.name
+	`, from, to, from, to, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCollectionBreakdown(rows)
+}
+
+// GetCollectionByFeeCategory compares bill-linked payments with bills due between from and to for every
+// fee category of a school
+func GetCollectionByFeeCategory(schoolID int64, from, to string) ([]models.CollectionBreakdown, error) {
+	rows, err := DB.Query(`
+		SELECT fc.id, fc.name,
+			   COALESCE((SELECT SUM(p.nominal) FROM payments p JOIN bills b ON b.id = p.bill_id
+						 WHERE b.fee_category_id = fc.id AND p.tanggal BETWEEN ? AND ?), 0),
+			   COALESCE((SELECT SUM(b.nominal) FROM bills b
+						 WHERE b.fee_category_id = fc.id AND b.jatuh_tempo BETWEEN ? AND ?), 0)
+		FROM fee_categories fc
+		WHERE fc.school_id = ?
+		ORDER BY fc.name
+	`, from, to, from, to, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCollectionBreakdown(rows)
+}
+
+func scanCollectionBreakdown(rows *sql.Rows) ([]models.CollectionBreakdown, error) {
+	var breakdown []models.CollectionBreakdown
+	for rows.Next() {
+		var item models.CollectionBreakdown
+		if err := rows.Scan(&item.ID, &item.Name, &item.Terkumpul, &item.Target); err != nil {
+			return nil, err
+		}
+		item.PersentaseTagih = percentOf(item.Terkumpul, item.Target)
+		breakdown = append(breakdown, item)
+	}
+	return breakdown, nil
+}
+
+// GetTopArrears returns a school's students with the largest unpaid amount on bills due on or
+// before asOf. Payments count towards the bill they are linked to, as in GetOpenBillBalances,
+// so a student's arrears here match the past-due balance of the aging report.
+func GetTopArrears(schoolID int64, asOf string, limit int) ([]models.ArrearsEntry, error) {
+	rows, err := DB.Query(`
+		SELECT u.id, COALESCE(u.nis, ''), u.name, COALESCE(c.name, ''), t.tagihan, t.terbayar, t.tagihan - t.terbayar AS tunggakan
+		FROM (
+			SELECT pb.user_id, SUM(pb.nominal) AS tagihan, SUM(LEAST(pb.nominal, pb.dibayar)) AS terbayar
+			FROM (
+				SELECT b.user_id, b.nominal,
+					   COALESCE((SELECT SUM(p.nominal) FROM payments p WHERE p.bill_id = b.id AND p.tanggal <= ?), 0) AS dibayar
+				FROM bills b
+				WHERE b.school_id = ? AND b.jatuh_tempo <= ? AND b.created_at < DATE_ADD(?, INTERVAL 1 DAY)
+			) pb
+			GROUP BY pb.user_id
+		) t
+		JOIN users u ON u.id = t.user_id
+		LEFT JOIN enrollments e ON e.id = (
+			SELECT e2.id FROM enrollments e2
+			WHERE e2.user_id = t.user_id AND e2.start_date <= ? AND (e2.end_date IS NULL OR e2.end_date >= ?)
+			ORDER BY e2.start_date DESC, e2.id DESC
+			LIMIT 1
+		)
+		LEFT JOIN classes c ON c.id = e.class_id
+		WHERE t.tagihan > t.terbayar
+		ORDER BY tunggakan DESC
+		LIMIT ?
+	`, asOf, schoolID, asOf, asOf, asOf, asOf, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.ArrearsEntry
+	for rows.Next() {
+		var entry models.ArrearsEntry
+		err := rows.Scan(
+			&entry.UserID, &entry.NIS, &entry.Name, &entry.ClassName,
+			&entry.Tagihan, &entry.Terbayar, &entry.Tunggakan,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// GetDailyTrend returns a school's payment totals per day between from and to. Days without payments are omitted.
+func GetDailyTrend(schoolID int64, from, to string) ([]models.TrendPoint, error) {
+	return getTrend(`DATE_FORMAT(tanggal, '%Y-%m-%d')`, schoolID, from, to)
+}
+
+// GetMonthlyTrend returns a school's payment totals per month between from and to. Months without payments are omitted.
+func GetMonthlyTrend(schoolID int64, from, to string) ([]models.TrendPoint, error) {
+	return getTrend(`DATE_FORMAT(tanggal, '%Y-%m')`, schoolID, from, to)
+}
+
+func getTrend(bucket string, schoolID int64, from, to string) ([]models.TrendPoint, error) {
+	rows, err := DB.Query(`
+		SELECT `+bucket+` AS periode, COALESCE(SUM(nominal), 0), COUNT(*)
+		FROM payments
+		WHERE school_id = ? AND tanggal BETWEEN ? AND ?
+		GROUP BY periode
+		ORDER BY periode
+	`, schoolID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []models.TrendPoint
+	for rows.Next() {
+		var point models.TrendPoint
+		if err := rows.Scan(&point.Periode, &point.Terkumpul, &point.JumlahTransaksi); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// percentOf returns part as a percentage of whole, rounded to two decimals
+func percentOf(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*10000) / 100
+}
+
+// GetOpenBillBalances returns every bill of a school that existed on asOf and still had an unpaid
+// balance on that date, counting only payments dated on or before asOf. Students are placed in
+// the class they were enrolled in on asOf, not their current one, so past reports can be
+// reproduced. classID and feeCategoryID narrow the result when non-zero.
+func GetOpenBillBalances(schoolID int64, asOf string, classID, feeCategoryID int64) ([]models.OpenBillBalance, error) {
+	query := `
+		SELECT b.id, b.user_id, COALESCE(u.nis, ''), u.name, COALESCE(e.class_id, 0), COALESCE(c.name, ''),
+			   b.fee_category_id, fc.name,
+			   b.nominal - COALESCE((SELECT SUM(p.nominal) FROM payments p WHERE p.bill_id = b.id AND p.tanggal <= ?), 0) AS sisa,
+			   DATEDIFF(?, b.jatuh_tempo)
+		FROM bills b
+		JOIN users u ON u.id = b.user_id
+		LEFT JOIN enrollments e ON e.id = (
+			SELECT e2.id FROM enrollments e2
+			WHERE e2.user_id = b.user_id AND e2.start_date <= ? AND (e2.end_date IS NULL OR e2.end_date >= ?)
+			ORDER BY e2.start_date DESC, e2.id DESC
+			LIMIT 1
+		)
+		LEFT JOIN classes c ON c.id = e.class_id
+		JOIN fee_categories fc ON fc.id = b.fee_category_id
+		WHERE b.school_id = ? AND b.created_at < DATE_ADD(?, INTERVAL 1 DAY)`
+	args := []interface{}{asOf, asOf, asOf, asOf, schoolID, asOf}
+
+	if classID != 0 {
+		query += " AND e.class_id = ?"
+		args = append(args, classID)
+	}
+	if feeCategoryID != 0 {
+		query += " AND b.fee_category_id = ?"
+		args = append(args, feeCategoryID)
+	}
+	query += " HAVING sisa > 0 ORDER BY b.jatuh_tempo, b.id"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []models.OpenBillBalance
+	for rows.Next() {
+		var b models.OpenBillBalance
+		err := rows.Scan(
+			&b.BillID, &b.UserID, &b.NIS, &b.StudentName, &b.ClassID, &b.ClassName,
+			&b.FeeCategoryID, &b.FeeCategoryName, &b.Sisa, &b.DaysPastDue,
+		)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+	return balances, nil
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"komite-sekolah/config"
+	"komite-sekolah/models"
+)
+
+var (
+	ErrSchoolNotFound = errors.New("School not found")
+	ErrSchoolExists   = errors.New("School code is already taken")
+)
+
+// tenantTables are the tables whose rows belong to one school. Child tables such as
+// enrollments, journal_lines and expense_approvals are scoped through their parent row.
+var tenantTables = []string{
+	"users", "academic_years", "classes", "fee_categories", "bills", "payments", "accounts",
+	"journal_entries", "refunds", "budget_lines", "expense_requests", "transparency_reports",
+}
+
+// backfillSchools assigns rows created before multi-school support to the default school,
+// creating it on first run. Foundation admins are the only users without a school.
+func backfillSchools() error {
+	schoolID, err := defaultSchoolID()
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tenantTables {
+		query := fmt.Sprintf("UPDATE %s SET school_id = ? WHERE school_id IS NULL", table)
+		if table == "users" {
+			query += " AND role <> '" + string(models.RoleFoundationAdmin) + "'"
+		}
+		if _, err := DB.Exec(query, schoolID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultSchoolID returns the ID of the school configured by DEFAULT_SCHOOL_CODE, creating it if needed
+func defaultSchoolID() (int64, error) {
+	cfg := config.AppConfig
+	_, err := DB.Exec(`INSERT IGNORE INTO schools (code, name) VALUES (?, ?)`, cfg.DefaultSchoolCode, cfg.DefaultSchoolName)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = DB.QueryRow(`SELECT id FROM schools WHERE code = ?`, cfg.DefaultSchoolCode).Scan(&id)
+	return id, err
+}
+
+// CreateSchool registers a new school
+func CreateSchool(req models.CreateSchoolRequest) (*models.School, error) {
+	result, err := DB.Exec(`INSERT INTO schools (code, name) VALUES (?, ?)`, req.Code, req.Name)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, ErrSchoolExists
+		}
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	if err := seedSchoolAccounts(id); err != nil {
+		return nil, err
+	}
+	return GetSchoolByID(id)
+}
+
+func scanSchool(row rowScanner) (*models.School, error) {
+	school := &models.School{}
+	err := row.Scan(&school.ID, &school.Code, &school.Name, &school.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrSchoolNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return school, nil
+}
+
+// GetSchoolByID retrieves a school by ID
+func GetSchoolByID(id int64) (*models.School, error) {
+	return scanSchool(DB.QueryRow(`SELECT id, code, name, created_at FROM schools WHERE id = ?`, id))
+}
+
+// GetSchoolByCode retrieves a school by its login code
+func GetSchoolByCode(code string) (*models.School, error) {
+	return scanSchool(DB.QueryRow(`SELECT id, code, name, created_at FROM schools WHERE code = ?`, code))
+}
+
+// GetAllSchools retrieves every school ordered by name
+func GetAllSchools() ([]models.School, error) {
+	rows, err := DB.Query(`SELECT id, code, name, created_at FROM schools ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schools []models.School
+	for rows.Next() {
+		school, err := scanSchool(rows)
+		if err != nil {
+			return nil, err
+		}
+		schools = append(schools, *school)
+	}
+	return schools, nil
+}
+
+// CreateFoundationAdmin creates a foundation admin account. It belongs to no school and
+// must change its password on first login.
+func CreateFoundationAdmin(username, name, hashedPassword string) (*models.User, error) {
+	result, err := DB.Exec(`
+		INSERT INTO users (username, name, password, role, must_change_password)
+		VALUES (?, ?, ?, ?, ?)
+	`, username, name, hashedPassword, models.RoleFoundationAdmin, 1)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, ErrDuplicateUser
+		}
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetUserByID(id)
+}
+
+// GetFoundationCollections compares payments made with bills due between from and to for
+// every school, with the outstanding arrears of each school as of to
+func GetFoundationCollections(from, to string) ([]models.SchoolCollection, error) {
+	rows, err := DB.Query(`
+		SELECT s.id, s.code, s.name,
+			   (SELECT COUNT(*) FROM users u
+				WHERE u.school_id = s.id AND u.role = ? AND u.status = ?),
+			   COALESCE((SELECT SUM(p.nominal) FROM payments p
+						 WHERE p.school_id = s.id AND p.tanggal BETWEEN ? AND ?), 0),
+			   COALESCE((SELECT SUM(b.nominal) FROM bills b
+						 WHERE b.school_id = s.id AND b.jatuh_tempo BETWEEN ? AND ?), 0)
+		FROM schools s
+		ORDER BY s.name
+	`, models.RoleStudent, models.StatusActive, from, to, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schools []models.SchoolCollection
+	for rows.Next() {
+		var s models.SchoolCollection
+		if err := rows.Scan(&s.SchoolID, &s.Code, &s.Name, &s.SiswaAktif, &s.Terkumpul, &s.Target); err != nil {
+			return nil, err
+		}
+		s.PersentaseTagih = percentOf(s.Terkumpul, s.Target)
+		schools = append(schools, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	arrears, err := getArrearsBySchool(to)
+	if err != nil {
+		return nil, err
+	}
+	for i := range schools {
+		schools[i].Tunggakan = arrears[schools[i].SchoolID]
+	}
+	return schools, nil
+}
+
+// getArrearsBySchool sums, per school, what students still owe on bills due on or before
+// asOf, counting payments up to asOf. Students who paid ahead do not offset others.
+func getArrearsBySchool(asOf string) (map[int64]int64, error) {
+	rows, err := DB.Query(`
+		SELECT t.school_id, SUM(t.tagihan - t.terbayar)
+		FROM (
+			SELECT b.school_id, b.user_id,
+				   SUM(b.nominal) AS tagihan,
+				   COALESCE((SELECT SUM(p.nominal) FROM payments p WHERE p.user_id = b.user_id AND p.tanggal <= ?), 0) AS terbayar
+			FROM bills b
+			WHERE b.jatuh_tempo <= ?
+			GROUP BY b.school_id, b.user_id
+		) t
+		WHERE t.tagihan > t.terbayar
+		GROUP BY t.school_id
+	`, asOf, asOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	arrears := make(map[int64]int64)
+	for rows.Next() {
+		var schoolID, amount int64
+		if err := rows.Scan(&schoolID, &amount); err != nil {
+			return nil, err
+		}
+		arrears[schoolID] = amount
+	}
+	return arrears, nil
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("Invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("Refresh token has already been used")
+)
+
+// hashRefreshToken returns the hex SHA-256 of a refresh token. Only hashes are stored so a
+// leaked sessions table cannot be used to log in.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession starts a login session for a user with the given refresh token and returns
+// its ID. Expired and revoked sessions of the user are cleaned up on the way.
+func CreateSession(userID int64, refreshToken string, expiresAt time.Time) (int64, error) {
+	_, err := DB.Exec(`
+		DELETE FROM sessions WHERE user_id = ? AND (expires_at < ? OR revoked_at IS NOT NULL)
+	`, userID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := DB.Exec(`
+		INSERT INTO sessions (user_id, refresh_token_hash, expires_at)
+		VALUES (?, ?, ?)
+	`, userID, hashRefreshToken(refreshToken), expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// SessionActive reports whether a session of the user exists and is neither revoked nor expired
+func SessionActive(sessionID, userID int64) (bool, error) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM sessions
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?
+	`, sessionID, userID, time.Now()).Scan(&count)
+	return count > 0, err
+}
+
+// RotateSession exchanges a refresh token for a new one and returns the session ID and user ID.
+// Presenting the token that was replaced by the last rotation means it was copied, so the
+// whole session is revoked and ErrRefreshTokenReused returned.
+func RotateSession(refreshToken, newRefreshToken string, expiresAt time.Time) (int64, int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	hash := hashRefreshToken(refreshToken)
+	var sessionID, userID int64
+	var currentHash string
+	var sessionExpires time.Time
+	var revokedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT id, user_id, refresh_token_hash, expires_at, revoked_at
+		FROM sessions
+		WHERE refresh_token_hash = ? OR previous_token_hash = ?
+		FOR UPDATE
+	`, hash, hash).Scan(&sessionID, &userID, &currentHash, &sessionExpires, &revokedAt)
+	if err == sql.ErrNoRows {
+		return 0, 0, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if revokedAt.Valid || sessionExpires.Before(time.Now()) {
+		return 0, 0, ErrInvalidRefreshToken
+	}
+
+	if currentHash != hash {
+		if _, err := tx.Exec(`UPDATE sessions SET revoked_at = ? WHERE id = ?`, time.Now(), sessionID); err != nil {
+			return 0, 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, 0, err
+		}
+		return 0, 0, ErrRefreshTokenReused
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sessions
+		SET previous_token_hash = refresh_token_hash, refresh_token_hash = ?, expires_at = ?, last_used_at = ?
+		WHERE id = ?
+	`, hashRefreshToken(newRefreshToken), expiresAt, time.Now(), sessionID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return sessionID, userID, nil
+}
+
+// RevokeSession ends one session of a user, e.g. on logout
+func RevokeSession(sessionID, userID int64) error {
+	_, err := DB.Exec(`
+		UPDATE sessions SET revoked_at = ?
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, time.Now(), sessionID, userID)
+	return err
+}
+
+// RevokeUserSessions ends every session of a user except keepSessionID, which may be 0
+func RevokeUserSessions(userID, keepSessionID int64) error {
+	return revokeUserSessions(DB, userID, keepSessionID)
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func revokeUserSessions(db execer, userID, keepSessionID int64) error {
+	_, err := db.Exec(`
+		UPDATE sessions SET revoked_at = ?
+		WHERE user_id = ? AND id <> ? AND revoked_at IS NULL
+	`, time.Now(), userID, keepSessionID)
+	return err
+}
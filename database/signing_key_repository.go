@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"komite-sekolah/models"
+)
+
+// signingKeyLock is the MySQL named lock held while deciding whether a new signing key is
+// needed, so instances sharing the database do not all create one at the same time
+const signingKeyLock = "komite_sekolah_signing_keys"
+
+// GetSigningKeys returns every signing key that has not expired, oldest first
+func GetSigningKeys() ([]models.SigningKey, error) {
+	rows, err := DB.Query(`
+		SELECT kid, algorithm, private_key, public_key, not_before, not_after, expires_at, created_at
+		FROM signing_keys
+		WHERE expires_at > ?
+		ORDER BY not_before, created_at
+	`, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.SigningKey
+	for rows.Next() {
+		var k models.SigningKey
+		if err := rows.Scan(&k.KID, &k.Algorithm, &k.PrivateKey, &k.PublicKey, &k.NotBefore, &k.NotAfter, &k.ExpiresAt, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// CreateSigningKey stores a new signing key
+func CreateSigningKey(key models.SigningKey) error {
+	_, err := DB.Exec(`
+		INSERT INTO signing_keys (kid, algorithm, private_key, public_key, not_before, not_after, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, key.KID, key.Algorithm, key.PrivateKey, key.PublicKey, key.NotBefore, key.NotAfter, key.ExpiresAt)
+	return err
+}
+
+// DeleteExpiredSigningKeys removes keys no token signed with can still be valid
+func DeleteExpiredSigningKeys() error {
+	_, err := DB.Exec(`DELETE FROM signing_keys WHERE expires_at <= ?`, time.Now())
+	return err
+}
+
+// LockSigningKeys takes the signing key lock, waiting up to timeout, and returns the
+// function that releases it
+func LockSigningKeys(timeout time.Duration) (func(), error) {
+	ctx := context.Background()
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var got int
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, signingKeyLock, int(timeout.Seconds())).Scan(&got); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if got != 1 {
+		conn.Close()
+		return nil, errors.New("timed out waiting for the signing key lock")
+	}
+
+	return func() {
+		conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, signingKeyLock)
+		conn.Close()
+	}, nil
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"komite-sekolah/models"
+)
+
+var (
+	ErrReportNotPublished     = errors.New("Report has not been published")
+	ErrReportAlreadyPublished = errors.New("Report for this period is already published")
+)
+
+// generalIncomeLabel names the income line of payments not linked to a bill
+const generalIncomeLabel = "Iuran umum (tanpa tagihan)"
+
+// BuildTransparencyReport aggregates a school's income per fee category and disbursed expenses per
+// budget line between from and to. Payments not linked to a bill are reported as general income,
+// after the fee categories, and never merged with a category of the same name.
+func BuildTransparencyReport(schoolID int64, periode, from, to string) (*models.TransparencyReport, error) {
+	report := &models.TransparencyReport{
+		Periode:       periode,
+		DariTanggal:   from,
+		SampaiTanggal: to,
+		Pemasukan:     []models.TransparencyLine{},
+		Pengeluaran:   []models.TransparencyLine{},
+	}
+
+	rows, err := DB.Query(`
+		SELECT fc.id, COALESCE(fc.name, ''), SUM(p.nominal)
+		FROM payments p
+		LEFT JOIN bills b ON b.id = p.bill_id
+		LEFT JOIN fee_categories fc ON fc.id = b.fee_category_id
+		WHERE p.school_id = ? AND p.tanggal BETWEEN ? AND ?
+		GROUP BY fc.id, fc.name
+		ORDER BY fc.id IS NULL, fc.name, fc.id
+	`, schoolID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var line models.TransparencyLine
+		var feeCategoryID sql.NullInt64
+		if err := rows.Scan(&feeCategoryID, &line.Name, &line.Jumlah); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		line.FeeCategoryID = nullInt64Ptr(feeCategoryID)
+		if line.FeeCategoryID == nil {
+			line.Name = generalIncomeLabel
+		}
+		report.TotalPemasukan += line.Jumlah
+		report.Pemasukan = append(report.Pemasukan, line)
+	}
+	rows.Close()
+
+	err = DB.QueryRow(`
+		SELECT COALESCE(SUM(nominal), 0) FROM refunds WHERE school_id = ? AND tanggal BETWEEN ? AND ?
+	`, schoolID, from, to).Scan(&report.Pengembalian)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = DB.Query(`
+		SELECT bl.name, SUM(d.nominal)
+		FROM expense_disbursements d
+		JOIN expense_requests e ON e.id = d.expense_request_id
+		JOIN budget_lines bl ON bl.id = e.budget_line_id
+		WHERE e.school_id = ? AND d.tanggal BETWEEN ? AND ?
+		GROUP BY bl.id, bl.name
+		ORDER BY bl.name
+	`, schoolID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line models.TransparencyLine
+		if err := rows.Scan(&line.Name, &line.Jumlah); err != nil {
+			return nil, err
+		}
+		report.TotalPengeluaran += line.Jumlah
+		report.Pengeluaran = append(report.Pengeluaran, line)
+	}
+
+	report.Selisih = report.TotalPemasukan - report.Pengembalian - report.TotalPengeluaran
+	return report, nil
+}
+
+// PublishTransparencyReport freezes a report by storing its figures as a snapshot.
+// A period can only be published once per school so published numbers never change.
+func PublishTransparencyReport(schoolID int64, report *models.TransparencyReport, publishedBy int64) (*models.TransparencyReport, error) {
+	snapshot, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = DB.Exec(`
+		INSERT INTO transparency_reports (periode, dari_tanggal, sampai_tanggal, snapshot, published_by, school_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, report.Periode, report.DariTanggal, report.SampaiTanggal, string(snapshot), publishedBy, schoolID)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, ErrReportAlreadyPublished
+		}
+		return nil, err
+	}
+	return GetPublishedTransparencyReport(schoolID, report.Periode)
+}
+
+// GetPublishedTransparencyReport returns the frozen snapshot of a school's published period
+func GetPublishedTransparencyReport(schoolID int64, periode string) (*models.TransparencyReport, error) {
+	var snapshot string
+	var publishedAt sql.NullTime
+	err := DB.QueryRow(`
+		SELECT snapshot, published_at FROM transparency_reports WHERE school_id = ? AND periode = ?
+	`, schoolID, periode).Scan(&snapshot, &publishedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrReportNotPublished
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.TransparencyReport{}
+	if err := json.Unmarshal([]byte(snapshot), report); err != nil {
+		return nil, err
+	}
+	report.Published = true
+	if publishedAt.Valid {
+		report.PublishedAt = &publishedAt.Time
+	}
+	return report, nil
+}
+
+// GetPublishedTransparencyReports lists every period a school has published, newest first
+func GetPublishedTransparencyReports(schoolID int64) ([]models.PublishedReportSummary, error) {
+	rows, err := DB.Query(`
+		SELECT periode, DATE_FORMAT(dari_tanggal, '%Y-%m-%d'), DATE_FORMAT(sampai_tanggal, '%Y-%m-%d'), published_at
+		FROM transparency_reports
+		WHERE school_id = ?
+		ORDER BY sampai_tanggal DESC, id DESC
+	`, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []models.PublishedReportSummary
+	for rows.Next() {
+		var r models.PublishedReportSummary
+		if err := rows.Scan(&r.Periode, &r.DariTanggal, &r.SampaiTanggal, &r.PublishedAt); err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"komite-sekolah/models"
+)
+
+var ErrTwoFactorEnabled = errors.New("Two-factor authentication is already enabled")
+
+// hashRecoveryCode binds a recovery code to its user like hashResetCode does
+func hashRecoveryCode(userID int64, code string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("recovery:%d:%s", userID, code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetTOTPSecret returns a user's TOTP secret and whether 2FA is enabled. An empty secret
+// means enrollment has not been started.
+func GetTOTPSecret(userID int64) (string, bool, error) {
+	var secret sql.NullString
+	var enabled bool
+	err := DB.QueryRow(`SELECT totp_secret, totp_enabled FROM users WHERE id = ?`, userID).Scan(&secret, &enabled)
+	if err == sql.ErrNoRows {
+		return "", false, ErrUserNotFound
+	}
+	return secret.String, enabled, err
+}
+
+// SetPendingTOTPSecret starts (or restarts) enrollment with a new secret. It only takes
+// effect once EnableTOTP is called after the user proved their app generates its codes.
+func SetPendingTOTPSecret(userID int64, secret string) error {
+	result, err := DB.Exec(`
+		UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ? AND totp_enabled = 0
+	`, secret, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted code and reports false when that step
+// (or a later one) was already used, so an observed code cannot be replayed
+func UseTOTPStep(userID, step int64) (bool, error) {
+	result, err := DB.Exec(`
+		UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?
+	`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// EnableTOTP turns on 2FA with the pending secret and stores a fresh set of recovery codes
+func EnableTOTP(userID int64, recoveryCodes []string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET totp_enabled = 1, updated_at = ? WHERE id = ?`, time.Now(), userID); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes discards a user's recovery codes and stores new ones
+func ReplaceRecoveryCodes(userID int64, recoveryCodes []string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(db execer, userID int64, recoveryCodes []string) error {
+	if _, err := db.Exec(`DELETE FROM totp_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, code := range recoveryCodes {
+		if _, err := db.Exec(`
+			INSERT INTO totp_recovery_codes (user_id, code_hash) VALUES (?, ?)
+		`, userID, hashRecoveryCode(userID, code)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode consumes an unused recovery code and reports whether it was valid
+func UseRecoveryCode(userID int64, code string) (bool, error) {
+	result, err := DB.Exec(`
+		UPDATE totp_recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, time.Now(), userID, hashRecoveryCode(userID, code))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DisableTOTP turns off 2FA, forgetting the secret and every recovery code
+func DisableTOTP(userID int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE users SET totp_secret = NULL, totp_enabled = 0, totp_last_step = 0, updated_at = ?
+		WHERE id = ?
+	`, time.Now(), userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM totp_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetTwoFactorRoles returns the staff roles of a school that must use 2FA
+func GetTwoFactorRoles(schoolID int64) ([]models.UserRole, error) {
+	rows, err := DB.Query(`SELECT role FROM two_factor_roles WHERE school_id = ? ORDER BY role`, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []models.UserRole
+	for rows.Next() {
+		var role models.UserRole
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// SetTwoFactorRoles replaces the list of roles of a school that must use 2FA
+func SetTwoFactorRoles(schoolID int64, roles []models.UserRole) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM two_factor_roles WHERE school_id = ?`, schoolID); err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if _, err := tx.Exec(`INSERT IGNORE INTO two_factor_roles (school_id, role) VALUES (?, ?)`, schoolID, role); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// TwoFactorRequired reports whether a school requires 2FA for a role
+func TwoFactorRequired(schoolID int64, role models.UserRole) (bool, error) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM two_factor_roles WHERE school_id = ? AND role = ?
+	`, schoolID, role).Scan(&count)
+	return count > 0, err
+}
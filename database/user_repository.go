@@ -3,26 +3,66 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"komite-sekolah/models"
 )
 
 var (
-	ErrUserNotFound = errors.New("User not found")
-	ErrDuplicateUser = errors.New("user already exists")
+	ErrUserNotFound   = errors.New("User not found")
+	ErrDuplicateUser  = errors.New("user already exists")
+	ErrNISTaken       = errors.New("NIS is already registered")
+	ErrVATaken        = errors.New("Virtual account is already registered")
+	ErrInvalidSort    = errors.New("Invalid sort field")
+	ErrLastSuperAdmin = errors.New("Cannot remove the last active super admin")
 )
 
-func GetUserByUsername(username string) (*models.User, error) {
+// userColumns is the column list scanned by scanUser. Queries using it must
+// alias users as u and LEFT JOIN classes as c.
+const userColumns = `u.id, COALESCE(u.username, ''), COALESCE(u.nis, ''), COALESCE(u.virtual_account, ''),
+	COALESCE(u.phone, ''), COALESCE(u.email, ''), u.name, u.password, u.role,
+	u.status, u.must_change_password, u.totp_enabled, u.class_id, COALESCE(c.name, ''), u.school_id, u.created_at, u.updated_at`
+
+const userFrom = `FROM users u LEFT JOIN classes c ON c.id = u.class_id`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// extraScanner scans columns selected after userColumns into extra destinations
+type extraScanner struct {
+	row   rowScanner
+	extra []any
+}
+
+func (s extraScanner) Scan(dest ...any) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+func withExtraColumns(row rowScanner, extra ...any) rowScanner {
+	return extraScanner{row: row, extra: extra}
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
 	user := &models.User{}
-	err := DB.QueryRow(`
-		SELECT id, username, COALESCE(nis, ''), COALESCE(virtual_account, ''), name, password, role, must_change_password, created_at, updated_at
-		FROM users WHERE username = ?
-	`, username).Scan(
-		&user.ID, &user.Username, &user.NIS, &user.VirtualAccount, &user.Name, &user.Password,
-		&user.Role, &user.MustChangePassword, &user.CreatedAt, &user.UpdatedAt,
+	var classID, schoolID sql.NullInt64
+	err := row.Scan(
+		&user.ID, &user.Username, &user.NIS, &user.VirtualAccount, &user.Phone, &user.Email, &user.Name, &user.Password, &user.Role,
+		&user.Status, &user.MustChangePassword, &user.TwoFactorEnabled, &classID, &user.ClassName, &schoolID, &user.CreatedAt, &user.UpdatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	user.ClassID = nullInt64Ptr(classID)
+	user.SchoolID = nullInt64Ptr(schoolID)
+	return user, nil
+}
+
+func GetUserByUsername(username string) (*models.User, error) {
+	user, err := scanUser(DB.QueryRow(`SELECT `+userColumns+` `+userFrom+` WHERE u.username = ?`, username))
 
 	if err == sql.ErrNoRows {
 		return nil, ErrUserNotFound
@@ -33,15 +73,8 @@ func GetUserByUsername(username string) (*models.User, error) {
 	return user, nil
 }
 
-func GetUserByNIS(nis string) (*models.User, error) {
-	user := &models.User{}
-	err := DB.QueryRow(`
-		SELECT id, COALESCE(username, ''), nis, COALESCE(virtual_account, ''), name, password, role, must_change_password, created_at, updated_at
-		FROM users WHERE nis = ?
-	`, nis).Scan(
-		&user.ID, &user.Username, &user.NIS, &user.VirtualAccount, &user.Name, &user.Password,
-		&user.Role, &user.MustChangePassword, &user.CreatedAt, &user.UpdatedAt,
-	)
+func GetUserByNIS(schoolID int64, nis string) (*models.User, error) {
+	user, err := scanUser(DB.QueryRow(`SELECT `+userColumns+` `+userFrom+` WHERE u.nis = ? AND u.school_id = ?`, nis, schoolID))
 
 	if err == sql.ErrNoRows {
 		log.Printf("GetUserByNIS: no rows for nis=%q", nis)
@@ -55,15 +88,10 @@ func GetUserByNIS(nis string) (*models.User, error) {
 	return user, nil
 }
 
+// GetUserByID retrieves any user by ID. Use it only for the logged-in user's own account;
+// lookups of other accounts go through GetSchoolUser so they stay within one school.
 func GetUserByID(id int64) (*models.User, error) {
-	user := &models.User{}
-	err := DB.QueryRow(`
-		SELECT id, COALESCE(username, ''), COALESCE(nis, ''), COALESCE(virtual_account, ''), name, password, role, must_change_password, created_at, updated_at
-		FROM users WHERE id = ?
-	`, id).Scan(
-		&user.ID, &user.Username, &user.NIS, &user.VirtualAccount, &user.Name, &user.Password,
-		&user.Role, &user.MustChangePassword, &user.CreatedAt, &user.UpdatedAt,
-	)
+	user, err := scanUser(DB.QueryRow(`SELECT `+userColumns+` `+userFrom+` WHERE u.id = ?`, id))
 
 	if err == sql.ErrNoRows {
 		return nil, ErrUserNotFound
@@ -74,44 +102,517 @@ func GetUserByID(id int64) (*models.User, error) {
 	return user, nil
 }
 
-func CreateStudent(nis, virtual_account, name, hashedPassword string) (*models.User, error) {
-	result, err := DB.Exec(`
-		INSERT INTO users (nis, virtual_account, name, password, role, must_change_password)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, nis, virtual_account, name, hashedPassword, models.RoleStudent, 1)
+// GetSchoolUser retrieves a user of one school by ID
+func GetSchoolUser(schoolID, id int64) (*models.User, error) {
+	user, err := scanUser(DB.QueryRow(`SELECT `+userColumns+` `+userFrom+` WHERE u.id = ? AND u.school_id = ?`, id, schoolID))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
 
+func CreateStudent(schoolID int64, nis, virtual_account, name, hashedPassword string, classID *int64) (*models.User, error) {
+	tx, err := DB.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO users (nis, virtual_account, name, password, role, must_change_password, school_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, nis, virtual_account, name, hashedPassword, models.RoleStudent, 1, schoolID)
+
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, ErrDuplicateUser
+		}
+		return nil, err
+	}
 
 	id, _ := result.LastInsertId()
-	return GetUserByID(id)
+	if classID != nil {
+		if err := enrollInTx(tx, id, *classID, today()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetSchoolUser(schoolID, id)
 }
 
+// UpdatePassword sets a user's own new password and ends every session of the user
 func UpdatePassword(userID int64, hashedPassword string) error {
-	_, err := DB.Exec(`
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
 		UPDATE users 
 		SET password = ?, must_change_password = 0, updated_at = ?
 		WHERE id = ?
 	`, hashedPassword, time.Now(), userID)
-	return err
+	if err != nil {
+		return err
+	}
+	if err := revokeUserSessions(tx, userID, 0); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func ResetPassword(userID int64, hashedPassword string) error {
-	_, err := DB.Exec(`
+// ResetPassword sets a password chosen by an admin, which must be changed on next login,
+// and ends every session of the user
+func ResetPassword(schoolID, userID int64, hashedPassword string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
 		UPDATE users 
 		SET password = ?, must_change_password = 1, updated_at = ?
-		WHERE id = ?
-	`, hashedPassword, time.Now(), userID)
-	return err
+		WHERE id = ? AND school_id = ?
+	`, hashedPassword, time.Now(), userID, schoolID)
+	if err != nil {
+		return err
+	}
+	if err := revokeUserSessions(tx, userID, 0); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func GetAllStudents() ([]models.User, error) {
-	rows, err := DB.Query(`
-		SELECT id, COALESCE(username, ''), nis, COALESCE(virtual_account, ''), name, role, must_change_password, created_at, updated_at
-		FROM users WHERE role = 'student'
-		ORDER BY name
+// ResetPasswords gives several users of a school new passwords in one transaction, keyed by
+// user ID. Like ResetPassword, each must change it at the next login and is logged out.
+func ResetPasswords(schoolID int64, hashedPasswords map[int64]string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for userID, hashedPassword := range hashedPasswords {
+		_, err := tx.Exec(`
+			UPDATE users
+			SET password = ?, must_change_password = 1, updated_at = ?
+			WHERE id = ? AND school_id = ?
+		`, hashedPassword, now, userID, schoolID)
+		if err != nil {
+			return err
+		}
+		if err := revokeUserSessions(tx, userID, 0); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetCredentialStudents returns the active students of a school that are in a class (when
+// classID is set) and among userIDs (when given), ordered by class and name. With
+// onlyPending, students who already changed their initial password are left out.
+func GetCredentialStudents(schoolID, classID int64, userIDs []int64, onlyPending bool) ([]models.User, error) {
+	where := []string{"u.role = 'student'", "u.school_id = ?", "u.status = ?"}
+	args := []interface{}{schoolID, models.StatusActive}
+	if classID != 0 {
+		where = append(where, "u.class_id = ?")
+		args = append(args, classID)
+	}
+	if len(userIDs) > 0 {
+		where = append(where, "u.id IN (?"+strings.Repeat(", ?", len(userIDs)-1)+")")
+		for _, id := range userIDs {
+			args = append(args, id)
+		}
+	}
+	if onlyPending {
+		where = append(where, "u.must_change_password = 1")
+	}
+
+	rows, err := DB.Query(`SELECT `+userColumns+` `+userFrom+` WHERE `+strings.Join(where, " AND ")+` ORDER BY c.name, u.name, u.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+// UpdateStudent changes a student's NIS, virtual account, name or class. NIS and virtual
+// account numbers are checked for uniqueness against every other user of the school first;
+// a class change is recorded as a new enrollment.
+func UpdateStudent(schoolID int64, req models.UpdateStudentRequest) (*models.User, error) {
+	var sets []string
+	var args []interface{}
+
+	if req.NIS != nil {
+		if taken, err := valueTakenByOther(schoolID, "nis", *req.NIS, req.UserID); err != nil {
+			return nil, err
+		} else if taken {
+			return nil, ErrNISTaken
+		}
+		sets = append(sets, "nis = ?")
+		args = append(args, *req.NIS)
+	}
+	if req.VirtualAccount != nil {
+		if taken, err := valueTakenByOther(schoolID, "virtual_account", *req.VirtualAccount, req.UserID); err != nil {
+			return nil, err
+		} else if taken {
+			return nil, ErrVATaken
+		}
+		sets = append(sets, "virtual_account = ?")
+		args = append(args, *req.VirtualAccount)
+	}
+	if req.Name != nil {
+		sets = append(sets, "name = ?")
+		args = append(args, *req.Name)
+	}
+
+	if len(sets) == 0 && req.ClassID == nil {
+		return nil, errors.New("No fields to update")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if len(sets) > 0 {
+		query := "UPDATE users SET " + strings.Join(sets, ", ") + ", updated_at = CURRENT_TIMESTAMP WHERE id = ? AND school_id = ? AND role = 'student'"
+		args = append(args, req.UserID, schoolID)
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			if isDuplicateKey(err) {
+				return nil, ErrDuplicateUser
+			}
+			return nil, err
+		}
+	}
+
+	// A class change is a move, so it goes through enrollment to keep the history
+	if req.ClassID != nil {
+		user, err := GetSchoolUser(schoolID, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user.ClassID == nil || *user.ClassID != *req.ClassID {
+			if err := enrollInTx(tx, req.UserID, *req.ClassID, today()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetSchoolUser(schoolID, req.UserID)
+}
+
+func valueTakenByOther(schoolID int64, column, value string, userID int64) (bool, error) {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM users WHERE `+column+` = ? AND school_id = ? AND id <> ?`, value, schoolID, userID).Scan(&count)
+	return count > 0, err
+}
+
+// UpdateUserStatus activates or deactivates an account. Payments and bills are kept; a
+// deactivated account is logged out everywhere.
+func UpdateUserStatus(schoolID, userID int64, status models.UserStatus) error {
+	_, err := DB.Exec(`
+		UPDATE users
+		SET status = ?, updated_at = ?
+		WHERE id = ? AND school_id = ?
+	`, status, time.Now(), userID, schoolID)
+	if err != nil || status == models.StatusActive {
+		return err
+	}
+	return RevokeUserSessions(userID, 0)
+}
+
+// studentSortColumns maps the sort fields accepted by GetStudents to SQL expressions
+var studentSortColumns = map[string][]string{
+	"name":       {"u.name"},
+	"nis":        {"u.nis"},
+	"va":         {"u.virtual_account"},
+	"class":      {"c.grade_level", "c.name"},
+	"status":     {"u.status"},
+	"created_at": {"u.created_at"},
+}
+
+// GetStudents returns one page of students matching the filter, together with the number
+// of students matching it in total. Results are ordered by name unless the filter says otherwise.
+func GetStudents(schoolID int64, filter models.StudentFilter) ([]models.User, int64, error) {
+	where := []string{"u.role = 'student'", "u.school_id = ?"}
+	args := []interface{}{schoolID}
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		where = append(where, "(u.name LIKE ? OR u.nis LIKE ? OR u.virtual_account LIKE ?)")
+		args = append(args, pattern, pattern, pattern)
+	}
+	if filter.ClassID != 0 {
+		where = append(where, "u.class_id = ?")
+		args = append(args, filter.ClassID)
+	}
+	if filter.Status != "" {
+		where = append(where, "u.status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.MustChangePassword != nil {
+		where = append(where, "u.must_change_password = ?")
+		args = append(args, *filter.MustChangePassword)
+	}
+	whereSQL := " WHERE " + strings.Join(where, " AND ")
+
+	var total int64
+	if err := DB.QueryRow(`SELECT COUNT(*) `+userFrom+whereSQL, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	orderBy, err := orderByClause(filter.Sort, studentSortColumns, "u.name")
+	if err != nil {
+		return nil, 0, err
+	}
+	// u.id breaks ties so pages never overlap or skip rows
+	query := `SELECT ` + userColumns + ` ` + userFrom + whereSQL + ` ORDER BY ` + orderBy + `, u.id LIMIT ? OFFSET ?`
+	args = append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *user)
+	}
+	return users, total, nil
+}
+
+// ExistingStudentIdentifiers returns which of the given NIS and virtual account numbers are
+// already taken in a school
+func ExistingStudentIdentifiers(schoolID int64, nisList, vaList []string) (map[string]bool, map[string]bool, error) {
+	nisTaken, err := existingValues(schoolID, "nis", nisList)
+	if err != nil {
+		return nil, nil, err
+	}
+	vaTaken, err := existingValues(schoolID, "virtual_account", vaList)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nisTaken, vaTaken, nil
+}
+
+// existingValues looks up values of a per-school unique users column in batches
+func existingValues(schoolID int64, column string, values []string) (map[string]bool, error) {
+	taken := make(map[string]bool)
+	const batchSize = 500
+	for start := 0; start < len(values); start += batchSize {
+		end := start + batchSize
+		if end > len(values) {
+			end = len(values)
+		}
+		batch := values[start:end]
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ")
+		args := []interface{}{schoolID}
+		for _, v := range batch {
+			args = append(args, v)
+		}
+
+		rows, err := DB.Query(`SELECT `+column+` FROM users WHERE school_id = ? AND `+column+` IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			taken[v] = true
+		}
+		rows.Close()
+	}
+	return taken, nil
+}
+
+// CreateStudents inserts many students in a single transaction; either all rows are created or none.
+// hashedPasswords must be parallel to rows.
+func CreateStudents(schoolID int64, rows []models.StudentImportRow, hashedPasswords []string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO users (nis, virtual_account, name, password, role, must_change_password, school_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	startDate := today()
+	for i, row := range rows {
+		result, err := stmt.Exec(row.NIS, row.VirtualAccount, row.Name, hashedPasswords[i], models.RoleStudent, 1, schoolID)
+		if err != nil {
+			if isDuplicateKey(err) {
+				return fmt.Errorf("row %d: %w", row.Row, ErrDuplicateUser)
+			}
+			return err
+		}
+		if row.ClassID != nil {
+			id, _ := result.LastInsertId()
+			if err := enrollInTx(tx, id, *row.ClassID, startDate); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// orderByClause turns sort fields such as "class" or "-name" into an ORDER BY list using
+// only the columns whitelisted in columns
+func orderByClause(fields []string, columns map[string][]string, fallback string) (string, error) {
+	var parts []string
+	for _, field := range fields {
+		direction := "ASC"
+		if strings.HasPrefix(field, "-") {
+			direction = "DESC"
+			field = field[1:]
+		}
+		exprs, ok := columns[field]
+		if !ok {
+			return "", ErrInvalidSort
+		}
+		for _, expr := range exprs {
+			parts = append(parts, expr+" "+direction)
+		}
+	}
+	if len(parts) == 0 {
+		return fallback, nil
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// escapeLike escapes the LIKE wildcards in a user-supplied search term
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// AssignRole changes a staff account's role. For wali_kelas, classID is the class they
+// are homeroom teacher of; other roles have it cleared. The last active super admin of a
+// school cannot be demoted.
+func AssignRole(schoolID, userID int64, role models.UserRole, classID *int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if role != models.RoleSuperAdmin {
+		if err := ensureOtherSuperAdmin(tx, schoolID, userID); err != nil {
+			return err
+		}
+	}
+
+	if role != models.RoleWaliKelas {
+		classID = nil
+	}
+	_, err = tx.Exec(`
+		UPDATE users SET role = ?, class_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND school_id = ?
+	`, role, classID, userID, schoolID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ensureOtherSuperAdmin fails with ErrLastSuperAdmin when userID is the only active super
+// admin left in its school. The super admin rows are locked so two concurrent demotions
+// cannot both pass.
+func ensureOtherSuperAdmin(tx *sql.Tx, schoolID, userID int64) error {
+	rows, err := tx.Query(`
+		SELECT id FROM users WHERE role = ? AND status = ? AND school_id = ? FOR UPDATE
+	`, models.RoleSuperAdmin, models.StatusActive, schoolID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	isSuperAdmin, others := false, 0
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		if id == userID {
+			isSuperAdmin = true
+		} else {
+			others++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if isSuperAdmin && others == 0 {
+		return ErrLastSuperAdmin
+	}
+	return nil
+}
+
+// CreateStaff creates an admin or staff account that must change its password on first login
+func CreateStaff(schoolID int64, req models.CreateStaffRequest, hashedPassword string) (*models.User, error) {
+	if req.Role != models.RoleWaliKelas {
+		req.ClassID = nil
+	}
+
+	result, err := DB.Exec(`
+		INSERT INTO users (username, name, password, role, must_change_password, class_id, school_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, req.Username, req.Name, hashedPassword, req.Role, 1, req.ClassID, schoolID)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, ErrDuplicateUser
+		}
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetSchoolUser(schoolID, id)
+}
+
+// GetAllStaff returns every admin and staff account of a school ordered by name
+func GetAllStaff(schoolID int64) ([]models.User, error) {
+	rows, err := DB.Query(`
+		SELECT `+userColumns+` `+userFrom+`
+		WHERE u.role NOT IN (?, ?) AND u.school_id = ?
+		ORDER BY u.name
+	`, models.RoleStudent, models.RoleParent, schoolID)
 	if err != nil {
 		return nil, err
 	}
@@ -119,17 +620,38 @@ func GetAllStudents() ([]models.User, error) {
 
 	var users []models.User
 	for rows.Next() {
-		var user models.User
-		err := rows.Scan(
-			&user.ID, &user.Username, &user.NIS, &user.VirtualAccount, &user.Name,
-			&user.Role, &user.MustChangePassword, &user.CreatedAt, &user.UpdatedAt,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
-		users = append(users, user)
+		users = append(users, *user)
 	}
 	return users, nil
 }
 
+// UpdateStaffStatus disables or re-enables a staff account. The last active super admin
+// of a school cannot be disabled; a disabled account is logged out everywhere.
+func UpdateStaffStatus(schoolID, userID int64, status models.UserStatus) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if status != models.StatusActive {
+		if err := ensureOtherSuperAdmin(tx, schoolID, userID); err != nil {
+			return err
+		}
+	}
 
+	_, err = tx.Exec(`UPDATE users SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND school_id = ?`, status, userID, schoolID)
+	if err != nil {
+		return err
+	}
+	if status != models.StatusActive {
+		if err := revokeUserSessions(tx, userID, 0); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
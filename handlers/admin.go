@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"komite-sekolah/database"
 	"komite-sekolah/models"
@@ -14,7 +18,8 @@ type CreateStudentRequest struct {
 	NIS      	   string `json:"nis"`
 	VirtualAccount string `json:"virtual_account"`
 	Name    	   string `json:"name"`
-	Password 	   string `json:"password"` // Initial password given by admin
+	Password 	   string `json:"password"` // Initial password given by admin; generated when empty
+	ClassID        *int64 `json:"class_id,omitempty"`
 }
 
 // CreateStudent creates a new student account (admin only)
@@ -24,64 +29,173 @@ func CreateStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user is admin
-	role, ok := r.Context().Value("user_role").(models.UserRole)
-	if !ok || role != models.RoleAdmin {
-		respondError(w, http.StatusForbidden, "Admin access required")
-		return
-	}
-
 	var req CreateStudentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	} 
 
-	if req.NIS == "" || req.VirtualAccount == "" || req.Name == "" || req.Password == "" {
-		respondError(w, http.StatusBadRequest, "NIS, virtual account, name, and password are required")
+	if req.NIS == "" || req.VirtualAccount == "" || req.Name == "" {
+		respondError(w, http.StatusBadRequest, "NIS, virtual account, and name are required")
 		return
 	}
 
+	generated := req.Password == ""
+	if generated {
+		password, err := generatePassword()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to generate password")
+			return
+		}
+		req.Password = password
+	} else if req.Password == req.NIS {
+		respondError(w, http.StatusBadRequest, "Password must not be the NIS")
+		return
+	}
+
+	if req.ClassID != nil {
+		if _, err := database.GetClassByID(callerSchool(r), *req.ClassID); err != nil {
+			respondError(w, http.StatusNotFound, "Class not found")
+			return
+		}
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to hash password")
 		return
 	}
 
-	user, err := database.CreateStudent(req.NIS, req.VirtualAccount, req.Name, string(hashedPassword))
+	user, err := database.CreateStudent(callerSchool(r), req.NIS, req.VirtualAccount, req.Name, string(hashedPassword), req.ClassID)
 	if err != nil {
+		if err == database.ErrDuplicateUser {
+			respondError(w, http.StatusConflict, "NIS or virtual account is already registered")
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Failed to create student: "+err.Error())
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, user)
+	audit(r, models.AuditCreate, models.EntityStudent, user.ID, nil, user)
+
+	resp := models.NewStudentResponse{User: *user}
+	if generated {
+		resp.GeneratedPassword = req.Password
+	}
+	respondJSON(w, http.StatusCreated, resp)
 }
 
-// GetStudents returns all students (admin only)
+const (
+	defaultPageSize = 25
+	maxPageSize     = 100
+)
+
+// GetStudents returns a page of students (admin only). Supports search by name, NIS or VA
+// (q), filters on class_id, status and must_change_password, sorting (sort=class,-name)
+// and page/page_size pagination.
 func GetStudents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	} 
 
-	role, ok := r.Context().Value("user_role").(models.UserRole)
-	if !ok || role != models.RoleAdmin {
-		respondError(w, http.StatusForbidden, "Admin access required")
+	query := r.URL.Query()
+	filter := models.StudentFilter{
+		Search: strings.TrimSpace(query.Get("q")),
+		Status: models.UserStatus(query.Get("status")),
+		Sort:   parseSortFields(query.Get("sort")),
+	}
+
+	classID, err := parseOptionalID(query.Get("class_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid class_id")
 		return
 	}
+	filter.ClassID = classID
 
-	students, err := database.GetAllStudents()
+	// A homeroom teacher only ever sees their own class, whatever class_id was asked for
+	scope, err := classScope(r)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to fetch students")
 		return
 	}
+	if scope != 0 {
+		filter.ClassID = scope
+	}
 
-	respondJSON(w, http.StatusOK, students)
+	if filter.Status != "" && !validStatus(filter.Status) {
+		respondError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	if v := query.Get("must_change_password"); v != "" {
+		mustChange, err := strconv.ParseBool(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid must_change_password")
+			return
+		}
+		filter.MustChangePassword = &mustChange
+	}
+
+	filter.Page, filter.PageSize, err = parsePagination(query)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	students, total, err := database.GetStudents(callerSchool(r), filter)
+	if err != nil {
+		if err == database.ErrInvalidSort {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to fetch students")
+		return
+	}
+
+	if students == nil {
+		students = []models.User{}
+	}
+
+	respondJSON(w, http.StatusOK, models.StudentListResponse{
+		Data: students,
+		Meta: models.NewListMeta(total, filter.Page, filter.PageSize),
+	})
+}
+
+// parsePagination reads page (1-based) and page_size, applying the defaults and the size cap
+func parsePagination(query url.Values) (page, pageSize int, err error) {
+	page, pageSize = 1, defaultPageSize
+	if v := query.Get("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return 0, 0, errors.New("Invalid page")
+		}
+	}
+	if v := query.Get("page_size"); v != "" {
+		if pageSize, err = strconv.Atoi(v); err != nil || pageSize < 1 {
+			return 0, 0, errors.New("Invalid page_size")
+		}
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize, nil
+}
+
+// parseSortFields splits a comma-separated sort parameter such as "class,-name"
+func parseSortFields(value string) []string {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
 }
 
 type ResetPasswordRequest struct {
 	UserID      int64  `json:"user_id"`
-	NewPassword string `json:"new_password"`
+	NewPassword string `json:"new_password"` // Generated when empty (students only)
 }
 
 // ResetStudentPassword resets a student's password (admin only)
@@ -92,25 +206,19 @@ func ResetStudentPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	role, ok := r.Context().Value("user_role").(models.UserRole)
-	if !ok || role != models.RoleAdmin {
-		respondError(w, http.StatusForbidden, "Admin access required")
-		return
-	}
-
 	var req ResetPasswordRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if req.UserID == 0 || req.NewPassword == "" {
-		respondError(w, http.StatusBadRequest, "User ID and new password are required")
+	if req.UserID == 0 {
+		respondError(w, http.StatusBadRequest, "user_id is required")
 		return
 	}
 
-	// Verify it's a student
-	user, err := database.GetUserByID(req.UserID)
+	// Verify it's a student of the caller's school
+	user, err := database.GetSchoolUser(callerSchool(r), req.UserID)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "User not found")
 		return
@@ -121,6 +229,19 @@ func ResetStudentPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	generated := req.NewPassword == ""
+	if generated {
+		password, err := generatePassword()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to generate password")
+			return
+		}
+		req.NewPassword = password
+	} else if req.NewPassword == user.NIS {
+		respondError(w, http.StatusBadRequest, "Password must not be the NIS")
+		return
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to hash password")
@@ -128,12 +249,124 @@ func ResetStudentPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Reset password and set must_change_password to true
-	if err := database.ResetPassword(req.UserID, string(hashedPassword)); err != nil {
+	if err := database.ResetPassword(callerSchool(r), req.UserID, string(hashedPassword)); err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to reset password")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"message": "Password reset successfully"})
+	audit(r, models.AuditResetPassword, models.EntityStudent, req.UserID, nil, nil)
+
+	resp := map[string]string{"message": "Password reset successfully"}
+	if generated {
+		// Shown once; only the hash is stored
+		resp["generated_password"] = req.NewPassword
+	}
+	respondJSON(w, http.StatusOK, resp)
 }
 
+// UpdateStudent edits a student's NIS, virtual account, name or class (admin only)
+func UpdateStudent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
 
+	var req models.UpdateStudentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.UserID == 0 {
+		respondError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if req.NIS == nil && req.VirtualAccount == nil && req.Name == nil && req.ClassID == nil {
+		respondError(w, http.StatusBadRequest, "No fields to update")
+		return
+	}
+	if (req.NIS != nil && strings.TrimSpace(*req.NIS) == "") ||
+		(req.VirtualAccount != nil && strings.TrimSpace(*req.VirtualAccount) == "") ||
+		(req.Name != nil && strings.TrimSpace(*req.Name) == "") {
+		respondError(w, http.StatusBadRequest, "NIS, virtual account, and name cannot be empty")
+		return
+	}
+
+	user, err := database.GetSchoolUser(callerSchool(r), req.UserID)
+	if err != nil || user.Role != models.RoleStudent {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if req.ClassID != nil {
+		if _, err := database.GetClassByID(callerSchool(r), *req.ClassID); err != nil {
+			respondError(w, http.StatusNotFound, "Class not found")
+			return
+		}
+	}
+
+	updated, err := database.UpdateStudent(callerSchool(r), req)
+	if err != nil {
+		switch err {
+		case database.ErrNISTaken, database.ErrVATaken:
+			respondError(w, http.StatusConflict, err.Error())
+		case database.ErrDuplicateUser:
+			respondError(w, http.StatusConflict, "NIS or virtual account is already registered")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to update student: "+err.Error())
+		}
+		return
+	}
+
+	audit(r, models.AuditUpdate, models.EntityStudent, updated.ID, user, updated)
+	respondJSON(w, http.StatusOK, updated)
+}
+
+// UpdateStudentStatus deactivates or reactivates a student (admin only).
+// Inactive, transferred and graduated students cannot log in and are skipped by bill
+// generation; their payment history is kept.
+func UpdateStudentStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.UpdateStudentStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.UserID == 0 {
+		respondError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if !validStatus(req.Status) {
+		respondError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	user, err := database.GetSchoolUser(callerSchool(r), req.UserID)
+	if err != nil || user.Role != models.RoleStudent {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := database.UpdateUserStatus(callerSchool(r), req.UserID, req.Status); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update status")
+		return
+	}
+
+	before := *user
+	user.Status = req.Status
+	audit(r, models.AuditStatus, models.EntityStudent, user.ID, before, user)
+	respondJSON(w, http.StatusOK, user)
+}
+
+func validStatus(status models.UserStatus) bool {
+	switch status {
+	case models.StatusActive, models.StatusInactive, models.StatusTransferred, models.StatusGraduated:
+		return true
+	}
+	return false
+}
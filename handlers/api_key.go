@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+)
+
+// apiKeyPrefixLength is how much of a key is kept in the clear to tell keys apart
+const apiKeyPrefixLength = 12
+
+// GetAPIKeys lists the school's API keys with when and from where each was last used
+// (super admin only). The keys themselves cannot be shown again.
+func GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	keys, err := database.GetAPIKeys(callerSchool(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch API keys")
+		return
+	}
+
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+
+	respondJSON(w, http.StatusOK, keys)
+}
+
+// CreateAPIKey issues a key for a machine integration with a subset of the staff
+// permissions, valid through expires_on and optionally only from allowed_ips (super admin
+// only). The key is returned once; only its hash is stored.
+func CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		respondError(w, http.StatusBadRequest, "name is required (max 100 characters)")
+		return
+	}
+	if len(req.Permissions) == 0 {
+		respondError(w, http.StatusBadRequest, "permissions is required")
+		return
+	}
+	for _, perm := range req.Permissions {
+		if !grantableToAPIKey(perm) {
+			respondError(w, http.StatusBadRequest, "Permission cannot be granted to an API key: "+string(perm))
+			return
+		}
+	}
+	for i, allowed := range req.AllowedIPs {
+		allowed = strings.TrimSpace(allowed)
+		if _, _, err := net.ParseCIDR(allowed); err != nil && net.ParseIP(allowed) == nil {
+			respondError(w, http.StatusBadRequest, "Invalid IP address or range in allowed_ips")
+			return
+		}
+		req.AllowedIPs[i] = allowed
+	}
+
+	// A key is valid through the whole of its last day
+	expiresOn, err := time.ParseInLocation(dateLayout, req.ExpiresOn, time.Local)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid expires_on, expected YYYY-MM-DD")
+		return
+	}
+	expiresAt := expiresOn.AddDate(0, 0, 1)
+	if !expiresAt.After(time.Now()) {
+		respondError(w, http.StatusBadRequest, "expires_on must not be in the past")
+		return
+	}
+
+	key, err := newAPIKey()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate API key")
+		return
+	}
+
+	apiKey := models.APIKey{
+		SchoolID:    callerSchool(r),
+		Name:        req.Name,
+		Prefix:      key[:apiKeyPrefixLength],
+		Permissions: req.Permissions,
+		AllowedIPs:  req.AllowedIPs,
+		ExpiresAt:   expiresAt,
+		CreatedBy:   userID,
+	}
+	if apiKey.AllowedIPs == nil {
+		apiKey.AllowedIPs = []string{}
+	}
+	if err := database.CreateAPIKey(&apiKey, key); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityAPIKey, apiKey.ID, nil, apiKey)
+
+	// The key cannot be shown again, so no proxy or browser should keep a copy
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusCreated, models.CreateAPIKeyResponse{APIKey: apiKey, Key: key})
+}
+
+// RevokeAPIKey stops a key from being accepted, at once and for good (super admin only)
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.RevokeAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	before, err := database.GetAPIKeyByID(callerSchool(r), req.ID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	revoked, err := database.RevokeAPIKey(callerSchool(r), req.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+	if !revoked {
+		respondError(w, http.StatusConflict, "API key already revoked")
+		return
+	}
+
+	after, err := database.GetAPIKeyByID(callerSchool(r), req.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch API keys")
+		return
+	}
+
+	audit(r, models.AuditRevoke, models.EntityAPIKey, req.ID, before, after)
+	respondJSON(w, http.StatusOK, after)
+}
+
+func grantableToAPIKey(perm models.Permission) bool {
+	for _, p := range models.APIKeyPermissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// newAPIKey returns a random key starting with models.APIKeyPrefix
+func newAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return models.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"komite-sekolah/database"
+	"komite-sekolah/middleware"
+	"komite-sekolah/models"
+)
+
+const maxUserAgentLength = 255
+
+// audit records a change the caller just made in the audit trail. before and after are the
+// entity as the API returns it, or nil when there is none. The change has already been
+// committed, so a failure to record it is logged rather than returned to the caller.
+func audit(r *http.Request, action, entityType string, entityID int64, before, after any) {
+	entry := models.AuditLog{
+		ActorRole:  callerRole(r),
+		Action:     action,
+		EntityType: entityType,
+		IP:         middleware.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+	}
+	entry.ActorID, _ = r.Context().Value("user_id").(int64)
+	if schoolID := callerSchool(r); schoolID != 0 {
+		entry.SchoolID = &schoolID
+	}
+	recordAudit(entry, entityID, before, after)
+}
+
+// auditOwnAccount records a change users made to their own login, such as a new password or
+// 2FA. It is filed under the user's own school, or foundation-wide for a foundation admin,
+// and also works before login, when the request carries no token yet.
+func auditOwnAccount(r *http.Request, user *models.User, action string) {
+	recordAudit(models.AuditLog{
+		SchoolID:   user.SchoolID,
+		ActorID:    user.ID,
+		ActorRole:  user.Role,
+		Action:     action,
+		EntityType: accountEntity(user.Role),
+		IP:         middleware.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+	}, user.ID, nil, nil)
+}
+
+// accountEntity returns the entity type of a user's own account
+func accountEntity(role models.UserRole) string {
+	switch role {
+	case models.RoleStudent:
+		return models.EntityStudent
+	case models.RoleParent:
+		return models.EntityParent
+	}
+	return models.EntityStaff
+}
+
+// recordAudit completes and stores an audit entry whose actor and school are already set. It
+// also records changes no caller made, such as an impersonation running out.
+func recordAudit(entry models.AuditLog, entityID int64, before, after any) {
+	if entityID != 0 {
+		entry.EntityID = &entityID
+	}
+	if len(entry.UserAgent) > maxUserAgentLength {
+		entry.UserAgent = entry.UserAgent[:maxUserAgentLength]
+	}
+
+	var err error
+	if entry.Before, err = auditValue(before); err == nil {
+		entry.After, err = auditValue(after)
+	}
+	if err == nil {
+		err = database.CreateAuditLog(entry)
+	}
+	if err != nil {
+		log.Printf("Failed to record audit log %s %s %d by user %d: %v", entry.Action, entry.EntityType, entityID, entry.ActorID, err)
+	}
+}
+
+func auditValue(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// GetAuditLogs returns the school's audit trail, newest first (auditor only). Filters:
+// actor_id, action, entity_type, entity_id and from/to (YYYY-MM-DD), with page/page_size.
+func GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	listAuditLogs(w, r, callerSchool(r))
+}
+
+// GetFoundationAuditLogs returns the audit entries that belong to no school, such as schools
+// being created, with the same filters as GetAuditLogs (foundation admin only)
+func GetFoundationAuditLogs(w http.ResponseWriter, r *http.Request) {
+	listAuditLogs(w, r, 0)
+}
+
+// listAuditLogs answers one page of a school's audit trail, or of the foundation-level
+// entries when schoolID is 0
+func listAuditLogs(w http.ResponseWriter, r *http.Request, schoolID int64) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	filter, ok := parseAuditFilter(w, r.URL.Query())
+	if !ok {
+		return
+	}
+
+	var err error
+	filter.Page, filter.PageSize, err = parsePagination(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, total, err := database.GetAuditLogs(schoolID, filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch audit log")
+		return
+	}
+
+	if entries == nil {
+		entries = []models.AuditLog{}
+	}
+
+	respondJSON(w, http.StatusOK, models.AuditLogListResponse{
+		Data: entries,
+		Meta: models.NewListMeta(total, filter.Page, filter.PageSize),
+	})
+}
+
+// ExportAuditLogs downloads every audit entry matching the same filters as GetAuditLogs as
+// CSV (auditor only)
+func ExportAuditLogs(w http.ResponseWriter, r *http.Request) {
+	exportAuditLogs(w, r, callerSchool(r))
+}
+
+// ExportFoundationAuditLogs downloads the foundation-level audit entries as CSV (foundation
+// admin only)
+func ExportFoundationAuditLogs(w http.ResponseWriter, r *http.Request) {
+	exportAuditLogs(w, r, 0)
+}
+
+func exportAuditLogs(w http.ResponseWriter, r *http.Request, schoolID int64) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	filter, ok := parseAuditFilter(w, r.URL.Query())
+	if !ok {
+		return
+	}
+
+	entries, _, err := database.GetAuditLogs(schoolID, filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch audit log")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-log-`+time.Now().Format(dateLayout)+`.csv"`)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "waktu", "actor_id", "actor_name", "actor_role", "action", "entity_type", "entity_id", "ip", "user_agent", "before", "after"})
+	for _, e := range entries {
+		entityID := ""
+		if e.EntityID != nil {
+			entityID = strconv.FormatInt(*e.EntityID, 10)
+		}
+		out.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.Format(time.RFC3339),
+			strconv.FormatInt(e.ActorID, 10),
+			csvSafe(e.ActorName),
+			string(e.ActorRole),
+			e.Action,
+			e.EntityType,
+			entityID,
+			e.IP,
+			csvSafe(e.UserAgent),
+			csvSafe(string(e.Before)),
+			csvSafe(string(e.After)),
+		})
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("Failed to write audit log export: %v", err)
+	}
+}
+
+func parseAuditFilter(w http.ResponseWriter, q url.Values) (models.AuditFilter, bool) {
+	filter := models.AuditFilter{
+		Action:     q.Get("action"),
+		EntityType: q.Get("entity_type"),
+	}
+
+	var err error
+	if filter.ActorID, err = parseOptionalID(q.Get("actor_id")); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid actor_id")
+		return filter, false
+	}
+	if filter.EntityID, err = parseOptionalID(q.Get("entity_id")); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid entity_id")
+		return filter, false
+	}
+
+	for _, d := range []struct {
+		value string
+		dest  *string
+	}{{q.Get("from"), &filter.From}, {q.Get("to"), &filter.To}} {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, d.value); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid date range, expected YYYY-MM-DD")
+			return filter, false
+		}
+		*d.dest = d.value
+	}
+	return filter, true
+}
+
+// csvSafe keeps spreadsheet programs from evaluating a cell as a formula
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsAny(s[:1], "=+-@\t\r") {
+		return "'" + s
+	}
+	return s
+}
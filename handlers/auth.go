@@ -1,29 +1,23 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"komite-sekolah/config"
 	"komite-sekolah/database"
 	"komite-sekolah/models"
+	"komite-sekolah/tokens"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func getJWTSecret() []byte {
-	return []byte(config.AppConfig.JWTSecret)
-}
-
-type Claims struct {
-	UserID int64           `json:"user_id"`
-	Role   models.UserRole `json:"role"`
-	jwt.RegisteredClaims
-}
-
-// LoginAdmin handles admin login with username & password
+// LoginAdmin handles admin and staff login with username & password
 func LoginAdmin(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -41,33 +35,42 @@ func LoginAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	} 
 
+	// Usernames are unique across schools; the account's school only decides whose admins
+	// see its lockout
 	user, err := database.GetUserByUsername(req.Username)
+	attempt := newLoginAttempt(r, models.LoginScopeAdmin, lockoutSchool(user), req.Username)
+	if !attempt.allowed(w) {
+		return
+	}
+
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Invalid credentials")
+		attempt.failed(w)
 		return
 	}
 
-	if user.Role != models.RoleAdmin {
-		respondError(w, http.StatusUnauthorized, "Invalid credentials")
+	if !user.Role.IsStaff() {
+		attempt.failed(w)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		respondError(w, http.StatusUnauthorized, "Invalid credentials")
+		attempt.failed(w)
 		return
 	}
 
-	token, err := generateToken(user)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to generate token")
+	if user.Status != models.StatusActive {
+		respondError(w, http.StatusForbidden, "Account is not active")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, models.LoginResponse{
-		Token:              token,
-		User:               *user,
-		MustChangePassword: user.MustChangePassword,
-	})
+	// Staff with 2FA get a challenge instead of a session; the failure counter is only
+	// cleared once the second factor is verified
+	if challenged := beginTwoFactor(w, user); challenged {
+		return
+	}
+
+	attempt.succeeded()
+	startSession(w, user)
 }
 
 // LoginStudent handles student login with NIS & password
@@ -88,33 +91,39 @@ func LoginStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	} 
 
-	user, err := database.GetUserByNIS(req.NIS)
+	school, ok := loginSchool(w, req.SchoolCode)
+	if !ok {
+		return
+	}
+
+	attempt := newLoginAttempt(r, models.LoginScopeStudent, school.ID, req.NIS)
+	if !attempt.allowed(w) {
+		return
+	}
+
+	user, err := database.GetUserByNIS(school.ID, req.NIS)
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Invalid credentials")
+		attempt.failed(w)
 		return
 	}
 
 	if user.Role != models.RoleStudent {
-		respondError(w, http.StatusUnauthorized, "Invalid credentials")
+		attempt.failed(w)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		respondError(w, http.StatusUnauthorized, "Invalid credentials")
+		attempt.failed(w)
 		return
 	}
 
-	token, err := generateToken(user)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to generate token")
+	if user.Status != models.StatusActive {
+		respondError(w, http.StatusForbidden, "Account is not active")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, models.LoginResponse{
-		Token:              token,
-		User:               *user,
-		MustChangePassword: user.MustChangePassword,
-	})
+	attempt.succeeded()
+	startSession(w, user)
 }
 
 // ChangePassword handles password change for authenticated users
@@ -164,26 +173,218 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Every session is logged out, including the one the change was made from: it may hold a
+	// token restricted to this password change, so a new session with full access replaces it
 	if err := database.UpdatePassword(userID, string(hashedPassword)); err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to update password")
 		return
 	}
+	user.MustChangePassword = false
+
+	auditOwnAccount(r, user, models.AuditChangePassword)
+	startSession(w, user)
+}
+
+// GetProfile returns the logged-in user's own account
+func GetProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"message": "Kata sandi berhasil diubah"})
+	userID, _ := r.Context().Value("user_id").(int64)
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, user)
 }
 
-func generateToken(user *models.User) (string, error) {
-	claims := &Claims{
-		UserID: user.ID,
-		Role:   user.Role,
+// loginSchool resolves the school a student or parent logs in to. The code may be left out
+// while the deployment has a single school.
+func loginSchool(w http.ResponseWriter, code string) (*models.School, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code != "" {
+		school, err := database.GetSchoolByCode(code)
+		if err != nil {
+			respondError(w, http.StatusUnauthorized, "Invalid credentials")
+			return nil, false
+		}
+		return school, true
+	}
+
+	schools, err := database.GetAllSchools()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch schools")
+		return nil, false
+	}
+	if len(schools) != 1 {
+		respondError(w, http.StatusBadRequest, "school_code is required")
+		return nil, false
+	}
+	return &schools[0], true
+}
+
+// startSession opens a login session for an authenticated user and responds with its
+// access and refresh tokens
+func startSession(w http.ResponseWriter, user *models.User) {
+	resp, err := newSession(user)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// newSession opens a login session for an authenticated user and returns its tokens
+func newSession(user *models.User) (*models.LoginResponse, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := database.CreateSession(user.ID, refreshToken, time.Now().Add(config.AppConfig.RefreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenResponse(user, sessionID, refreshToken)
+}
+
+// respondTokens signs an access token for a session and responds with it and the session's
+// current refresh token
+func respondTokens(w http.ResponseWriter, user *models.User, sessionID int64, refreshToken string) {
+	resp, err := tokenResponse(user, sessionID, refreshToken)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func tokenResponse(user *models.User, sessionID int64, refreshToken string) (*models.LoginResponse, error) {
+	token, err := generateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResponse{
+		Token:              token,
+		RefreshToken:       refreshToken,
+		ExpiresIn:          int64(config.AppConfig.AccessTokenTTL.Seconds()),
+		User:               *user,
+		MustChangePassword: user.MustChangePassword,
+	}, nil
+}
+
+// newRefreshToken returns a random, URL-safe refresh token
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh token.
+// Each refresh token works once; reusing an old one ends the session.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.RefreshToken == "" {
+		respondError(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	sessionID, userID, err := database.RotateSession(req.RefreshToken, newToken, time.Now().Add(config.AppConfig.RefreshTokenTTL))
+	if err != nil {
+		if err == database.ErrInvalidRefreshToken || err == database.ErrRefreshTokenReused {
+			respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	user, err := database.GetUserByID(userID)
+	if err != nil || user.Status != models.StatusActive {
+		database.RevokeSession(sessionID, userID)
+		respondError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+
+	respondTokens(w, user, sessionID, newToken)
+}
+
+// Logout ends the session the request was made with
+func Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, _ := r.Context().Value("user_id").(int64)
+	sessionID, _ := r.Context().Value("session_id").(int64)
+	if err := database.RevokeSession(sessionID, userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Berhasil keluar"})
+}
+
+// LogoutAll ends every session of the logged-in user, on every device
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, _ := r.Context().Value("user_id").(int64)
+	if err := database.RevokeUserSessions(userID, 0); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Berhasil keluar dari semua perangkat"})
+}
+
+func generateToken(user *models.User, sessionID int64) (string, error) {
+	claims := &tokens.Claims{
+		UserID:    user.ID,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.AppConfig.AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
+	if user.SchoolID != nil {
+		claims.SchoolID = *user.SchoolID
+	}
+	if user.MustChangePassword {
+		claims.Scope = models.TokenScopePasswordChange
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(getJWTSecret())
+	return tokens.Sign(claims)
 }
 
-
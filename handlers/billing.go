@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+)
+
+// GetFeeCategories returns all fee categories (admin only)
+func GetFeeCategories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	categories, err := database.GetAllFeeCategories(callerSchool(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch fee categories")
+		return
+	}
+
+	if categories == nil {
+		categories = []models.FeeCategory{}
+	}
+
+	respondJSON(w, http.StatusOK, categories)
+}
+
+// CreateFeeCategory creates a new fee category (admin only)
+func CreateFeeCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateFeeCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Code == "" || req.Name == "" {
+		respondError(w, http.StatusBadRequest, "Code and name are required")
+		return
+	}
+	if req.DefaultNominal < 0 {
+		respondError(w, http.StatusBadRequest, "Nominal must be greater than 0")
+		return
+	}
+
+	category, err := database.CreateFeeCategory(callerSchool(r), req)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create fee category: "+err.Error())
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityFeeCategory, category.ID, nil, category)
+	respondJSON(w, http.StatusCreated, category)
+}
+
+// GenerateBills creates bills for every student (or every student of one class)
+// for a fee category and period (admin only)
+func GenerateBills(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.GenerateBillsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Periode = strings.TrimSpace(req.Periode)
+	if req.FeeCategoryID == 0 || req.Periode == "" {
+		respondError(w, http.StatusBadRequest, "fee_category_id and periode are required")
+		return
+	}
+	if _, err := time.Parse("2006-01-02", req.JatuhTempo); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid jatuh_tempo, expected YYYY-MM-DD")
+		return
+	}
+
+	category, err := database.GetFeeCategoryByID(callerSchool(r), req.FeeCategoryID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Fee category not found")
+		return
+	}
+
+	if req.Nominal == 0 {
+		req.Nominal = category.DefaultNominal
+	}
+	if req.Nominal <= 0 {
+		respondError(w, http.StatusBadRequest, "Nominal must be greater than 0")
+		return
+	}
+
+	if req.ClassID != nil {
+		if _, err := database.GetClassByID(callerSchool(r), *req.ClassID); err != nil {
+			respondError(w, http.StatusNotFound, "Class not found")
+			return
+		}
+	}
+
+	result, err := database.GenerateBills(callerSchool(r), req)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate bills: "+err.Error())
+		return
+	}
+
+	audit(r, models.AuditGenerate, models.EntityBill, 0, nil, map[string]any{"request": req, "result": result})
+	respondJSON(w, http.StatusCreated, result)
+}
+
+// GetBillsByUser returns all bills of a student (admin only)
+func GetBillsByUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		respondError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user_id")
+		return
+	}
+
+	if _, ok := visibleStudent(w, r, userID); !ok {
+		return
+	}
+
+	bills, err := database.GetBillsByUserID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch bills")
+		return
+	}
+
+	if bills == nil {
+		bills = []models.Bill{}
+	}
+
+	respondJSON(w, http.StatusOK, bills)
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+)
+
+var academicYearPattern = regexp.MustCompile(`^\d{4}/\d{4}$`)
+
+// GetClasses returns all classes, optionally limited to one academic year (admin only)
+func GetClasses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	academicYearID, err := parseOptionalID(r.URL.Query().Get("academic_year_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid academic_year_id")
+		return
+	}
+
+	classes, err := database.GetAllClasses(callerSchool(r), academicYearID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch classes")
+		return
+	}
+
+	if classes == nil {
+		classes = []models.Class{}
+	}
+
+	respondJSON(w, http.StatusOK, classes)
+}
+
+// CreateClass creates a new class (admin only)
+func CreateClass(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateClassRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.HomeroomTeacher = strings.TrimSpace(req.HomeroomTeacher)
+	if req.Name == "" || req.GradeLevel <= 0 {
+		respondError(w, http.StatusBadRequest, "Name and grade level are required")
+		return
+	}
+
+	if req.AcademicYearID == nil {
+		year, err := database.GetActiveAcademicYear(callerSchool(r))
+		if err != nil && err != database.ErrAcademicYearNotFound {
+			respondError(w, http.StatusInternalServerError, "Failed to fetch academic year")
+			return
+		}
+		if year != nil {
+			req.AcademicYearID = &year.ID
+		}
+	} else if _, err := database.GetAcademicYearByID(callerSchool(r), *req.AcademicYearID); err != nil {
+		respondError(w, http.StatusBadRequest, "Academic year not found")
+		return
+	}
+
+	class, err := database.CreateClass(callerSchool(r), req)
+	if err != nil {
+		if err == database.ErrClassExists {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create class: "+err.Error())
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityClass, class.ID, nil, class)
+	respondJSON(w, http.StatusCreated, class)
+}
+
+// GetAcademicYears returns all academic years (admin only)
+func GetAcademicYears(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	years, err := database.GetAllAcademicYears(callerSchool(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch academic years")
+		return
+	}
+
+	if years == nil {
+		years = []models.AcademicYear{}
+	}
+
+	respondJSON(w, http.StatusOK, years)
+}
+
+// CreateAcademicYear creates an academic year, optionally making it the active one (admin only)
+func CreateAcademicYear(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateAcademicYearRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if !academicYearPattern.MatchString(req.Name) {
+		respondError(w, http.StatusBadRequest, "Name must look like 2024/2025")
+		return
+	}
+
+	start, err := time.Parse(dateLayout, req.StartDate)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid start_date")
+		return
+	}
+	end, err := time.Parse(dateLayout, req.EndDate)
+	if err != nil || !end.After(start) {
+		respondError(w, http.StatusBadRequest, "Invalid end_date")
+		return
+	}
+
+	year, err := database.CreateAcademicYear(callerSchool(r), req)
+	if err != nil {
+		if err == database.ErrAcademicYearExists {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create academic year: "+err.Error())
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityAcademicYear, year.ID, nil, year)
+	respondJSON(w, http.StatusCreated, year)
+}
+
+// ActivateAcademicYear makes an academic year the active one (admin only)
+func ActivateAcademicYear(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if _, err := database.GetAcademicYearByID(callerSchool(r), req.ID); err != nil {
+		respondError(w, http.StatusNotFound, "Academic year not found")
+		return
+	}
+
+	if err := database.ActivateAcademicYear(callerSchool(r), req.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to activate academic year")
+		return
+	}
+
+	audit(r, models.AuditActivate, models.EntityAcademicYear, req.ID, nil, nil)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Academic year activated"})
+}
+
+// GetEnrollments returns a student's enrollment history (admin only)
+func GetEnrollments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, err := parseOptionalID(r.URL.Query().Get("user_id"))
+	if err != nil || userID == 0 {
+		respondError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+
+	if _, ok := visibleStudent(w, r, userID); !ok {
+		return
+	}
+
+	enrollments, err := database.GetEnrollmentsByUserID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch enrollments")
+		return
+	}
+
+	if enrollments == nil {
+		enrollments = []models.Enrollment{}
+	}
+
+	respondJSON(w, http.StatusOK, enrollments)
+}
+
+// EnrollStudent enrolls a student in a class or moves them to another one, keeping the
+// previous enrollment in the history (admin only)
+func EnrollStudent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.EnrollStudentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.UserID == 0 || req.ClassID == 0 {
+		respondError(w, http.StatusBadRequest, "user_id and class_id are required")
+		return
+	}
+
+	if req.StartDate == "" {
+		req.StartDate = time.Now().Format(dateLayout)
+	} else if _, err := time.Parse(dateLayout, req.StartDate); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid start_date")
+		return
+	}
+
+	user, err := database.GetSchoolUser(callerSchool(r), req.UserID)
+	if err != nil || user.Role != models.RoleStudent {
+		respondError(w, http.StatusNotFound, "Student not found")
+		return
+	}
+
+	if _, err := database.GetClassByID(callerSchool(r), req.ClassID); err != nil {
+		respondError(w, http.StatusBadRequest, "Class not found")
+		return
+	}
+
+	if err := database.EnrollStudent(req); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to enroll student: "+err.Error())
+		return
+	}
+	audit(r, models.AuditCreate, models.EntityEnrollment, req.UserID, nil, req)
+
+	enrollments, err := database.GetEnrollmentsByUserID(req.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch enrollments")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, enrollments)
+}
+
+// activeAcademicYearID returns the ID of a school's active academic year, or 0 when none is set
+func activeAcademicYearID(schoolID int64) (int64, error) {
+	year, err := database.GetActiveAcademicYear(schoolID)
+	if err == database.ErrAcademicYearNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return year.ID, nil
+}
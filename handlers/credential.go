@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"komite-sekolah/config"
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+	"komite-sekolah/pdf"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// GenerateCredentials gives the students of a class, or the listed students, new generated
+// passwords they must change at their first login (admin only). The passwords are returned
+// once, as JSON or with ?format=pdf as printable slips; only their hashes are kept.
+func GenerateCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.GenerateCredentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.ClassID == 0 && len(req.UserIDs) == 0 {
+		respondError(w, http.StatusBadRequest, "class_id or user_ids is required")
+		return
+	}
+	if req.ClassID != 0 {
+		if _, err := database.GetClassByID(callerSchool(r), req.ClassID); err != nil {
+			respondError(w, http.StatusNotFound, "Class not found")
+			return
+		}
+	}
+
+	school, err := database.GetSchoolByID(callerSchool(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch schools")
+		return
+	}
+
+	students, err := database.GetCredentialStudents(callerSchool(r), req.ClassID, req.UserIDs, req.OnlyPending)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch students")
+		return
+	}
+	if len(students) == 0 {
+		respondError(w, http.StatusNotFound, "No students found")
+		return
+	}
+
+	slips := make([]models.CredentialSlip, len(students))
+	hashes := make(map[int64]string, len(students))
+	for i := range students {
+		password, err := generatePassword()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to generate password")
+			return
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to hash password")
+			return
+		}
+		hashes[students[i].ID] = string(hashed)
+		slips[i] = credentialSlip(&students[i], school, password)
+	}
+
+	if err := database.ResetPasswords(callerSchool(r), hashes); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	userIDs := make([]int64, len(students))
+	for i := range students {
+		userIDs[i] = students[i].ID
+	}
+	audit(r, models.AuditResetPassword, models.EntityStudent, 0, nil, map[string]any{"class_id": req.ClassID, "user_ids": userIDs})
+
+	// The passwords cannot be shown again, so no proxy or browser should keep a copy
+	w.Header().Set("Cache-Control", "no-store")
+	if r.URL.Query().Get("format") == "pdf" {
+		writeCredentialSlips(w, school, slips)
+		return
+	}
+	respondJSON(w, http.StatusOK, models.CredentialSlipsResponse{Slips: slips})
+}
+
+func credentialSlip(student *models.User, school *models.School, password string) models.CredentialSlip {
+	return models.CredentialSlip{
+		UserID:     student.ID,
+		Name:       student.Name,
+		NIS:        student.NIS,
+		ClassName:  student.ClassName,
+		SchoolCode: school.Code,
+		Password:   password,
+		LoginURL:   config.AppConfig.StudentLoginURL,
+	}
+}
+
+// Credential slips are laid out two across and five down on A4, separated by dashed cut lines
+const (
+	slipColumns = 2
+	slipRows    = 5
+	slipPadding = 20
+)
+
+// writeCredentialSlips responds with a PDF of slips to cut out and hand to the students
+func writeCredentialSlips(w http.ResponseWriter, school *models.School, slips []models.CredentialSlip) {
+	doc := pdf.New()
+	width := pdf.PageWidth / slipColumns
+	height := pdf.PageHeight / slipRows
+
+	for i, slip := range slips {
+		if i%(slipColumns*slipRows) == 0 {
+			doc.AddPage()
+			for c := 1; c < slipColumns; c++ {
+				doc.Line(float64(c)*width, 0, float64(c)*width, pdf.PageHeight, 0.5, 3)
+			}
+			for r := 1; r < slipRows; r++ {
+				doc.Line(0, float64(r)*height, pdf.PageWidth, float64(r)*height, 0.5, 3)
+			}
+		}
+
+		n := i % (slipColumns * slipRows)
+		x := float64(n%slipColumns)*width + slipPadding
+		y := float64(n/slipColumns) * height
+		valueX := x + 75
+		valueWidth := width - 2*slipPadding - 75
+
+		doc.Text(x, y+24, pdf.HelveticaBold, 10, fitText(pdf.HelveticaBold, 10, school.Name, width-2*slipPadding))
+		doc.Text(x, y+38, pdf.Helvetica, 8, "Kartu Akun Siswa")
+		for j, field := range [][2]string{
+			{"Nama", slip.Name},
+			{"NIS", slip.NIS},
+			{"Kelas", slip.ClassName},
+			{"Kode sekolah", slip.SchoolCode},
+		} {
+			doc.Text(x, y+58+float64(j)*15, pdf.Helvetica, 9, field[0])
+			doc.Text(valueX, y+58+float64(j)*15, pdf.Helvetica, 9, fitText(pdf.Helvetica, 9, field[1], valueWidth))
+		}
+		doc.Text(x, y+123, pdf.Helvetica, 9, "Kata sandi")
+		doc.Text(valueX, y+123, pdf.CourierBold, 13, slip.Password)
+		doc.Text(x, y+141, pdf.Helvetica, 8, fitText(pdf.Helvetica, 8, "Masuk di "+slip.LoginURL, width-2*slipPadding))
+		doc.Text(x, y+154, pdf.Helvetica, 7.5, "Kata sandi sementara, wajib diganti saat masuk pertama.")
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="kartu-akun-siswa.pdf"`)
+	doc.WriteTo(w)
+}
+
+// fitText shortens s with "..." until it fits in width points
+func fitText(font pdf.Font, size float64, s string, width float64) string {
+	runes := []rune(s)
+	if pdf.TextWidth(font, size, s) <= width {
+		return s
+	}
+	for len(runes) > 0 && pdf.TextWidth(font, size, string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
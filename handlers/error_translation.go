@@ -44,6 +44,202 @@ func respondError(w http.ResponseWriter, status int, message string) {
 		"Password changed successfully": "Kata sandi berhasil diubah",
 		"Nominal must be greater than 0": "Nominal harus lebih besar dari 0",
 		"Tanggal is required": "Tanggal diperlukan",
+		"Class not found": "Kelas tidak ditemukan",
+		"Failed to fetch classes": "Gagal mengambil data kelas",
+		"Failed to create class: ": "Gagal membuat kelas: ",
+		"Name and grade level are required": "Nama dan tingkat kelas diperlukan",
+		"Fee category not found": "Kategori iuran tidak ditemukan",
+		"Failed to fetch fee categories": "Gagal mengambil kategori iuran",
+		"Failed to create fee category: ": "Gagal membuat kategori iuran: ",
+		"Code and name are required": "Kode dan nama diperlukan",
+		"fee_category_id and periode are required": "fee_category_id dan periode diperlukan",
+		"Invalid jatuh_tempo, expected YYYY-MM-DD": "jatuh_tempo tidak valid, gunakan format YYYY-MM-DD",
+		"Failed to generate bills: ": "Gagal membuat tagihan: ",
+		"Failed to fetch bills": "Gagal mengambil data tagihan",
+		"Bill does not belong to this student": "Tagihan bukan milik siswa ini",
+		"Failed to build dashboard": "Gagal menyusun dasbor",
+		"Invalid as_of, expected YYYY-MM-DD": "as_of tidak valid, gunakan format YYYY-MM-DD",
+		"Invalid group_by": "group_by tidak valid",
+		"Invalid buckets": "Rentang umur piutang tidak valid",
+		"Invalid class_id": "class_id tidak valid",
+		"Invalid fee_category_id": "fee_category_id tidak valid",
+		"Failed to build aging report": "Gagal menyusun laporan umur piutang",
+		"Invalid metode": "Metode pembayaran tidak valid",
+		"Failed to fetch accounts": "Gagal mengambil daftar akun",
+		"Failed to create account: ": "Gagal membuat akun: ",
+		"Invalid account type": "Jenis akun tidak valid",
+		"Only asset accounts can be cash accounts": "Hanya akun aset yang dapat menjadi akun kas",
+		"Keterangan is required": "Keterangan diperlukan",
+		"Transfer accounts must be two different cash accounts": "Pemindahbukuan harus antara dua akun kas yang berbeda",
+		"Invalid date range, expected YYYY-MM-DD": "Rentang tanggal tidak valid, gunakan format YYYY-MM-DD",
+		"Invalid account_id": "account_id tidak valid",
+		"account_id is required": "account_id diperlukan",
+		"Account not found": "Akun tidak ditemukan",
+		"Failed to fetch ledger": "Gagal mengambil buku besar",
+		"Journal entry is not balanced": "Jurnal tidak seimbang",
+		"Each journal line needs an account and either a debit or a credit": "Setiap baris jurnal memerlukan akun serta debit atau kredit",
+		"Failed to post journal entry: ": "Gagal mencatat jurnal: ",
+		"Failed to fetch budget lines": "Gagal mengambil mata anggaran",
+		"Invalid tahun_ajaran, expected YYYY/YYYY": "tahun_ajaran tidak valid, gunakan format YYYY/YYYY",
+		"Budget lines must use an expense account": "Mata anggaran harus menggunakan akun beban",
+		"Failed to create budget line: ": "Gagal membuat mata anggaran: ",
+		"Budget line not found": "Mata anggaran tidak ditemukan",
+		"Failed to fetch expense requests": "Gagal mengambil pengajuan dana",
+		"budget_line_id and judul are required": "budget_line_id dan judul diperlukan",
+		"Failed to create expense request: ": "Gagal membuat pengajuan dana: ",
+		"Invalid expense_id": "expense_id tidak valid",
+		"expense_id is required": "expense_id diperlukan",
+		"Expense request not found": "Pengajuan dana tidak ditemukan",
+		"Expense request is not awaiting approval": "Pengajuan dana tidak sedang menunggu persetujuan",
+		"Expense request is not approved": "Pengajuan dana belum disetujui",
+		"Expense exceeds the remaining budget": "Pengajuan melebihi sisa anggaran",
+		"File is required": "Berkas diperlukan",
+		"File is too large": "Berkas terlalu besar",
+		"Failed to store file": "Gagal menyimpan berkas",
+		"Invalid attachment_id": "attachment_id tidak valid",
+		"Attachment not found": "Lampiran tidak ditemukan",
+		"Failed to build budget report": "Gagal menyusun laporan anggaran",
+		"Failed to fetch transparency report": "Gagal mengambil laporan transparansi",
+		"Report has not been published": "Laporan belum dipublikasikan",
+		"periode is required": "periode diperlukan",
+		"Report for this period is already published": "Laporan untuk periode ini sudah dipublikasikan",
+		"Failed to publish transparency report": "Gagal mempublikasikan laporan transparansi",
+		"Unsupported file format, expected .csv or .xlsx": "Format berkas tidak didukung, gunakan .csv atau .xlsx",
+		"Failed to read file: ": "Gagal membaca berkas: ",
+		"File has no data rows": "Berkas tidak berisi data",
+		"Too many rows in file": "Jumlah baris dalam berkas terlalu banyak",
+		"Missing required column: ": "Kolom wajib tidak ada: ",
+		"Failed to validate import": "Gagal memvalidasi data impor",
+		"No valid rows to import": "Tidak ada baris valid untuk diimpor",
+		"Failed to import students: ": "Gagal mengimpor siswa: ",
+		"NIS, virtual account, and name cannot be empty": "NIS, nomor VA, dan nama tidak boleh kosong",
+		"NIS is already registered": "NIS sudah terdaftar",
+		"Virtual account is already registered": "Nomor VA sudah terdaftar",
+		"NIS or virtual account is already registered": "NIS atau nomor VA sudah terdaftar",
+		"Failed to update student: ": "Gagal memperbarui data siswa: ",
+		"Invalid status": "Status tidak valid",
+		"Failed to update status": "Gagal memperbarui status",
+		"Account is not active": "Akun tidak aktif",
+		"Invalid academic_year_id": "academic_year_id tidak valid",
+		"Failed to fetch academic year": "Gagal mengambil tahun ajaran",
+		"Failed to fetch academic years": "Gagal mengambil daftar tahun ajaran",
+		"Academic year not found": "Tahun ajaran tidak ditemukan",
+		"Academic year already exists": "Tahun ajaran sudah ada",
+		"Class already exists in this academic year": "Kelas sudah ada pada tahun ajaran ini",
+		"Name must look like 2024/2025": "Nama harus berformat seperti 2024/2025",
+		"Invalid start_date": "start_date tidak valid",
+		"Invalid end_date": "end_date tidak valid",
+		"Failed to create academic year: ": "Gagal membuat tahun ajaran: ",
+		"Failed to activate academic year": "Gagal mengaktifkan tahun ajaran",
+		"Failed to fetch enrollments": "Gagal mengambil riwayat kelas",
+		"user_id and class_id are required": "user_id dan class_id diperlukan",
+		"Student not found": "Siswa tidak ditemukan",
+		"Failed to enroll student: ": "Gagal memasukkan siswa ke kelas: ",
+		"Phone or email and password are required": "Nomor HP atau email dan kata sandi diperlukan",
+		"Name, password, and phone or email are required": "Nama, kata sandi, dan nomor HP atau email diperlukan",
+		"Invalid phone number": "Nomor HP tidak valid",
+		"Invalid email": "Email tidak valid",
+		"Phone number is already registered": "Nomor HP sudah terdaftar",
+		"Email is already registered": "Email sudah terdaftar",
+		"Failed to create parent: ": "Gagal membuat akun orang tua: ",
+		"Failed to fetch parents": "Gagal mengambil daftar orang tua",
+		"parent_id and student_id are required": "parent_id dan student_id diperlukan",
+		"Parent not found": "Orang tua tidak ditemukan",
+		"Failed to link parent": "Gagal menghubungkan orang tua dengan siswa",
+		"Failed to unlink parent": "Gagal memutus hubungan orang tua dengan siswa",
+		"Failed to fetch children": "Gagal mengambil data anak",
+		"Parent access required": "Akses orang tua diperlukan",
+		"Invalid student_id": "student_id tidak valid",
+		"Invalid must_change_password": "must_change_password tidak valid",
+		"Invalid page": "Nomor halaman tidak valid",
+		"Invalid page_size": "Ukuran halaman tidak valid",
+		"Invalid sort field": "Kolom pengurutan tidak valid",
+		"Invalid cursor": "Cursor tidak valid",
+		"Invalid date range": "Rentang tanggal tidak valid",
+		"Invalid nominal range": "Rentang nominal tidak valid",
+		"Invalid limit": "Batas jumlah data tidak valid",
+		"Your role cannot decide this approval step": "Peran Anda tidak dapat memutuskan tahap persetujuan ini",
+		"Your role cannot override the budget": "Peran Anda tidak dapat melampaui pagu anggaran",
+		"Invalid role": "Peran tidak valid",
+		"class_id is required for wali_kelas": "class_id diperlukan untuk wali kelas",
+		"Staff account not found": "Akun staf tidak ditemukan",
+		"Cannot remove the last active super admin": "Super admin aktif terakhir tidak dapat dihapus",
+		"Failed to assign role": "Gagal mengubah peran",
+		"Permission denied": "Akses ditolak",
+		"Failed to fetch staff": "Gagal mengambil daftar staf",
+		"Username, name, and password are required": "Username, nama, dan kata sandi diperlukan",
+		"Invalid username": "Username tidak valid",
+		"Username is already taken": "Username sudah digunakan",
+		"Failed to create staff: ": "Gagal membuat akun staf: ",
+		"School not found": "Sekolah tidak ditemukan",
+		"School code is already taken": "Kode sekolah sudah digunakan",
+		"school_code is required": "school_code diperlukan",
+		"Invalid school code": "Kode sekolah tidak valid",
+		"Failed to fetch schools": "Gagal mengambil daftar sekolah",
+		"Failed to create school: ": "Gagal membuat sekolah: ",
+		"Failed to build foundation report": "Gagal menyusun laporan yayasan",
+		"refresh_token is required": "refresh_token diperlukan",
+		"Invalid refresh token": "Refresh token tidak valid",
+		"Refresh token has already been used": "Refresh token sudah pernah digunakan, silakan masuk kembali",
+		"Failed to log out": "Gagal keluar",
+		"Too many failed login attempts, try again later": "Terlalu banyak percobaan masuk yang gagal, coba lagi nanti",
+		"Failed to check login attempts": "Gagal memeriksa percobaan masuk",
+		"Failed to fetch lockouts": "Gagal mengambil daftar akun terkunci",
+		"id is required": "id diperlukan",
+		"Lockout not found": "Data penguncian tidak ditemukan",
+		"Failed to clear lockout": "Gagal membuka penguncian",
+		"Invalid channel": "Kanal pengiriman tidak valid",
+		"NIS, phone or email is required": "NIS, nomor telepon, atau email diperlukan",
+		"Failed to send recovery code": "Gagal mengirim kode pemulihan",
+		"Too many reset requests, try again later": "Terlalu banyak permintaan kode pemulihan, coba lagi nanti",
+		"Code and new password are required": "Kode dan kata sandi baru diperlukan",
+		"Invalid or expired code": "Kode tidak valid atau sudah kedaluwarsa",
+		"Failed to check two-factor authentication": "Gagal memeriksa autentikasi dua langkah",
+		"Failed to set up two-factor authentication": "Gagal menyiapkan autentikasi dua langkah",
+		"challenge_token and code are required": "challenge_token dan kode diperlukan",
+		"Invalid or expired challenge": "Tantangan tidak valid atau sudah kedaluwarsa",
+		"Failed to verify two-factor code": "Gagal memverifikasi kode dua langkah",
+		"Invalid two-factor code": "Kode dua langkah tidak valid",
+		"Two-factor authentication is already enabled": "Autentikasi dua langkah sudah aktif",
+		"Two-factor authentication is not enabled": "Autentikasi dua langkah belum aktif",
+		"Two-factor authentication is required for your role": "Autentikasi dua langkah wajib untuk peran Anda",
+		"Two-factor authentication is only available for staff accounts": "Autentikasi dua langkah hanya tersedia untuk akun staf",
+		"Two-factor enrollment has not been started": "Pendaftaran autentikasi dua langkah belum dimulai",
+		"code is required": "kode diperlukan",
+		"Password and code are required": "Kata sandi dan kode diperlukan",
+		"Password is incorrect": "Kata sandi salah",
+		"Failed to disable two-factor authentication": "Gagal menonaktifkan autentikasi dua langkah",
+		"Failed to generate recovery codes": "Gagal membuat kode pemulihan",
+		"Failed to fetch two-factor policy": "Gagal mengambil kebijakan autentikasi dua langkah",
+		"Failed to update two-factor policy": "Gagal memperbarui kebijakan autentikasi dua langkah",
+		"Failed to fetch audit log": "Gagal mengambil log audit",
+		"Invalid actor_id": "actor_id tidak valid",
+		"Invalid entity_id": "entity_id tidak valid",
+		"Failed to verify payment chain": "Gagal memverifikasi rantai pembayaran",
+		"Failed to fetch payment anchors": "Gagal mengambil hash jangkar pembayaran",
+		"NIS, virtual account, and name are required": "NIS, nomor VA, dan nama wajib diisi",
+		"Password must not be the NIS": "Kata sandi tidak boleh sama dengan NIS",
+		"Failed to generate password": "Gagal membuat kata sandi",
+		"class_id or user_ids is required": "class_id atau user_ids diperlukan",
+		"No students found": "Siswa tidak ditemukan",
+		"Failed to start impersonation": "Gagal memulai sesi lihat sebagai siswa",
+		"Failed to end impersonation": "Gagal mengakhiri sesi lihat sebagai siswa",
+		"Not an impersonation session": "Bukan sesi lihat sebagai siswa",
+		"Impersonation not found": "Sesi lihat sebagai siswa tidak ditemukan",
+		"Failed to fetch API keys": "Gagal mengambil data API key",
+		"name is required (max 100 characters)": "Nama wajib diisi (maksimal 100 karakter)",
+		"permissions is required": "permissions diperlukan",
+		"Permission cannot be granted to an API key: ": "Izin tidak dapat diberikan kepada API key: ",
+		"Invalid IP address or range in allowed_ips": "Alamat IP atau rentang pada allowed_ips tidak valid",
+		"Invalid expires_on, expected YYYY-MM-DD": "expires_on tidak valid, gunakan format YYYY-MM-DD",
+		"expires_on must not be in the past": "expires_on tidak boleh tanggal yang sudah lewat",
+		"Failed to generate API key": "Gagal membuat API key",
+		"Failed to create API key": "Gagal membuat API key",
+		"API key not found": "API key tidak ditemukan",
+		"Failed to revoke API key": "Gagal mencabut API key",
+		"API key already revoked": "API key sudah dicabut",
+		"You cannot decide your own expense request": "Anda tidak dapat memutuskan pengajuan dana Anda sendiri",
+		"You already decided another step of this expense request": "Anda sudah memutuskan tahap lain dari pengajuan dana ini",
 	}
 
 	// Exact match translation
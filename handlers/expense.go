@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"komite-sekolah/config"
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+)
+
+var tahunAjaranPattern = regexp.MustCompile(`^\d{4}/\d{4}$`)
+
+// GetBudgetLines returns the budget lines of an academic year, defaulting to the current one (admin only)
+func GetBudgetLines(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tahunAjaran := r.URL.Query().Get("tahun_ajaran")
+	if tahunAjaran == "" {
+		tahunAjaran = academicYearLabel(time.Now())
+	}
+
+	lines, err := database.GetBudgetLines(callerSchool(r), tahunAjaran)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch budget lines")
+		return
+	}
+
+	if lines == nil {
+		lines = []models.BudgetLine{}
+	}
+
+	respondJSON(w, http.StatusOK, lines)
+}
+
+// CreateBudgetLine creates a budget line (admin only)
+func CreateBudgetLine(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateBudgetLineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Code == "" || req.Name == "" {
+		respondError(w, http.StatusBadRequest, "Code and name are required")
+		return
+	}
+	if !tahunAjaranPattern.MatchString(req.TahunAjaran) {
+		respondError(w, http.StatusBadRequest, "Invalid tahun_ajaran, expected YYYY/YYYY")
+		return
+	}
+	if req.Pagu <= 0 {
+		respondError(w, http.StatusBadRequest, "Nominal must be greater than 0")
+		return
+	}
+
+	account, err := database.GetAccountByID(callerSchool(r), req.AccountID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Account not found")
+		return
+	}
+	if account.Type != models.AccountExpense {
+		respondError(w, http.StatusBadRequest, "Budget lines must use an expense account")
+		return
+	}
+
+	line, err := database.CreateBudgetLine(callerSchool(r), req)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create budget line: "+err.Error())
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityBudgetLine, line.ID, nil, line)
+	respondJSON(w, http.StatusCreated, line)
+}
+
+// GetExpenses lists expense requests, optionally filtered by status and tahun_ajaran (admin only)
+func GetExpenses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	expenses, err := database.GetExpenseRequests(callerSchool(r), r.URL.Query().Get("status"), r.URL.Query().Get("tahun_ajaran"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch expense requests")
+		return
+	}
+
+	if expenses == nil {
+		expenses = []models.ExpenseRequest{}
+	}
+
+	respondJSON(w, http.StatusOK, expenses)
+}
+
+// CreateExpense submits a new expense request (pengajuan dana) against a budget line (admin only)
+func CreateExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.CreateExpenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Judul = strings.TrimSpace(req.Judul)
+	if req.BudgetLineID == 0 || req.Judul == "" {
+		respondError(w, http.StatusBadRequest, "budget_line_id and judul are required")
+		return
+	}
+	if req.Nominal <= 0 {
+		respondError(w, http.StatusBadRequest, "Nominal must be greater than 0")
+		return
+	}
+
+	if _, err := database.GetBudgetLineByID(callerSchool(r), req.BudgetLineID); err != nil {
+		respondError(w, http.StatusNotFound, "Budget line not found")
+		return
+	}
+
+	expense, err := database.CreateExpenseRequest(callerSchool(r), req, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create expense request: "+err.Error())
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityExpense, expense.ID, nil, expense)
+	respondJSON(w, http.StatusCreated, expense)
+}
+
+// GetExpense returns one expense request with approvals, attachments and disbursement (admin only)
+func GetExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	expenseID, err := strconv.ParseInt(r.URL.Query().Get("expense_id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid expense_id")
+		return
+	}
+
+	expense, err := database.GetExpenseRequestByID(callerSchool(r), expenseID)
+	if err != nil {
+		if err == database.ErrExpenseNotFound {
+			respondError(w, http.StatusNotFound, "Expense request not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to fetch expense requests")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, expense)
+}
+
+// DecideExpense approves or rejects the approval step an expense request is waiting for.
+// Each step can only be decided by a role holding that step's permission, and by someone who
+// neither made the request nor decided an earlier step.
+func DecideExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.ExpenseDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.ExpenseID == 0 {
+		respondError(w, http.StatusBadRequest, "expense_id is required")
+		return
+	}
+
+	expense, err := database.DecideExpenseRequest(callerSchool(r), req, userID, callerRole(r))
+	if err != nil {
+		respondExpenseError(w, err)
+		return
+	}
+	audit(r, models.AuditDecide, models.EntityExpense, expense.ID, nil, map[string]any{"decision": req, "expense": expense})
+
+	respondJSON(w, http.StatusOK, expense)
+}
+
+// DisburseExpense pays out a fully approved expense request and posts it to the ledger (admin only)
+func DisburseExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.DisburseExpenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.ExpenseID == 0 {
+		respondError(w, http.StatusBadRequest, "expense_id is required")
+		return
+	}
+	if _, err := time.Parse(dateLayout, req.Tanggal); err != nil {
+		respondError(w, http.StatusBadRequest, "Tanggal is required")
+		return
+	}
+	if req.Metode == "" {
+		req.Metode = models.MetodeTunai
+	}
+	if !validMetode(req.Metode) {
+		respondError(w, http.StatusBadRequest, "Invalid metode")
+		return
+	}
+
+	expense, err := database.DisburseExpenseRequest(callerSchool(r), req, userID)
+	if err != nil {
+		respondExpenseError(w, err)
+		return
+	}
+	audit(r, models.AuditDisburse, models.EntityExpense, expense.ID, nil, expense)
+
+	respondJSON(w, http.StatusOK, expense)
+}
+
+// UploadExpenseAttachment stores a supporting document (receipt, quotation) for an expense request (admin only).
+// Expects multipart/form-data with a "file" field and expense_id in the query string.
+func UploadExpenseAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	expenseID, err := strconv.ParseInt(r.URL.Query().Get("expense_id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid expense_id")
+		return
+	}
+	if _, err := database.GetExpenseRequestByID(callerSchool(r), expenseID); err != nil {
+		respondError(w, http.StatusNotFound, "Expense request not found")
+		return
+	}
+
+	maxSize := config.AppConfig.MaxUploadSizeMB << 20
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+(1<<20))
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "File is required")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxSize {
+		respondError(w, http.StatusRequestEntityTooLarge, "File is too large")
+		return
+	}
+
+	dir := filepath.Join(config.AppConfig.UploadDir, "expenses", strconv.FormatInt(expenseID, 10))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+	fileName := filepath.Base(header.Filename)
+	storagePath := filepath.Join(dir, hex.EncodeToString(suffix)+"-"+sanitizeFileName(fileName))
+
+	dst, err := os.OpenFile(storagePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+	size, err := io.Copy(dst, file)
+	dst.Close()
+	if err != nil {
+		os.Remove(storagePath)
+		respondError(w, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	attachment, err := database.CreateExpenseAttachment(callerSchool(r), expenseID, models.ExpenseAttachment{
+		FileName:    fileName,
+		ContentType: contentType,
+		Size:        size,
+		StoragePath: storagePath,
+		UploadedBy:  userID,
+	})
+	if err != nil {
+		os.Remove(storagePath)
+		respondError(w, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+
+	audit(r, models.AuditUpload, models.EntityExpenseAttachment, expenseID, nil, attachment)
+	respondJSON(w, http.StatusCreated, attachment)
+}
+
+// DownloadExpenseAttachment streams an expense attachment (admin only)
+func DownloadExpenseAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	attachmentID, err := strconv.ParseInt(r.URL.Query().Get("attachment_id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid attachment_id")
+		return
+	}
+
+	attachment, err := database.GetExpenseAttachmentByID(callerSchool(r), attachmentID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Attachment not found")
+		return
+	}
+
+	f, err := os.Open(attachment.StoragePath)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Attachment not found")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+sanitizeFileName(attachment.FileName)+`"`)
+	http.ServeContent(w, r, attachment.FileName, attachment.CreatedAt, f)
+}
+
+// GetBudgetReport returns budget versus actual per budget line for tahun_ajaran, defaulting to the current year (admin only)
+func GetBudgetReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tahunAjaran := r.URL.Query().Get("tahun_ajaran")
+	if tahunAjaran == "" {
+		tahunAjaran = academicYearLabel(time.Now())
+	}
+
+	report, err := database.GetBudgetReport(callerSchool(r), tahunAjaran)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to build budget report")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func sanitizeFileName(name string) string {
+	name = unsafeFileNameChars.ReplaceAllString(name, "_")
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
+
+func respondExpenseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrExpenseNotFound):
+		respondError(w, http.StatusNotFound, "Expense request not found")
+	case errors.Is(err, database.ErrExpenseNotPending):
+		respondError(w, http.StatusConflict, "Expense request is not awaiting approval")
+	case errors.Is(err, database.ErrExpenseNotApproved):
+		respondError(w, http.StatusConflict, "Expense request is not approved")
+	case errors.Is(err, database.ErrOverBudget):
+		respondError(w, http.StatusConflict, "Expense exceeds the remaining budget")
+	case errors.Is(err, database.ErrStepNotPermitted), errors.Is(err, database.ErrOverrideNotPermitted),
+		errors.Is(err, database.ErrOwnExpense), errors.Is(err, database.ErrAlreadyDecided):
+		respondError(w, http.StatusForbidden, err.Error())
+	default:
+		respondLedgerError(w, err)
+	}
+}
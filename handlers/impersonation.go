@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"komite-sekolah/config"
+	"komite-sekolah/database"
+	"komite-sekolah/middleware"
+	"komite-sekolah/models"
+	"komite-sekolah/tokens"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const impersonationCheckInterval = time.Minute
+
+// StartImpersonation issues a short-lived, read-only token to see the app as a student sees
+// it, e.g. while helping a parent on the phone (admin only). The token has its own session,
+// so ending the impersonation or any logout of the student invalidates it at once.
+func StartImpersonation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	staffID, ok := r.Context().Value("user_id").(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.StartImpersonationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.UserID == 0 {
+		respondError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+
+	student, err := database.GetSchoolUser(callerSchool(r), req.UserID)
+	if err != nil || student.Role != models.RoleStudent {
+		respondError(w, http.StatusNotFound, "Student not found")
+		return
+	}
+
+	// The session's refresh token is never handed out, so the impersonation cannot outlive
+	// its access token
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	expiresAt := time.Now().Add(config.AppConfig.ImpersonationTTL)
+	sessionID, err := database.CreateSession(student.ID, refreshToken, expiresAt)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to start impersonation")
+		return
+	}
+
+	imp, err := database.CreateImpersonation(callerSchool(r), staffID, student.ID, sessionID, expiresAt)
+	if err != nil {
+		database.RevokeSession(sessionID, student.ID)
+		respondError(w, http.StatusInternalServerError, "Failed to start impersonation")
+		return
+	}
+
+	token, err := generateImpersonationToken(student, imp)
+	if err != nil {
+		database.EndImpersonation(imp, models.ImpersonationEnded)
+		respondError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	audit(r, models.AuditImpersonateStart, models.EntityStudent, student.ID, nil, imp)
+	respondJSON(w, http.StatusOK, models.ImpersonationResponse{
+		Token:           token,
+		ExpiresIn:       int64(time.Until(expiresAt).Seconds()),
+		ExpiresAt:       expiresAt,
+		ImpersonationID: imp.ID,
+		User:            *student,
+	})
+}
+
+// EndImpersonation ends the impersonation the request's token belongs to. The audit entry
+// names the staff member, not the student whose token was used.
+func EndImpersonation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	staffID, _ := r.Context().Value("impersonated_by").(int64)
+	sessionID, _ := r.Context().Value("session_id").(int64)
+	if staffID == 0 {
+		respondError(w, http.StatusBadRequest, "Not an impersonation session")
+		return
+	}
+
+	imp, err := database.GetImpersonationBySession(sessionID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Impersonation not found")
+		return
+	}
+
+	ended, err := database.EndImpersonation(imp, models.ImpersonationEnded)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to end impersonation")
+		return
+	}
+	if ended {
+		entry := impersonationAuditEntry(imp)
+		entry.IP = middleware.ClientIP(r)
+		entry.UserAgent = r.UserAgent()
+		recordAudit(entry, imp.StudentID, nil, imp)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Sesi lihat sebagai siswa diakhiri"})
+}
+
+// ExpireImpersonations records the end of impersonations whose token ran out without being
+// ended. It runs until the process exits.
+func ExpireImpersonations() {
+	for range time.Tick(impersonationCheckInterval) {
+		expired, err := database.GetExpiredImpersonations()
+		if err != nil {
+			log.Printf("Failed to fetch expired impersonations: %v", err)
+			continue
+		}
+		for i := range expired {
+			ended, err := database.EndImpersonation(&expired[i], models.ImpersonationExpired)
+			if err != nil {
+				log.Printf("Failed to end impersonation %d: %v", expired[i].ID, err)
+				continue
+			}
+			if ended {
+				recordAudit(impersonationAuditEntry(&expired[i]), expired[i].StudentID, nil, expired[i])
+			}
+		}
+	}
+}
+
+// impersonationAuditEntry attributes the end of an impersonation to the staff member who
+// started it
+func impersonationAuditEntry(imp *models.Impersonation) models.AuditLog {
+	entry := models.AuditLog{
+		SchoolID:   &imp.SchoolID,
+		ActorID:    imp.StaffID,
+		Action:     models.AuditImpersonateEnd,
+		EntityType: models.EntityStudent,
+	}
+	if staff, err := database.GetUserByID(imp.StaffID); err == nil {
+		entry.ActorRole = staff.Role
+	}
+	return entry
+}
+
+func generateImpersonationToken(student *models.User, imp *models.Impersonation) (string, error) {
+	claims := &tokens.Claims{
+		UserID:         student.ID,
+		Role:           student.Role,
+		SchoolID:       imp.SchoolID,
+		SessionID:      imp.SessionID,
+		Scope:          models.TokenScopeImpersonation,
+		ImpersonatedBy: imp.StaffID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(imp.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(imp.StartedAt),
+		},
+	}
+	return tokens.Sign(claims)
+}
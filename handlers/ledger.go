@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+)
+
+// GetAccounts returns the school's chart of accounts (admin only)
+func GetAccounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	accounts, err := database.GetAllAccounts(callerSchool(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch accounts")
+		return
+	}
+
+	if accounts == nil {
+		accounts = []models.Account{}
+	}
+
+	respondJSON(w, http.StatusOK, accounts)
+}
+
+// CreateAccount adds an account to the school's chart of accounts (admin only)
+func CreateAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Code == "" || req.Name == "" {
+		respondError(w, http.StatusBadRequest, "Code and name are required")
+		return
+	}
+
+	switch req.Type {
+	case models.AccountAsset, models.AccountLiability, models.AccountEquity, models.AccountIncome, models.AccountExpense:
+	default:
+		respondError(w, http.StatusBadRequest, "Invalid account type")
+		return
+	}
+	if req.IsCash && req.Type != models.AccountAsset {
+		respondError(w, http.StatusBadRequest, "Only asset accounts can be cash accounts")
+		return
+	}
+
+	account, err := database.CreateAccount(callerSchool(r), req)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create account: "+err.Error())
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityAccount, account.ID, nil, account)
+	respondJSON(w, http.StatusCreated, account)
+}
+
+// CreateJournalEntry records a manual balanced journal entry (admin only)
+func CreateJournalEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateJournalEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if _, err := time.Parse(dateLayout, req.Tanggal); err != nil {
+		respondError(w, http.StatusBadRequest, "Tanggal is required")
+		return
+	}
+	if strings.TrimSpace(req.Keterangan) == "" {
+		respondError(w, http.StatusBadRequest, "Keterangan is required")
+		return
+	}
+
+	entry, err := database.CreateJournalEntry(callerSchool(r), req)
+	if err != nil {
+		respondLedgerError(w, err)
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityJournalEntry, entry.ID, nil, entry)
+	respondJSON(w, http.StatusCreated, entry)
+}
+
+// CreateRefund records money returned to a student (admin only)
+func CreateRefund(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateRefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.UserID == 0 {
+		respondError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if _, err := time.Parse(dateLayout, req.Tanggal); err != nil {
+		respondError(w, http.StatusBadRequest, "Tanggal is required")
+		return
+	}
+	if req.Nominal <= 0 {
+		respondError(w, http.StatusBadRequest, "Nominal must be greater than 0")
+		return
+	}
+	if req.Metode == "" {
+		req.Metode = models.MetodeTunai
+	}
+	if !validMetode(req.Metode) {
+		respondError(w, http.StatusBadRequest, "Invalid metode")
+		return
+	}
+
+	if _, err := database.GetSchoolUser(callerSchool(r), req.UserID); err != nil {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	refund, err := database.CreateRefund(callerSchool(r), req)
+	if err != nil {
+		respondLedgerError(w, err)
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityRefund, refund.ID, nil, refund)
+	respondJSON(w, http.StatusCreated, refund)
+}
+
+// CreateTransfer records money moved between two cash accounts, e.g. a cash deposit to the bank (admin only)
+func CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if _, err := time.Parse(dateLayout, req.Tanggal); err != nil {
+		respondError(w, http.StatusBadRequest, "Tanggal is required")
+		return
+	}
+	if req.Nominal <= 0 {
+		respondError(w, http.StatusBadRequest, "Nominal must be greater than 0")
+		return
+	}
+	if req.FromAccountID == req.ToAccountID {
+		respondError(w, http.StatusBadRequest, "Transfer accounts must be two different cash accounts")
+		return
+	}
+	for _, id := range []int64{req.FromAccountID, req.ToAccountID} {
+		account, err := database.GetAccountByID(callerSchool(r), id)
+		if err != nil || !account.IsCash {
+			respondError(w, http.StatusBadRequest, "Transfer accounts must be two different cash accounts")
+			return
+		}
+	}
+
+	entry, err := database.CreateTransfer(callerSchool(r), req)
+	if err != nil {
+		respondLedgerError(w, err)
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityTransfer, entry.ID, nil, entry)
+	respondJSON(w, http.StatusCreated, entry)
+}
+
+// GetGeneralLedger returns journal entries between from and to, optionally only those touching account_id (admin only)
+func GetGeneralLedger(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	from, to, err := parseDateRange(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid date range, expected YYYY-MM-DD")
+		return
+	}
+	accountID, err := parseOptionalID(r.URL.Query().Get("account_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid account_id")
+		return
+	}
+
+	entries, err := database.GetJournalEntries(callerSchool(r), from, to, accountID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch ledger")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, entries)
+}
+
+// GetTrialBalance returns debit and credit totals per account as of a date (admin only)
+func GetTrialBalance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	asOf := time.Now().Format(dateLayout)
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		if _, err := time.Parse(dateLayout, v); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid as_of, expected YYYY-MM-DD")
+			return
+		}
+		asOf = v
+	}
+
+	tb, err := database.GetTrialBalance(callerSchool(r), asOf)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch ledger")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tb)
+}
+
+// GetCashBook returns the buku kas umum of a cash or bank account with running balances (admin only)
+func GetCashBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	accountIDStr := r.URL.Query().Get("account_id")
+	if accountIDStr == "" {
+		respondError(w, http.StatusBadRequest, "account_id is required")
+		return
+	}
+	accountID, err := strconv.ParseInt(accountIDStr, 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid account_id")
+		return
+	}
+
+	from, to, err := parseDateRange(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid date range, expected YYYY-MM-DD")
+		return
+	}
+
+	account, err := database.GetAccountByID(callerSchool(r), accountID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Account not found")
+		return
+	}
+
+	book, err := database.GetCashBook(callerSchool(r), account, from, to)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch ledger")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, book)
+}
+
+// parseDateRange reads from/to (YYYY-MM-DD) query parameters, defaulting to the current month up to today
+func parseDateRange(q url.Values) (string, string, error) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Format(dateLayout)
+	to := now.Format(dateLayout)
+
+	if v := q.Get("from"); v != "" {
+		if _, err := time.Parse(dateLayout, v); err != nil {
+			return "", "", err
+		}
+		from = v
+	}
+	if v := q.Get("to"); v != "" {
+		if _, err := time.Parse(dateLayout, v); err != nil {
+			return "", "", err
+		}
+		to = v
+	}
+	if from > to {
+		return "", "", errors.New("from is after to")
+	}
+	return from, to, nil
+}
+
+func respondLedgerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrUnbalancedJournal):
+		respondError(w, http.StatusBadRequest, "Journal entry is not balanced")
+	case errors.Is(err, database.ErrInvalidJournalLines):
+		respondError(w, http.StatusBadRequest, "Each journal line needs an account and either a debit or a credit")
+	case errors.Is(err, database.ErrAccountNotFound):
+		respondError(w, http.StatusBadRequest, "Account not found")
+	default:
+		respondError(w, http.StatusInternalServerError, "Failed to post journal entry: "+err.Error())
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"komite-sekolah/database"
+	"komite-sekolah/middleware"
+	"komite-sekolah/models"
+)
+
+type loginSubject struct {
+	scope    string
+	schoolID int64
+	subject  string
+}
+
+// loginAttempt throttles one login request by the account it names and the client IP it
+// comes from. Both are checked before the password is, so guessing costs the same whether
+// or not the account exists.
+type loginAttempt struct {
+	account loginSubject
+	ip      loginSubject
+}
+
+func newLoginAttempt(r *http.Request, scope string, schoolID int64, subject string) *loginAttempt {
+	return &loginAttempt{
+		account: loginSubject{scope, schoolID, strings.ToLower(strings.TrimSpace(subject))},
+		ip:      loginSubject{models.LoginScopeIP, 0, middleware.ClientIP(r)},
+	}
+}
+
+// lockoutSchool returns the school a staff account's failed logins are counted under, or 0
+// for a foundation admin or a username that does not exist. Lockouts of school 0, like those
+// of IPs, are only shown to foundation admins.
+func lockoutSchool(user *models.User) int64 {
+	if user == nil || user.SchoolID == nil {
+		return 0
+	}
+	return *user.SchoolID
+}
+
+// allowed answers 429 with Retry-After when the account or the IP is still waiting
+func (a *loginAttempt) allowed(w http.ResponseWriter) bool {
+	for _, s := range []loginSubject{a.account, a.ip} {
+		until, err := database.LoginBlockedUntil(s.scope, s.schoolID, s.subject)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check login attempts")
+			return false
+		}
+		if !until.IsZero() {
+			respondTooManyAttempts(w, until)
+			return false
+		}
+	}
+	return true
+}
+
+// failed counts the failure against the account and the IP and answers 401
+func (a *loginAttempt) failed(w http.ResponseWriter) {
+	a.record()
+	respondError(w, http.StatusUnauthorized, "Invalid credentials")
+}
+
+// record counts a failure against the account and the IP without answering the request
+func (a *loginAttempt) record() {
+	for _, s := range []loginSubject{a.account, a.ip} {
+		if _, err := database.RecordLoginFailure(s.scope, s.schoolID, s.subject); err != nil {
+			log.Printf("Failed to record login failure for %s %q: %v", s.scope, s.subject, err)
+		}
+	}
+}
+
+// succeeded forgets the account's failures. The IP's failures are kept so an attacker cannot
+// reset them by logging in to an account of their own.
+func (a *loginAttempt) succeeded() {
+	if err := database.ClearLoginFailures(a.account.scope, a.account.schoolID, a.account.subject); err != nil {
+		log.Printf("Failed to clear login failures for %s %q: %v", a.account.scope, a.account.subject, err)
+	}
+}
+
+func respondTooManyAttempts(w http.ResponseWriter, until time.Time) {
+	seconds := int(math.Ceil(time.Until(until).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	respondError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+}
+
+// GetLockouts lists the accounts that currently have to wait before logging in again; a
+// foundation admin also sees the IPs and the usernames of no school
+func GetLockouts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	lockouts, err := database.GetLoginLockouts(callerSchool(r), callerRole(r).FoundationWide())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch lockouts")
+		return
+	}
+
+	if lockouts == nil {
+		lockouts = []models.LoginLockout{}
+	}
+
+	respondJSON(w, http.StatusOK, lockouts)
+}
+
+// ClearLockout lifts a lockout so the account or IP can log in again straight away
+func ClearLockout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ClearLockoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.ID == 0 {
+		respondError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	if err := database.ClearLoginLockout(callerSchool(r), callerRole(r).FoundationWide(), req.ID); err != nil {
+		if err == database.ErrLockoutNotFound {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to clear lockout")
+		return
+	}
+
+	audit(r, models.AuditClearLockout, models.EntityLoginLockout, req.ID, nil, nil)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Lockout cleared"})
+}
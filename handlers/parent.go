@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var phonePattern = regexp.MustCompile(`^62\d{7,13}$`)
+
+// normalizePhone strips separators and rewrites a local 08xx number to 628xx, so parents
+// can log in with either form
+func normalizePhone(phone string) string {
+	phone = strings.NewReplacer(" ", "", "-", "", "+", "", "(", "", ")", "").Replace(strings.TrimSpace(phone))
+	if strings.HasPrefix(phone, "0") {
+		phone = "62" + phone[1:]
+	}
+	return phone
+}
+
+// LoginParent handles parent login with phone number or email & password
+func LoginParent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	phone := normalizePhone(req.Phone)
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if (phone == "" && email == "") || req.Password == "" {
+		respondError(w, http.StatusBadRequest, "Phone or email and password are required")
+		return
+	}
+
+	school, ok := loginSchool(w, req.SchoolCode)
+	if !ok {
+		return
+	}
+
+	login := phone
+	if login == "" {
+		login = email
+	}
+	attempt := newLoginAttempt(r, models.LoginScopeParent, school.ID, login)
+	if !attempt.allowed(w) {
+		return
+	}
+
+	user, err := database.GetParentByLogin(school.ID, phone, email)
+	if err != nil {
+		attempt.failed(w)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		attempt.failed(w)
+		return
+	}
+
+	if user.Status != models.StatusActive {
+		respondError(w, http.StatusForbidden, "Account is not active")
+		return
+	}
+
+	attempt.succeeded()
+	startSession(w, user)
+}
+
+// CreateParent creates a parent account (admin only)
+func CreateParent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateParentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Phone = normalizePhone(req.Phone)
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if req.Name == "" || req.Password == "" || (req.Phone == "" && req.Email == "") {
+		respondError(w, http.StatusBadRequest, "Name, password, and phone or email are required")
+		return
+	}
+	if req.Phone != "" && !phonePattern.MatchString(req.Phone) {
+		respondError(w, http.StatusBadRequest, "Invalid phone number")
+		return
+	}
+	if req.Email != "" {
+		if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+			respondError(w, http.StatusBadRequest, "Invalid email")
+			return
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	user, err := database.CreateParent(callerSchool(r), req, string(hashedPassword))
+	if err != nil {
+		if err == database.ErrPhoneTaken || err == database.ErrEmailTaken {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create parent: "+err.Error())
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityParent, user.ID, nil, user)
+	respondJSON(w, http.StatusCreated, user)
+}
+
+// GetParents returns all parent accounts with their linked children (admin only)
+func GetParents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Parent accounts span classes, so class-scoped roles cannot list them
+	if callerRole(r).ClassScoped() {
+		respondError(w, http.StatusForbidden, "Permission denied")
+		return
+	}
+
+	parents, err := database.GetAllParents(callerSchool(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch parents")
+		return
+	}
+
+	if parents == nil {
+		parents = []models.ParentWithChildren{}
+	}
+
+	respondJSON(w, http.StatusOK, parents)
+}
+
+// LinkParentStudent links a parent to a student (POST) or removes the link (DELETE) (admin only)
+func LinkParentStudent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.LinkParentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.ParentID == 0 || req.StudentID == 0 {
+		respondError(w, http.StatusBadRequest, "parent_id and student_id are required")
+		return
+	}
+
+	parent, err := database.GetSchoolUser(callerSchool(r), req.ParentID)
+	if err != nil || parent.Role != models.RoleParent {
+		respondError(w, http.StatusNotFound, "Parent not found")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := database.UnlinkParentStudent(req.ParentID, req.StudentID); err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to unlink parent")
+			return
+		}
+		audit(r, models.AuditUnlink, models.EntityParent, req.ParentID, req, nil)
+		respondJSON(w, http.StatusOK, map[string]string{"message": "Parent unlinked"})
+		return
+	}
+
+	student, err := database.GetSchoolUser(callerSchool(r), req.StudentID)
+	if err != nil || student.Role != models.RoleStudent {
+		respondError(w, http.StatusNotFound, "Student not found")
+		return
+	}
+
+	req.Relationship = strings.TrimSpace(req.Relationship)
+	if err := database.LinkParentStudent(req); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to link parent")
+		return
+	}
+	audit(r, models.AuditLink, models.EntityParent, req.ParentID, nil, req)
+
+	children, err := database.GetChildrenByParentID(req.ParentID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch children")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.ParentWithChildren{Parent: *parent, Children: children})
+}
+
+// GetMyChildren returns the balance and payment history of every child linked to the
+// logged-in parent
+func GetMyChildren(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	parentID, ok := parentFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	parent, err := database.GetUserByID(parentID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	children, err := database.GetChildrenByParentID(parentID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch children")
+		return
+	}
+
+	response := models.ParentOverviewResponse{
+		Parent:   *parent,
+		Children: []models.PaymentHistoryResponse{},
+	}
+	for i := range children {
+		history, err := studentPaymentHistory(&children[i].Student)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to fetch payments")
+			return
+		}
+		response.Children = append(response.Children, *history)
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// GetChildPaymentHistory returns one linked child's balance and payment history
+func GetChildPaymentHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	parentID, ok := parentFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	studentID, err := strconv.ParseInt(r.URL.Query().Get("student_id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid student_id")
+		return
+	}
+
+	// Unlinked and non-existent students get the same answer so IDs cannot be probed
+	linked, err := database.IsParentOf(parentID, studentID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch children")
+		return
+	}
+	if !linked {
+		respondError(w, http.StatusNotFound, "Student not found")
+		return
+	}
+
+	student, err := database.GetSchoolUser(callerSchool(r), studentID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Student not found")
+		return
+	}
+
+	history, err := studentPaymentHistory(student)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch payments")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, history)
+}
+
+// parentFromContext returns the logged-in parent's ID, answering 403 for any other role
+func parentFromContext(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	role, ok := r.Context().Value("user_role").(models.UserRole)
+	if !ok || role != models.RoleParent {
+		respondError(w, http.StatusForbidden, "Parent access required")
+		return 0, false
+	}
+
+	userID, ok := r.Context().Value("user_id").(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return 0, false
+	}
+	return userID, true
+}
+
+// studentPaymentHistory builds a student's payment history with a summary of billed,
+// paid and outstanding amounts
+func studentPaymentHistory(student *models.User) (*models.PaymentHistoryResponse, error) {
+	payments, err := database.GetPaymentsByUserID(student.ID)
+	if err != nil {
+		return nil, err
+	}
+	if payments == nil {
+		payments = []models.Payment{}
+	}
+
+	billed, err := database.GetTotalBilledByUserID(student.ID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := database.GetPaymentSummaryByUserID(student.ID, billed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PaymentHistoryResponse{
+		VirtualAccount: student.VirtualAccount,
+		Summary:        *summary,
+		Payments:       payments,
+		User:           student,
+	}, nil
+}
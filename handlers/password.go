@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// passwordAlphabet leaves out characters that are easily confused when read off paper (0/O, 1/l/I)
+const passwordAlphabet = "abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const generatedPasswordLength = 10
+
+// generatePassword returns a random password drawn from passwordAlphabet
+func generatePassword() (string, error) {
+	return randomString(passwordAlphabet, generatedPasswordLength)
+}
+
+// randomString returns n characters drawn uniformly from alphabet
+func randomString(alphabet string, n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(alphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[n.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"komite-sekolah/config"
+	"komite-sekolah/database"
+	"komite-sekolah/messaging"
+	"komite-sekolah/middleware"
+	"komite-sekolah/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const resetCodeDigits = 6
+
+// ForgotPassword sends a one-time recovery code to the contacts of a student (through the
+// linked parents) or a parent. The answer is the same whether or not the account exists, so
+// the endpoint cannot be used to find out which NIS numbers are registered; that includes an
+// account that asked for too many codes. Only the client IP's own limit is answered with 429.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Channel != "" && req.Channel != messaging.ChannelEmail && req.Channel != messaging.ChannelWhatsApp {
+		respondError(w, http.StatusBadRequest, "Invalid channel")
+		return
+	}
+
+	if err := database.RecordResetRequest(middleware.ClientIP(r)); err != nil {
+		if err == database.ErrTooManyResetRequests {
+			respondError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to send recovery code")
+		return
+	}
+
+	user, _, ok := recoveryAccount(w, r, req.NIS, req.Phone, req.Email, req.SchoolCode)
+	if !ok {
+		return
+	}
+
+	ttl := config.AppConfig.OTPTTL
+	if user != nil && user.Status == models.StatusActive {
+		recipients, err := recoveryRecipients(user, req.Channel)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to send recovery code")
+			return
+		}
+
+		if len(recipients) > 0 {
+			code, err := generateResetCode()
+			if err != nil {
+				respondError(w, http.StatusInternalServerError, "Failed to send recovery code")
+				return
+			}
+
+			switch err := database.CreateResetCode(user.ID, code, time.Now().Add(ttl)); err {
+			case nil:
+				for _, msg := range recipients {
+					msg.Subject = "Kode pemulihan kata sandi"
+					msg.Body = fmt.Sprintf("Kode pemulihan kata sandi %s: %s. Berlaku %d menit. Jangan berikan kode ini kepada siapa pun.",
+						user.Name, code, int(ttl.Minutes()))
+					if err := messaging.Send(msg); err != nil {
+						log.Printf("Failed to send recovery code for user %d via %s: %v", user.ID, msg.Channel, err)
+					}
+				}
+			case database.ErrTooManyResetRequests:
+				// Answered like any other request, so the limit does not reveal the account
+				log.Printf("Password recovery for user %d: too many codes requested, none sent", user.ID)
+			default:
+				respondError(w, http.StatusInternalServerError, "Failed to send recovery code")
+				return
+			}
+		} else {
+			log.Printf("Password recovery for user %d: no %q contact to send the code to", user.ID, req.Channel)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, models.ForgotPasswordResponse{
+		Message:   "Jika akun terdaftar, kode pemulihan telah dikirim ke kontak yang terdaftar",
+		ExpiresIn: int64(ttl.Seconds()),
+	})
+}
+
+// ResetPasswordWithCode sets a new password for the account a recovery code was sent for.
+// Wrong codes count as failed logins of the account and the client IP.
+func ResetPasswordWithCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+	if req.Code == "" || req.NewPassword == "" {
+		respondError(w, http.StatusBadRequest, "Code and new password are required")
+		return
+	}
+
+	if len(req.NewPassword) < 6 {
+		respondError(w, http.StatusBadRequest, "New password must be at least 6 characters")
+		return
+	}
+
+	user, attempt, ok := recoveryAccount(w, r, req.NIS, req.Phone, req.Email, req.SchoolCode)
+	if !ok {
+		return
+	}
+	if user == nil || user.Status != models.StatusActive {
+		attempt.record()
+		respondError(w, http.StatusBadRequest, database.ErrInvalidResetCode.Error())
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	if err := database.ResetPasswordWithCode(user.ID, req.Code, string(hashedPassword)); err != nil {
+		if err == database.ErrInvalidResetCode {
+			attempt.record()
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to update password")
+		return
+	}
+
+	auditOwnAccount(r, user, models.AuditChangePassword)
+	attempt.succeeded()
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Kata sandi berhasil diubah, silakan masuk kembali"})
+}
+
+// recoveryAccount finds the student (by NIS) or parent (by phone or email) a recovery request
+// is for. A nil user with ok true means there is no such account. Requests for accounts or
+// IPs that are locked out of logging in are refused with 429.
+func recoveryAccount(w http.ResponseWriter, r *http.Request, nis, phone, email, schoolCode string) (*models.User, *loginAttempt, bool) {
+	nis = strings.TrimSpace(nis)
+	phone = normalizePhone(phone)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if nis == "" && phone == "" && email == "" {
+		respondError(w, http.StatusBadRequest, "NIS, phone or email is required")
+		return nil, nil, false
+	}
+
+	school, ok := loginSchool(w, schoolCode)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var attempt *loginAttempt
+	if nis != "" {
+		attempt = newLoginAttempt(r, models.LoginScopeStudent, school.ID, nis)
+	} else if phone != "" {
+		attempt = newLoginAttempt(r, models.LoginScopeParent, school.ID, phone)
+	} else {
+		attempt = newLoginAttempt(r, models.LoginScopeParent, school.ID, email)
+	}
+	if !attempt.allowed(w) {
+		return nil, nil, false
+	}
+
+	var user *models.User
+	var err error
+	if nis != "" {
+		user, err = database.GetUserByNIS(school.ID, nis)
+		if err == nil && user.Role != models.RoleStudent {
+			user = nil
+		}
+	} else {
+		user, err = database.GetParentByLogin(school.ID, phone, email)
+	}
+	if err == database.ErrUserNotFound {
+		return nil, attempt, true
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch user")
+		return nil, nil, false
+	}
+	return user, attempt, true
+}
+
+// recoveryRecipients returns where a user's recovery code goes: the parent's own phone
+// (WhatsApp) or email, or those of every parent linked to a student. channel picks one of the
+// two when a contact has both; otherwise WhatsApp is preferred.
+func recoveryRecipients(user *models.User, channel string) ([]messaging.Message, error) {
+	contacts := []models.User{*user}
+	if user.Role == models.RoleStudent {
+		parents, err := database.GetParentsByStudentID(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		contacts = parents
+	}
+
+	var recipients []messaging.Message
+	for _, c := range contacts {
+		switch {
+		case c.Phone != "" && channel != messaging.ChannelEmail:
+			recipients = append(recipients, messaging.Message{Channel: messaging.ChannelWhatsApp, To: c.Phone})
+		case c.Email != "" && channel != messaging.ChannelWhatsApp:
+			recipients = append(recipients, messaging.Message{Channel: messaging.ChannelEmail, To: c.Email})
+		}
+	}
+	return recipients, nil
+}
+
+// generateResetCode returns a random numeric code of resetCodeDigits digits
+func generateResetCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < resetCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", resetCodeDigits, n.Int64()), nil
+}
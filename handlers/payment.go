@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"komite-sekolah/database"
 	"komite-sekolah/models"
@@ -54,46 +57,40 @@ func GetMyPaymentHistory(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, response)
 }
 
-// GetAllPayments returns all payments (admin only)
+// GetAllPayments returns a page of payments matching the query filters (admin only).
+// See parsePaymentFilter for the accepted parameters.
 func GetAllPayments(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	role, ok := r.Context().Value("user_role").(models.UserRole)
-	if !ok || role != models.RoleAdmin {
-		respondError(w, http.StatusForbidden, "Admin access required")
+	filter, err := parsePaymentFilter(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	payments, err := database.GetAllPayments()
+	scope, err := classScope(r)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to fetch payments")
 		return
 	}
-
-	// Handle nil payments
-	if payments == nil {
-		payments = []models.Payment{}
+	if scope != 0 {
+		filter.ClassID = scope
 	}
 
-	respondJSON(w, http.StatusOK, payments)
+	listPayments(w, callerSchool(r), filter)
 }
 
-// GetPaymentsByNIS returns payments for a specific student identified by NIS (admin only)
+// GetPaymentsByNIS returns payments for a specific student identified by NIS (admin only).
+// Accepts the same filters as GetAllPayments.
 func GetPaymentsByNIS(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	role, ok := r.Context().Value("user_role").(models.UserRole)
-	if !ok || role != models.RoleAdmin {
-		respondError(w, http.StatusForbidden, "Admin access required")
-		return
-	}
-
 	nis := strings.TrimSpace(r.URL.Query().Get("nis"))
 	log.Printf("AdminGetPaymentsByNIS called, nis=%q", nis)
 	if nis == "" {
@@ -101,7 +98,7 @@ func GetPaymentsByNIS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := database.GetUserByNIS(nis)
+	user, err := database.GetUserByNIS(callerSchool(r), nis)
 	if err != nil {
 		if err == database.ErrUserNotFound {
 			log.Printf("GetPaymentsByNIS: user not found for nis=%q", nis)
@@ -113,10 +110,20 @@ func GetPaymentsByNIS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := user.ID
-	payments, err := database.GetPaymentsByUserIDWithUser(userID)
+	if !canSeeStudent(w, r, user) {
+		return
+	}
+
+	filter, err := parsePaymentFilter(r.URL.Query())
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch payments")
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.UserID = user.ID
+
+	payments, meta, err := database.ListPayments(callerSchool(r), filter)
+	if err != nil {
+		respondPaymentListError(w, err)
 		return
 	}
 
@@ -129,24 +136,20 @@ func GetPaymentsByNIS(w http.ResponseWriter, r *http.Request) {
 		VirtualAccount: user.VirtualAccount,
 		Payments:       payments,
 		User:           user,
+		Meta:           meta,
 	}
 
 	respondJSON(w, http.StatusOK, response)
 }
 
-// GetPaymentsByUser returns payments for a specific user (admin only)
+// GetPaymentsByUser returns payments for a specific user (admin only).
+// Accepts the same filters as GetAllPayments.
 func GetPaymentsByUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	role, ok := r.Context().Value("user_role").(models.UserRole)
-	if !ok || role != models.RoleAdmin {
-		respondError(w, http.StatusForbidden, "Admin access required")
-		return
-	}
-
 	// Get user_id from query parameter
 	userIDStr := r.URL.Query().Get("user_id")
 	if userIDStr == "" {
@@ -160,18 +163,18 @@ func GetPaymentsByUser(w http.ResponseWriter, r *http.Request) {
 		return
 	} 
 
-	payments, err := database.GetPaymentsByUserIDWithUser(userID)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch payments")
+	if _, ok := visibleStudent(w, r, userID); !ok {
 		return
 	}
 
-	// Handle nil payments
-	if payments == nil {
-		payments = []models.Payment{}
+	filter, err := parsePaymentFilter(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	filter.UserID = userID
 
-	respondJSON(w, http.StatusOK, payments)
+	listPayments(w, callerSchool(r), filter)
 }
 
 // CreatePayment creates a new payment record (admin only)
@@ -181,12 +184,6 @@ func CreatePayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	role, ok := r.Context().Value("user_role").(models.UserRole)
-	if !ok || role != models.RoleAdmin {
-		respondError(w, http.StatusForbidden, "Admin access required")
-		return
-	}
-
 	var req models.CreatePaymentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid request body")
@@ -206,21 +203,33 @@ func CreatePayment(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "Nominal must be greater than 0")
 		return
 	}
+	if req.Metode != "" && !validMetode(req.Metode) {
+		respondError(w, http.StatusBadRequest, "Invalid metode")
+		return
+	}
 
-
-	// Verify user exists
-	_, err := database.GetUserByID(req.UserID)
+	// Verify user exists in the caller's school
+	_, err := database.GetSchoolUser(callerSchool(r), req.UserID)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "User not found")
 		return
 	}
 
-	payment, err := database.CreatePayment(req)
+	if req.BillID != nil {
+		bill, err := database.GetBillByID(callerSchool(r), *req.BillID)
+		if err != nil || bill.UserID != req.UserID {
+			respondError(w, http.StatusBadRequest, "Bill does not belong to this student")
+			return
+		}
+	}
+
+	payment, err := database.CreatePayment(callerSchool(r), req)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to create payment: "+err.Error())
 		return
 	} 
 
+	audit(r, models.AuditCreate, models.EntityPayment, payment.ID, nil, payment)
 	respondJSON(w, http.StatusCreated, payment)
 }
 
@@ -231,12 +240,6 @@ func DeletePayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	role, ok := r.Context().Value("user_role").(models.UserRole)
-	if !ok || role != models.RoleAdmin {
-		respondError(w, http.StatusForbidden, "Admin access required")
-		return
-	}
-
 	// Get payment_id from query parameter
 	paymentIDStr := r.URL.Query().Get("payment_id")
 	if paymentIDStr == "" {
@@ -251,17 +254,18 @@ func DeletePayment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify payment exists
-	_, err = database.GetPaymentByID(paymentID)
+	existing, err := database.GetPaymentByID(callerSchool(r), paymentID)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "Payment not found")
 		return
 	}
 
-	if err := database.DeletePayment(paymentID); err != nil {
+	if err := database.DeletePayment(callerSchool(r), paymentID); err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to delete payment")
 		return
 	}
 
+	audit(r, models.AuditDelete, models.EntityPayment, paymentID, existing, nil)
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Payment deleted successfully"})
 }
 
@@ -272,18 +276,12 @@ func UpdatePayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	role, ok := r.Context().Value("user_role").(models.UserRole)
-	if !ok || role != models.RoleAdmin {
-		respondError(w, http.StatusForbidden, "Admin access required")
-		return
-	}
-
 	var req models.UpdatePaymentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
-	log.Printf("UpdatePayment called, req=%+v, user_role=%v", req, role)
+	log.Printf("UpdatePayment called, req=%+v, user_role=%v", req, r.Context().Value("user_role"))
 
 	if req.ID == 0 {
 		respondError(w, http.StatusBadRequest, "payment_id is required")
@@ -300,8 +298,13 @@ func UpdatePayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Metode != nil && !validMetode(*req.Metode) {
+		respondError(w, http.StatusBadRequest, "Invalid metode")
+		return
+	}
+
 	// Verify payment exists
-	_, err := database.GetPaymentByID(req.ID)
+	existing, err := database.GetPaymentByID(callerSchool(r), req.ID)
 	if err != nil {
 		if err == database.ErrPaymentNotFound {
 			respondError(w, http.StatusNotFound, "Payment not found")
@@ -311,17 +314,127 @@ func UpdatePayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Basic validation: require at least one field to update (tanggal, nominal, or keterangan)
-	if req.Tanggal == nil && req.Nominal == nil && req.Keterangan == nil {
+	if req.BillID != nil {
+		bill, err := database.GetBillByID(callerSchool(r), *req.BillID)
+		if err != nil || bill.UserID != existing.UserID {
+			respondError(w, http.StatusBadRequest, "Bill does not belong to this student")
+			return
+		}
+	}
+
+	// Basic validation: require at least one field to update
+	if req.Tanggal == nil && req.Nominal == nil && req.Keterangan == nil && req.BillID == nil && req.Metode == nil {
 		respondError(w, http.StatusBadRequest, "No fields to update")
 		return
 	}
 
-	updated, err := database.UpdatePayment(req.ID, req)
+	updated, err := database.UpdatePayment(callerSchool(r), req.ID, req)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to update payment: "+err.Error())
 		return
 	}
 
+	audit(r, models.AuditUpdate, models.EntityPayment, updated.ID, existing, updated)
 	respondJSON(w, http.StatusOK, updated)
 }
+
+func validMetode(metode string) bool {
+	return metode == models.MetodeTunai || metode == models.MetodeTransfer
+}
+
+// parsePaymentFilter reads the payment list parameters: from/to (tanggal range),
+// min_nominal/max_nominal, user_id, class_id, fee_category_id, metode, q (keterangan),
+// sort (tanggal, nominal or created_at, "-" for descending; default -tanggal),
+// cursor (next_cursor of the previous page) and limit.
+func parsePaymentFilter(q url.Values) (models.PaymentFilter, error) {
+	filter := models.PaymentFilter{
+		Search: strings.TrimSpace(q.Get("q")),
+		Sort:   q.Get("sort"),
+		Cursor: q.Get("cursor"),
+		Limit:  defaultPageSize,
+	}
+
+	for _, date := range []struct {
+		param string
+		dest  *string
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if v := q.Get(date.param); v != "" {
+			if _, err := time.Parse(dateLayout, v); err != nil {
+				return filter, errors.New("Invalid date range")
+			}
+			*date.dest = v
+		}
+	}
+	if filter.From != "" && filter.To != "" && filter.From > filter.To {
+		return filter, errors.New("Invalid date range")
+	}
+
+	for _, amount := range []struct {
+		param string
+		dest  *int64
+	}{{"min_nominal", &filter.MinNominal}, {"max_nominal", &filter.MaxNominal}} {
+		if v := q.Get(amount.param); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return filter, errors.New("Invalid nominal range")
+			}
+			*amount.dest = n
+		}
+	}
+
+	for _, id := range []struct {
+		param string
+		dest  *int64
+	}{{"user_id", &filter.UserID}, {"class_id", &filter.ClassID}, {"fee_category_id", &filter.FeeCategoryID}} {
+		n, err := parseOptionalID(q.Get(id.param))
+		if err != nil {
+			return filter, errors.New("Invalid " + id.param)
+		}
+		*id.dest = n
+	}
+
+	if metode := q.Get("metode"); metode != "" {
+		if !validMetode(metode) {
+			return filter, errors.New("Invalid metode")
+		}
+		filter.Metode = metode
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return filter, errors.New("Invalid limit")
+		}
+		filter.Limit = limit
+	}
+	if filter.Limit > maxPageSize {
+		filter.Limit = maxPageSize
+	}
+	return filter, nil
+}
+
+// listPayments responds with one page of a school's payments and the totals for the filter
+func listPayments(w http.ResponseWriter, schoolID int64, filter models.PaymentFilter) {
+	payments, meta, err := database.ListPayments(schoolID, filter)
+	if err != nil {
+		respondPaymentListError(w, err)
+		return
+	}
+
+	// Handle nil payments
+	if payments == nil {
+		payments = []models.Payment{}
+	}
+
+	respondJSON(w, http.StatusOK, models.PaymentListResponse{Data: payments, Meta: *meta})
+}
+
+// respondPaymentListError answers a failed database.ListPayments; a bad sort or cursor is the
+// caller's mistake
+func respondPaymentListError(w http.ResponseWriter, err error) {
+	if err == database.ErrInvalidSort || err == database.ErrInvalidCursor {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondError(w, http.StatusInternalServerError, "Failed to fetch payments")
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+)
+
+// VerifyPaymentChain recomputes the school's payment hash chain and reports the first broken
+// link, if any (admin only)
+func VerifyPaymentChain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	result, err := database.VerifyPaymentChain(callerSchool(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify payment chain")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// GetPaymentChainAnchors returns the chain hash at the end of each day between from and to
+// (YYYY-MM-DD, default the current month) (admin only)
+func GetPaymentChainAnchors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	anchors, ok := paymentChainAnchors(w, r)
+	if !ok {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, anchors)
+}
+
+// ExportPaymentChainAnchors downloads the same daily anchors as GetPaymentChainAnchors as CSV,
+// for printing in the committee's meeting minutes (admin only)
+func ExportPaymentChainAnchors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	anchors, ok := paymentChainAnchors(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="payment-anchors.csv"`)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"tanggal", "jumlah_kejadian", "kejadian_terakhir", "hash"})
+	for _, a := range anchors {
+		out.Write([]string{a.Tanggal, strconv.Itoa(a.EventCount), strconv.FormatInt(a.LastEventID, 10), a.Hash})
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("Failed to write payment anchor export: %v", err)
+	}
+}
+
+func paymentChainAnchors(w http.ResponseWriter, r *http.Request) ([]models.PaymentChainAnchor, bool) {
+	from, to, err := parseDateRange(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid date range, expected YYYY-MM-DD")
+		return nil, false
+	}
+
+	anchors, err := database.GetPaymentChainAnchors(callerSchool(r), from, to)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch payment anchors")
+		return nil, false
+	}
+
+	if anchors == nil {
+		anchors = []models.PaymentChainAnchor{}
+	}
+	return anchors, true
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"komite-sekolah/config"
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+)
+
+const (
+	dateLayout        = "2006-01-02"
+	dailyTrendDays    = 30
+	topArrearsLimit   = 10
+	academicYearMonth = time.July // Indonesian academic years start in July
+)
+
+var reportCache = newResponseCache()
+
+// GetDashboard returns the treasurer dashboard: collections today, this month and this
+// academic year against billed targets, per-class and per-category rates, top arrears
+// and chart-ready trends (admin only). Pass refresh=1 to bypass the cache.
+func GetDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	now := time.Now()
+	schoolID := callerSchool(r)
+	cacheKey := fmt.Sprintf("dashboard:%d:%s", schoolID, now.Format(dateLayout))
+	if r.URL.Query().Get("refresh") != "1" {
+		if cached, ok := reportCache.get(cacheKey); ok {
+			setReportCacheHeaders(w)
+			respondJSON(w, http.StatusOK, cached)
+			return
+		}
+	}
+
+	dashboard, err := buildDashboard(schoolID, now)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to build dashboard")
+		return
+	}
+
+	reportCache.set(cacheKey, dashboard, config.AppConfig.ReportCacheTTL)
+	setReportCacheHeaders(w)
+	respondJSON(w, http.StatusOK, dashboard)
+}
+
+func buildDashboard(schoolID int64, now time.Time) (*models.DashboardResponse, error) {
+	today := startOfDay(now)
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+	yearStart, yearEnd := academicYearRange(today)
+	todayStr := today.Format(dateLayout)
+
+	hariIni, err := database.GetCollectionPeriod(schoolID, todayStr, todayStr)
+	if err != nil {
+		return nil, err
+	}
+	bulanIni, err := database.GetCollectionPeriod(schoolID, monthStart.Format(dateLayout), monthStart.AddDate(0, 1, -1).Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	tahunAjaran, err := database.GetCollectionPeriod(schoolID, yearStart.Format(dateLayout), yearEnd.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	perKelas, err := database.GetCollectionByClass(schoolID, yearStart.Format(dateLayout), yearEnd.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	perKategori, err := database.GetCollectionByFeeCategory(schoolID, yearStart.Format(dateLayout), yearEnd.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	arrears, err := database.GetTopArrears(schoolID, todayStr, topArrearsLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	dailyFrom := today.AddDate(0, 0, -(dailyTrendDays - 1))
+	daily, err := database.GetDailyTrend(schoolID, dailyFrom.Format(dateLayout), todayStr)
+	if err != nil {
+		return nil, err
+	}
+	monthly, err := database.GetMonthlyTrend(schoolID, yearStart.Format(dateLayout), yearEnd.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard := &models.DashboardResponse{
+		HariIni:      *hariIni,
+		BulanIni:     *bulanIni,
+		TahunAjaran:  *tahunAjaran,
+		PerKelas:     perKelas,
+		PerKategori:  perKategori,
+		TunggakanTop: arrears,
+		TrenHarian:   fillTrend(daily, dailyFrom, today, func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, dateLayout),
+		TrenBulanan:  fillTrend(monthly, yearStart, yearEnd, func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }, "2006-01"),
+		GeneratedAt:  now,
+	}
+
+	if dashboard.PerKelas == nil {
+		dashboard.PerKelas = []models.CollectionBreakdown{}
+	}
+	if dashboard.PerKategori == nil {
+		dashboard.PerKategori = []models.CollectionBreakdown{}
+	}
+	if dashboard.TunggakanTop == nil {
+		dashboard.TunggakanTop = []models.ArrearsEntry{}
+	}
+	return dashboard, nil
+}
+
+// fillTrend returns one point per step between from and to, using zero for periods without payments,
+// so charts get a continuous series.
+func fillTrend(points []models.TrendPoint, from, to time.Time, next func(time.Time) time.Time, layout string) []models.TrendPoint {
+	byPeriode := make(map[string]models.TrendPoint, len(points))
+	for _, p := range points {
+		byPeriode[p.Periode] = p
+	}
+
+	filled := []models.TrendPoint{}
+	for t := from; !t.After(to); t = next(t) {
+		key := t.Format(layout)
+		if p, ok := byPeriode[key]; ok {
+			filled = append(filled, p)
+		} else {
+			filled = append(filled, models.TrendPoint{Periode: key})
+		}
+	}
+	return filled
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// academicYearRange returns the first and last day of the academic year containing t
+func academicYearRange(t time.Time) (time.Time, time.Time) {
+	year := t.Year()
+	if t.Month() < academicYearMonth {
+		year--
+	}
+	start := time.Date(year, academicYearMonth, 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(1, 0, -1)
+}
+
+// academicYearLabel returns the academic year containing t, e.g. "2024/2025"
+func academicYearLabel(t time.Time) string {
+	start, _ := academicYearRange(t)
+	return fmt.Sprintf("%d/%d", start.Year(), start.Year()+1)
+}
+
+func setReportCacheHeaders(w http.ResponseWriter) {
+	ttl := int(config.AppConfig.ReportCacheTTL.Seconds())
+	if ttl > 0 {
+		w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(ttl))
+	} else {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+}
+
+// GetAgingReport returns open bill balances grouped into age buckets by days past due
+// (admin only). Query parameters:
+//   - as_of: report date (YYYY-MM-DD), defaults to today; only bills and payments up to
+//     that date are counted so past reports can be reproduced
+//   - group_by: "class" (default), "fee_category" or "student" for drill-down
+//   - class_id, fee_category_id: narrow the report, e.g. to drill into one class
+//   - buckets: comma-separated bucket upper bounds, defaults to AGING_BUCKETS
+func GetAgingReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	q := r.URL.Query()
+
+	asOf := time.Now().Format(dateLayout)
+	if v := q.Get("as_of"); v != "" {
+		if _, err := time.Parse(dateLayout, v); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid as_of, expected YYYY-MM-DD")
+			return
+		}
+		asOf = v
+	}
+
+	groupBy := q.Get("group_by")
+	if groupBy == "" {
+		groupBy = "class"
+	}
+	if groupBy != "class" && groupBy != "fee_category" && groupBy != "student" {
+		respondError(w, http.StatusBadRequest, "Invalid group_by")
+		return
+	}
+
+	bucketSpec := q.Get("buckets")
+	if bucketSpec == "" {
+		bucketSpec = config.AppConfig.AgingBuckets
+	}
+	buckets, err := parseAgingBuckets(bucketSpec)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid buckets")
+		return
+	}
+
+	classID, err := parseOptionalID(q.Get("class_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid class_id")
+		return
+	}
+	feeCategoryID, err := parseOptionalID(q.Get("fee_category_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid fee_category_id")
+		return
+	}
+
+	cacheKey := fmt.Sprintf("aging:%d:%s:%s", callerSchool(r), r.URL.RawQuery, time.Now().Format(dateLayout))
+	if cached, ok := reportCache.get(cacheKey); ok {
+		setReportCacheHeaders(w)
+		respondJSON(w, http.StatusOK, cached)
+		return
+	}
+
+	balances, err := database.GetOpenBillBalances(callerSchool(r), asOf, classID, feeCategoryID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to build aging report")
+		return
+	}
+
+	report := buildAgingReport(asOf, groupBy, buckets, balances)
+	reportCache.set(cacheKey, report, config.AppConfig.ReportCacheTTL)
+	setReportCacheHeaders(w)
+	respondJSON(w, http.StatusOK, report)
+}
+
+// parseAgingBuckets turns "30,60,90" into 0-30, 31-60, 61-90 and 90+
+func parseAgingBuckets(spec string) ([]models.AgingBucket, error) {
+	var buckets []models.AgingBucket
+	lower := 0
+	for _, part := range strings.Split(spec, ",") {
+		upper, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || upper < lower {
+			return nil, errors.New("bucket bounds must be increasing integers")
+		}
+		buckets = append(buckets, models.AgingBucket{
+			Label:   fmt.Sprintf("%d-%d", lower, upper),
+			MinDays: lower,
+			MaxDays: &upper,
+		})
+		lower = upper + 1
+	}
+	buckets = append(buckets, models.AgingBucket{
+		Label:   fmt.Sprintf("%d+", lower-1),
+		MinDays: lower,
+	})
+	return buckets, nil
+}
+
+func buildAgingReport(asOf, groupBy string, buckets []models.AgingBucket, balances []models.OpenBillBalance) *models.AgingReport {
+	report := &models.AgingReport{
+		AsOf:    asOf,
+		GroupBy: groupBy,
+		Buckets: buckets,
+		Rows:    []models.AgingRow{},
+		Total:   models.AgingRow{Name: "Total", Jumlah: make([]int64, len(buckets))},
+	}
+
+	index := make(map[int64]int)
+	for _, b := range balances {
+		var id int64
+		row := models.AgingRow{}
+		switch groupBy {
+		case "fee_category":
+			id, row.Name = b.FeeCategoryID, b.FeeCategoryName
+		case "student":
+			id, row.Name, row.NIS = b.UserID, b.StudentName, b.NIS
+		default:
+			id, row.Name = b.ClassID, b.ClassName
+			if id == 0 {
+				row.Name = "Tanpa Kelas"
+			}
+		}
+
+		i, ok := index[id]
+		if !ok {
+			row.ID = id
+			row.Jumlah = make([]int64, len(buckets))
+			report.Rows = append(report.Rows, row)
+			i = len(report.Rows) - 1
+			index[id] = i
+		}
+
+		addToAgingRow(&report.Rows[i], buckets, b)
+		addToAgingRow(&report.Total, buckets, b)
+	}
+
+	sort.Slice(report.Rows, func(i, j int) bool { return report.Rows[i].Name < report.Rows[j].Name })
+	return report
+}
+
+func addToAgingRow(row *models.AgingRow, buckets []models.AgingBucket, b models.OpenBillBalance) {
+	row.Total += b.Sisa
+	if b.DaysPastDue < 0 {
+		row.BelumJatuhTempo += b.Sisa
+		return
+	}
+	for i, bucket := range buckets {
+		if bucket.MaxDays == nil || b.DaysPastDue <= *bucket.MaxDays {
+			row.Jumlah[i] += b.Sisa
+			return
+		}
+	}
+}
+
+// parseOptionalID parses an optional numeric query parameter, returning 0 when empty
+func parseOptionalID(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+)
+
+// GetRoles lists the staff roles and the permissions each one grants
+func GetRoles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	roles := make([]models.RoleInfo, 0, len(models.RolePermissions))
+	for role, perms := range models.RolePermissions {
+		if role == models.RoleAdmin || role.FoundationWide() {
+			continue
+		}
+		roles = append(roles, models.RoleInfo{Role: role, Permissions: perms})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Role < roles[j].Role })
+
+	respondJSON(w, http.StatusOK, roles)
+}
+
+// AssignRole gives a staff account a new role. A homeroom teacher (wali_kelas) must be
+// given the class they are responsible for.
+func AssignRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.UserID == 0 {
+		respondError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if !req.Role.IsStaff() || req.Role == models.RoleAdmin || req.Role.FoundationWide() {
+		respondError(w, http.StatusBadRequest, "Invalid role")
+		return
+	}
+
+	if req.Role == models.RoleWaliKelas {
+		if req.ClassID == nil {
+			respondError(w, http.StatusBadRequest, "class_id is required for wali_kelas")
+			return
+		}
+		if _, err := database.GetClassByID(callerSchool(r), *req.ClassID); err != nil {
+			respondError(w, http.StatusNotFound, "Class not found")
+			return
+		}
+	}
+
+	before, ok := staffAccount(w, r, req.UserID)
+	if !ok {
+		return
+	}
+
+	if err := database.AssignRole(callerSchool(r), req.UserID, req.Role, req.ClassID); err != nil {
+		if err == database.ErrLastSuperAdmin {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to assign role")
+		return
+	}
+
+	updated, err := database.GetSchoolUser(callerSchool(r), req.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch user")
+		return
+	}
+
+	audit(r, models.AuditAssignRole, models.EntityStaff, req.UserID, before, updated)
+	respondJSON(w, http.StatusOK, updated)
+}
+
+// callerRole returns the role of the logged-in user
+func callerRole(r *http.Request) models.UserRole {
+	role, _ := r.Context().Value("user_role").(models.UserRole)
+	return role
+}
+
+// callerSchool returns the school the request works on: the caller's own school, or for a
+// foundation admin the one picked with ?school_id=
+func callerSchool(r *http.Request) int64 {
+	schoolID, _ := r.Context().Value("school_id").(int64)
+	return schoolID
+}
+
+// classScope returns the only class the caller may see students of, or 0 when the caller's
+// role is not limited to one class. A homeroom teacher without a class gets -1, which
+// matches no student.
+func classScope(r *http.Request) (int64, error) {
+	if !callerRole(r).ClassScoped() {
+		return 0, nil
+	}
+
+	userID, _ := r.Context().Value("user_id").(int64)
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		return 0, err
+	}
+	if user.ClassID == nil {
+		return -1, nil
+	}
+	return *user.ClassID, nil
+}
+
+// canSeeStudent reports whether the caller's class scope covers a student, answering
+// 404 when it does not so students outside the class cannot be probed
+func canSeeStudent(w http.ResponseWriter, r *http.Request, student *models.User) bool {
+	scope, err := classScope(r)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch user")
+		return false
+	}
+	if scope != 0 && (student.ClassID == nil || *student.ClassID != scope) {
+		respondError(w, http.StatusNotFound, "User not found")
+		return false
+	}
+	return true
+}
+
+// visibleStudent fetches a user of the caller's school for a staff lookup, answering 404
+// when it does not exist or is outside the caller's class scope
+func visibleStudent(w http.ResponseWriter, r *http.Request, userID int64) (*models.User, bool) {
+	user, err := database.GetSchoolUser(callerSchool(r), userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "User not found")
+		return nil, false
+	}
+	if !canSeeStudent(w, r, user) {
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+)
+
+var schoolCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{2,20}$`)
+
+// GetSchools returns every school of the foundation (foundation admin only)
+func GetSchools(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	schools, err := database.GetAllSchools()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch schools")
+		return
+	}
+
+	if schools == nil {
+		schools = []models.School{}
+	}
+
+	respondJSON(w, http.StatusOK, schools)
+}
+
+// CreateSchool registers a new school (foundation admin only). Its code is what students
+// and parents give at login, so it is stored upper-case.
+func CreateSchool(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateSchoolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Code == "" || req.Name == "" {
+		respondError(w, http.StatusBadRequest, "Code and name are required")
+		return
+	}
+	if !schoolCodePattern.MatchString(req.Code) {
+		respondError(w, http.StatusBadRequest, "Invalid school code")
+		return
+	}
+
+	school, err := database.CreateSchool(req)
+	if err != nil {
+		if err == database.ErrSchoolExists {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create school: "+err.Error())
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntitySchool, school.ID, nil, school)
+	respondJSON(w, http.StatusCreated, school)
+}
+
+// GetFoundationReport consolidates collections and arrears of every school for ?from=&to=,
+// defaulting to the current month (foundation admin only)
+func GetFoundationReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	from, to, err := parseDateRange(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid date range, expected YYYY-MM-DD")
+		return
+	}
+
+	schools, err := database.GetFoundationCollections(from, to)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to build foundation report")
+		return
+	}
+
+	report := models.FoundationReport{
+		DariTanggal:   from,
+		SampaiTanggal: to,
+		Schools:       schools,
+		Total:         models.SchoolCollection{Name: "Total"},
+		GeneratedAt:   time.Now(),
+	}
+	if report.Schools == nil {
+		report.Schools = []models.SchoolCollection{}
+	}
+	for _, s := range report.Schools {
+		report.Total.SiswaAktif += s.SiswaAktif
+		report.Total.Terkumpul += s.Terkumpul
+		report.Total.Target += s.Target
+		report.Total.Tunggakan += s.Tunggakan
+	}
+	report.Total.PersentaseTagih = collectionRate(report.Total.Terkumpul, report.Total.Target)
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// collectionRate returns collected as a percentage of target, rounded to two decimals
+func collectionRate(collected, target int64) float64 {
+	if target == 0 {
+		return 0
+	}
+	return math.Round(float64(collected)/float64(target)*10000) / 100
+}
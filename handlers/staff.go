@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{3,50}$`)
+
+// GetStaff returns all admin and staff accounts of the caller's school
+func GetStaff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	staff, err := database.GetAllStaff(callerSchool(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch staff")
+		return
+	}
+
+	if staff == nil {
+		staff = []models.User{}
+	}
+
+	respondJSON(w, http.StatusOK, staff)
+}
+
+// CreateStaff creates an admin or staff account. Like new students, the account must
+// change its password on first login.
+func CreateStaff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateStaffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Username == "" || req.Name == "" || req.Password == "" {
+		respondError(w, http.StatusBadRequest, "Username, name, and password are required")
+		return
+	}
+	if !usernamePattern.MatchString(req.Username) {
+		respondError(w, http.StatusBadRequest, "Invalid username")
+		return
+	}
+	if len(req.Password) < 6 {
+		respondError(w, http.StatusBadRequest, "New password must be at least 6 characters")
+		return
+	}
+	if !req.Role.IsStaff() || req.Role == models.RoleAdmin || req.Role.FoundationWide() {
+		respondError(w, http.StatusBadRequest, "Invalid role")
+		return
+	}
+	if req.Role == models.RoleWaliKelas {
+		if req.ClassID == nil {
+			respondError(w, http.StatusBadRequest, "class_id is required for wali_kelas")
+			return
+		}
+		if _, err := database.GetClassByID(callerSchool(r), *req.ClassID); err != nil {
+			respondError(w, http.StatusNotFound, "Class not found")
+			return
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	user, err := database.CreateStaff(callerSchool(r), req, string(hashedPassword))
+	if err != nil {
+		if err == database.ErrDuplicateUser {
+			respondError(w, http.StatusConflict, "Username is already taken")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create staff: "+err.Error())
+		return
+	}
+
+	audit(r, models.AuditCreate, models.EntityStaff, user.ID, nil, user)
+	respondJSON(w, http.StatusCreated, user)
+}
+
+// UpdateStaffStatus disables or re-enables a staff account. Disabled accounts cannot log in.
+func UpdateStaffStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.UpdateStaffStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.UserID == 0 {
+		respondError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if req.Status != models.StatusActive && req.Status != models.StatusInactive {
+		respondError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	before, ok := staffAccount(w, r, req.UserID)
+	if !ok {
+		return
+	}
+
+	if err := database.UpdateStaffStatus(callerSchool(r), req.UserID, req.Status); err != nil {
+		if err == database.ErrLastSuperAdmin {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to update status")
+		return
+	}
+
+	updated, err := database.GetSchoolUser(callerSchool(r), req.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch user")
+		return
+	}
+
+	audit(r, models.AuditStatus, models.EntityStaff, req.UserID, before, updated)
+	respondJSON(w, http.StatusOK, updated)
+}
+
+// ResetStaffPassword resets a staff account's password. After reset, the account must
+// change password on next login.
+func ResetStaffPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.UserID == 0 || req.NewPassword == "" {
+		respondError(w, http.StatusBadRequest, "User ID and new password are required")
+		return
+	}
+	if len(req.NewPassword) < 6 {
+		respondError(w, http.StatusBadRequest, "New password must be at least 6 characters")
+		return
+	}
+
+	if _, ok := staffAccount(w, r, req.UserID); !ok {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	if err := database.ResetPassword(callerSchool(r), req.UserID, string(hashedPassword)); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	audit(r, models.AuditResetPassword, models.EntityStaff, req.UserID, nil, nil)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Password reset successfully"})
+}
+
+// staffAccount fetches a staff account of the caller's school, answering 404 for students,
+// parents, other schools' staff and unknown IDs
+func staffAccount(w http.ResponseWriter, r *http.Request, userID int64) (*models.User, bool) {
+	user, err := database.GetSchoolUser(callerSchool(r), userID)
+	if err != nil || !user.Role.IsStaff() {
+		respondError(w, http.StatusNotFound, "Staff account not found")
+		return nil, false
+	}
+	return user, true
+}
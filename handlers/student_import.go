@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"komite-sekolah/config"
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+	"komite-sekolah/spreadsheet"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const maxImportRows = 5000
+
+// maxPasswordBytes is the longest password bcrypt accepts
+const maxPasswordBytes = 72
+
+var (
+	nisPattern = regexp.MustCompile(`^\d{4,20}$`)
+	vaPattern  = regexp.MustCompile(`^\d{6,30}$`)
+)
+
+// importColumns maps accepted header spellings to the field they fill
+var importColumns = map[string]string{
+	"nis":             "nis",
+	"nama":            "name",
+	"name":            "name",
+	"nama siswa":      "name",
+	"kelas":           "class",
+	"class":           "class",
+	"rombel":          "class",
+	"va":              "va",
+	"no va":           "va",
+	"nomor va":        "va",
+	"virtual account": "va",
+	"virtual_account": "va",
+	"password":        "password",
+	"kata sandi":      "password",
+	"kata_sandi":      "password",
+}
+
+// ImportStudents creates students in bulk from an uploaded CSV or XLSX file (admin only).
+// Expects multipart/form-data with a "file" field. Without ?confirm=true only a preview with
+// per-row errors is returned. With confirm=true all valid rows are created in one transaction;
+// rows without a password get a generated one, returned only in this response.
+func ImportStudents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	maxSize := config.AppConfig.MaxUploadSizeMB << 20
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+(1<<20))
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "File is required")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "File is required")
+		return
+	}
+	if int64(len(data)) > maxSize {
+		respondError(w, http.StatusRequestEntityTooLarge, "File is too large")
+		return
+	}
+
+	records, err := spreadsheet.Read(header.Filename, data)
+	if err != nil {
+		if errors.Is(err, spreadsheet.ErrUnsupportedFormat) {
+			respondError(w, http.StatusBadRequest, "Unsupported file format, expected .csv or .xlsx")
+			return
+		}
+		respondError(w, http.StatusBadRequest, "Failed to read file: "+err.Error())
+		return
+	}
+
+	rows, err := parseImportRows(records)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validateImportRows(callerSchool(r), rows); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to validate import")
+		return
+	}
+
+	result := models.StudentImportResult{TotalRows: len(rows), Rows: rows}
+	var valid []int
+	for i, row := range rows {
+		if len(row.Errors) == 0 {
+			valid = append(valid, i)
+		}
+	}
+	result.ValidRows = len(valid)
+	result.InvalidRows = len(rows) - len(valid)
+
+	if r.URL.Query().Get("confirm") != "true" {
+		respondJSON(w, http.StatusOK, result)
+		return
+	}
+	if len(valid) == 0 {
+		respondError(w, http.StatusBadRequest, "No valid rows to import")
+		return
+	}
+
+	toCreate := make([]models.StudentImportRow, len(valid))
+	for n, i := range valid {
+		if rows[i].Password == "" {
+			password, err := generatePassword()
+			if err != nil {
+				respondError(w, http.StatusInternalServerError, "Failed to hash password")
+				return
+			}
+			rows[i].Password = password
+			rows[i].GeneratedPassword = password
+		}
+		toCreate[n] = rows[i]
+	}
+
+	hashes, err := hashPasswords(toCreate)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	if err := database.CreateStudents(callerSchool(r), toCreate, hashes); err != nil {
+		respondError(w, http.StatusConflict, "Failed to import students: "+err.Error())
+		return
+	}
+
+	// Generated passwords are only ever shown in this response, never kept in the audit log
+	imported := make([]models.StudentImportRow, len(toCreate))
+	for i, row := range toCreate {
+		row.GeneratedPassword = ""
+		imported[i] = row
+	}
+	audit(r, models.AuditImport, models.EntityStudent, 0, nil, map[string]any{"file": header.Filename, "rows": imported})
+
+	result.Committed = true
+	result.Rows = rows
+	respondJSON(w, http.StatusCreated, result)
+}
+
+// parseImportRows maps spreadsheet records to import rows using the header row
+func parseImportRows(records [][]string) ([]models.StudentImportRow, error) {
+	// The header is the first row that is not blank; records[i] is spreadsheet row i+1
+	header := 0
+	for header < len(records) && blankRecord(records[header]) {
+		header++
+	}
+	if header+1 >= len(records) {
+		return nil, errors.New("File has no data rows")
+	}
+
+	columns := make(map[string]int)
+	for i, h := range records[header] {
+		if field, ok := importColumns[strings.ToLower(strings.TrimSpace(h))]; ok {
+			columns[field] = i
+		}
+	}
+	for _, required := range []string{"nis", "name", "va"} {
+		if _, ok := columns[required]; !ok {
+			return nil, errors.New("Missing required column: " + required)
+		}
+	}
+
+	cell := func(record []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []models.StudentImportRow
+	for n := header + 1; n < len(records); n++ {
+		record := records[n]
+		if blankRecord(record) {
+			continue
+		}
+		rows = append(rows, models.StudentImportRow{
+			Row:            n + 1,
+			NIS:            cell(record, "nis"),
+			Name:           cell(record, "name"),
+			ClassName:      cell(record, "class"),
+			VirtualAccount: cell(record, "va"),
+			Password:       cell(record, "password"),
+		})
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("File has no data rows")
+	}
+	if len(rows) > maxImportRows {
+		return nil, errors.New("Too many rows in file")
+	}
+	return rows, nil
+}
+
+func blankRecord(record []string) bool {
+	return strings.TrimSpace(strings.Join(record, "")) == ""
+}
+
+// validateImportRows records per-row errors for missing fields, invalid formats, unknown classes,
+// passwords equal to the NIS and NIS or virtual account numbers duplicated within the file or
+// already registered.
+// Class names are matched against the school's active academic year.
+func validateImportRows(schoolID int64, rows []models.StudentImportRow) error {
+	yearID, err := activeAcademicYearID(schoolID)
+	if err != nil {
+		return err
+	}
+	classes, err := database.GetAllClasses(schoolID, yearID)
+	if err != nil {
+		return err
+	}
+	classIDs := make(map[string]int64, len(classes))
+	for _, c := range classes {
+		classIDs[strings.ToLower(c.Name)] = c.ID
+	}
+
+	var nisList, vaList []string
+	for _, row := range rows {
+		if row.NIS != "" {
+			nisList = append(nisList, row.NIS)
+		}
+		if row.VirtualAccount != "" {
+			vaList = append(vaList, row.VirtualAccount)
+		}
+	}
+	nisTaken, vaTaken, err := database.ExistingStudentIdentifiers(schoolID, nisList, vaList)
+	if err != nil {
+		return err
+	}
+
+	nisSeen := make(map[string]int)
+	vaSeen := make(map[string]int)
+	for i := range rows {
+		row := &rows[i]
+
+		switch {
+		case row.NIS == "":
+			row.Errors = append(row.Errors, "NIS wajib diisi")
+		case !nisPattern.MatchString(row.NIS):
+			row.Errors = append(row.Errors, "NIS harus berupa 4-20 digit angka")
+		case nisTaken[row.NIS]:
+			row.Errors = append(row.Errors, "NIS sudah terdaftar")
+		case nisSeen[row.NIS] != 0:
+			row.Errors = append(row.Errors, "NIS duplikat dengan baris "+strconv.Itoa(nisSeen[row.NIS]))
+		default:
+			nisSeen[row.NIS] = row.Row
+		}
+
+		switch {
+		case row.VirtualAccount == "":
+			row.Errors = append(row.Errors, "Nomor VA wajib diisi")
+		case !vaPattern.MatchString(row.VirtualAccount):
+			row.Errors = append(row.Errors, "Nomor VA harus berupa 6-30 digit angka")
+		case vaTaken[row.VirtualAccount]:
+			row.Errors = append(row.Errors, "Nomor VA sudah terdaftar")
+		case vaSeen[row.VirtualAccount] != 0:
+			row.Errors = append(row.Errors, "Nomor VA duplikat dengan baris "+strconv.Itoa(vaSeen[row.VirtualAccount]))
+		default:
+			vaSeen[row.VirtualAccount] = row.Row
+		}
+
+		if row.Name == "" {
+			row.Errors = append(row.Errors, "Nama wajib diisi")
+		}
+
+		if row.ClassName != "" {
+			id, ok := classIDs[strings.ToLower(row.ClassName)]
+			if !ok {
+				row.Errors = append(row.Errors, "Kelas "+row.ClassName+" tidak ditemukan")
+			} else {
+				row.ClassID = &id
+			}
+		}
+
+		switch {
+		case row.Password == "":
+		case len(row.Password) < 6:
+			row.Errors = append(row.Errors, "Kata sandi minimal 6 karakter")
+		case len(row.Password) > maxPasswordBytes:
+			row.Errors = append(row.Errors, "Kata sandi maksimal "+strconv.Itoa(maxPasswordBytes)+" byte")
+		case row.Password == row.NIS:
+			row.Errors = append(row.Errors, "Kata sandi tidak boleh sama dengan NIS")
+		}
+	}
+	return nil
+}
+
+// hashPasswords bcrypt-hashes the passwords of rows in parallel; hashing hundreds of
+// passwords one after another would take minutes.
+func hashPasswords(rows []models.StudentImportRow) ([]string, error) {
+	hashes := make([]string, len(rows))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	for n := 0; n < runtime.NumCPU(); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hash, err := bcrypt.GenerateFromPassword([]byte(rows[i].Password), bcrypt.DefaultCost)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+				hashes[i] = string(hash)
+			}
+		}()
+	}
+	for i := range rows {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return hashes, firstErr
+}
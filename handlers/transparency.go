@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+)
+
+// GetTransparencyReport returns a published transparency report for ?periode=, or the list of
+// published periods when periode is omitted. Available to every authenticated user; only frozen
+// snapshots are served so parents always see the numbers that were published.
+func GetTransparencyReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := r.Context().Value("user_id").(int64); !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	periode := strings.TrimSpace(r.URL.Query().Get("periode"))
+	if periode == "" {
+		reports, err := database.GetPublishedTransparencyReports(callerSchool(r))
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to fetch transparency report")
+			return
+		}
+		if reports == nil {
+			reports = []models.PublishedReportSummary{}
+		}
+		respondJSON(w, http.StatusOK, reports)
+		return
+	}
+
+	report, err := database.GetPublishedTransparencyReport(callerSchool(r), periode)
+	if err != nil {
+		if err == database.ErrReportNotPublished {
+			respondError(w, http.StatusNotFound, "Report has not been published")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to fetch transparency report")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// PreviewTransparencyReport computes the transparency report from live data for ?from=&to= (admin only)
+func PreviewTransparencyReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	from, to, err := parseDateRange(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid date range, expected YYYY-MM-DD")
+		return
+	}
+
+	report, err := database.BuildTransparencyReport(callerSchool(r), r.URL.Query().Get("periode"), from, to)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch transparency report")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// PublishTransparencyReport freezes the transparency report of a period (admin only).
+// Later corrections to payments or expenses do not change a published report.
+func PublishTransparencyReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.PublishTransparencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Periode = strings.TrimSpace(req.Periode)
+	if req.Periode == "" {
+		respondError(w, http.StatusBadRequest, "periode is required")
+		return
+	}
+	from, errFrom := time.Parse(dateLayout, req.DariTanggal)
+	to, errTo := time.Parse(dateLayout, req.SampaiTanggal)
+	if errFrom != nil || errTo != nil || from.After(to) {
+		respondError(w, http.StatusBadRequest, "Invalid date range, expected YYYY-MM-DD")
+		return
+	}
+
+	report, err := database.BuildTransparencyReport(callerSchool(r), req.Periode, req.DariTanggal, req.SampaiTanggal)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch transparency report")
+		return
+	}
+
+	published, err := database.PublishTransparencyReport(callerSchool(r), report, userID)
+	if err != nil {
+		if err == database.ErrReportAlreadyPublished {
+			respondError(w, http.StatusConflict, "Report for this period is already published")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to publish transparency report")
+		return
+	}
+
+	audit(r, models.AuditPublish, models.EntityTransparencyReport, 0, nil, published)
+	respondJSON(w, http.StatusCreated, published)
+}
@@ -0,0 +1,544 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"komite-sekolah/config"
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+	"komite-sekolah/tokens"
+	"komite-sekolah/totp"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	totpSkew             = 1 // Steps of clock drift accepted either way
+)
+
+// beginTwoFactor answers a correct staff password with a 2FA challenge when the account has
+// 2FA enabled or its role requires it, and reports whether it did. Accounts that must use
+// 2FA but have not set it up get a new secret to enroll with as part of the challenge.
+func beginTwoFactor(w http.ResponseWriter, user *models.User) bool {
+	challenge := models.TwoFactorChallenge{TwoFactorRequired: true}
+
+	if !user.TwoFactorEnabled {
+		var schoolID int64
+		if user.SchoolID != nil {
+			schoolID = *user.SchoolID
+		}
+		required, err := database.TwoFactorRequired(schoolID, user.Role)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check two-factor authentication")
+			return true
+		}
+		if !required {
+			return false
+		}
+
+		enrollment, err := startEnrollment(user)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to set up two-factor authentication")
+			return true
+		}
+		challenge.EnrollmentRequired = true
+		challenge.Enrollment = enrollment
+	}
+
+	token, err := generateChallengeToken(user)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate token")
+		return true
+	}
+	challenge.ChallengeToken = token
+	challenge.ExpiresIn = int64(config.AppConfig.TwoFactorChallengeTTL.Seconds())
+
+	respondJSON(w, http.StatusOK, challenge)
+	return true
+}
+
+// VerifyTwoFactor completes a staff login: the challenge token from LoginAdmin and a TOTP or
+// recovery code are exchanged for a session. When the login enrolled the account, the code
+// turns 2FA on and the recovery codes are returned with the tokens.
+func VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.VerifyTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.ChallengeToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		respondError(w, http.StatusBadRequest, "challenge_token and code are required")
+		return
+	}
+
+	userID, err := parseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, "Invalid or expired challenge")
+		return
+	}
+
+	user, err := database.GetUserByID(userID)
+	if err != nil || !user.Role.IsStaff() || user.Status != models.StatusActive {
+		respondError(w, http.StatusUnauthorized, "Invalid or expired challenge")
+		return
+	}
+
+	attempt := newLoginAttempt(r, models.LoginScopeAdmin, lockoutSchool(user), user.Username)
+	if !attempt.allowed(w) {
+		return
+	}
+
+	var recoveryCodes []string
+	if user.TwoFactorEnabled {
+		ok, err := checkSecondFactor(user.ID, req.Code, req.RecoveryCode)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to verify two-factor code")
+			return
+		}
+		if !ok {
+			attempt.record()
+			respondError(w, http.StatusUnauthorized, "Invalid two-factor code")
+			return
+		}
+	} else {
+		// Enrollment started by beginTwoFactor; only a TOTP code can confirm it
+		codes, ok := confirmEnrollment(w, user.ID, req.Code)
+		if !ok {
+			attempt.record()
+			return
+		}
+		recoveryCodes = codes
+		user.TwoFactorEnabled = true
+		auditOwnAccount(r, user, models.AuditEnableTwoFactor)
+	}
+
+	attempt.succeeded()
+	resp, err := newSession(user)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	resp.RecoveryCodes = recoveryCodes
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// SetupTwoFactor starts 2FA enrollment for the logged-in staff account and returns the
+// secret and provisioning URI for the authenticator app
+func SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := twoFactorAccount(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := startEnrollment(user)
+	if err != nil {
+		if err == database.ErrTwoFactorEnabled {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to set up two-factor authentication")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, enrollment)
+}
+
+// EnableTwoFactor confirms enrollment with a code from the authenticator app, turns 2FA on
+// and returns the recovery codes
+func EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Code == "" {
+		respondError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	user, ok := twoFactorAccount(w, r)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		respondError(w, http.StatusConflict, database.ErrTwoFactorEnabled.Error())
+		return
+	}
+
+	codes, ok := confirmEnrollment(w, user.ID, req.Code)
+	if !ok {
+		return
+	}
+
+	auditOwnAccount(r, user, models.AuditEnableTwoFactor)
+	respondJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns 2FA off for the logged-in staff account. It needs the password and
+// a current code, and is refused while the account's role requires 2FA.
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.DisableTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Password == "" || (req.Code == "" && req.RecoveryCode == "") {
+		respondError(w, http.StatusBadRequest, "Password and code are required")
+		return
+	}
+
+	user, ok := twoFactorAccount(w, r)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		respondError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	required, err := database.TwoFactorRequired(callerSchool(r), user.Role)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to check two-factor authentication")
+		return
+	}
+	if required {
+		respondError(w, http.StatusForbidden, "Two-factor authentication is required for your role")
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		respondError(w, http.StatusBadRequest, "Password is incorrect")
+		return
+	}
+
+	if !verifySecondFactor(w, user.ID, req.Code, req.RecoveryCode) {
+		return
+	}
+
+	if err := database.DisableTOTP(user.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	auditOwnAccount(r, user, models.AuditDisableTwoFactor)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Autentikasi dua langkah dinonaktifkan"})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the logged-in staff account,
+// confirmed with a current code
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		respondError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	user, ok := twoFactorAccount(w, r)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		respondError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	if !verifySecondFactor(w, user.ID, req.Code, req.RecoveryCode) {
+		return
+	}
+
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+	if err := database.ReplaceRecoveryCodes(user.ID, codes); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+
+	// The codes themselves never go into the audit trail
+	auditOwnAccount(r, user, models.AuditRegenerateCodes)
+	respondJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// GetTwoFactorPolicy returns the staff roles of the school that must use 2FA (super admin only)
+func GetTwoFactorPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	roles, err := database.GetTwoFactorRoles(callerSchool(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch two-factor policy")
+		return
+	}
+
+	if roles == nil {
+		roles = []models.UserRole{}
+	}
+
+	respondJSON(w, http.StatusOK, models.TwoFactorPolicy{Roles: roles})
+}
+
+// SetTwoFactorPolicy replaces the list of staff roles of the school that must use 2FA
+// (super admin only). Staff of those roles without 2FA enroll at their next login.
+func SetTwoFactorPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.TwoFactorPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	for _, role := range req.Roles {
+		if !role.IsStaff() || role.FoundationWide() {
+			respondError(w, http.StatusBadRequest, "Invalid role")
+			return
+		}
+	}
+
+	before, err := database.GetTwoFactorRoles(callerSchool(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch two-factor policy")
+		return
+	}
+
+	if err := database.SetTwoFactorRoles(callerSchool(r), req.Roles); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update two-factor policy")
+		return
+	}
+
+	if req.Roles == nil {
+		req.Roles = []models.UserRole{}
+	}
+
+	audit(r, models.AuditUpdate, models.EntityTwoFactorPolicy, 0, models.TwoFactorPolicy{Roles: before}, req)
+	respondJSON(w, http.StatusOK, req)
+}
+
+// ResetStaffTwoFactor turns 2FA off for a staff member who lost both their device and their
+// recovery codes. If their role requires 2FA they enroll again at the next login.
+func ResetStaffTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ResetTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.UserID == 0 {
+		respondError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+
+	if _, ok := staffAccount(w, r, req.UserID); !ok {
+		return
+	}
+
+	if err := database.DisableTOTP(req.UserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	audit(r, models.AuditResetTwoFactor, models.EntityStaff, req.UserID, nil, nil)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication reset"})
+}
+
+// twoFactorAccount fetches the logged-in user's account, which must be a staff account
+func twoFactorAccount(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	if !callerRole(r).IsStaff() {
+		respondError(w, http.StatusForbidden, "Two-factor authentication is only available for staff accounts")
+		return nil, false
+	}
+
+	userID, _ := r.Context().Value("user_id").(int64)
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "User not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// startEnrollment stores a new pending secret for a user and returns what their
+// authenticator app needs
+func startEnrollment(user *models.User) (*models.TOTPEnrollment, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := database.SetPendingTOTPSecret(user.ID, secret); err != nil {
+		return nil, err
+	}
+	return &models.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(config.AppConfig.TOTPIssuer, user.Username, secret),
+	}, nil
+}
+
+// confirmEnrollment checks a code against the pending secret, turns 2FA on and returns the
+// new recovery codes. It answers the request itself when it fails.
+func confirmEnrollment(w http.ResponseWriter, userID int64, code string) ([]string, bool) {
+	secret, _, err := database.GetTOTPSecret(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify two-factor code")
+		return nil, false
+	}
+	if secret == "" {
+		respondError(w, http.StatusBadRequest, "Two-factor enrollment has not been started")
+		return nil, false
+	}
+
+	ok, err := checkTOTP(userID, secret, code)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify two-factor code")
+		return nil, false
+	}
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return nil, false
+	}
+
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return nil, false
+	}
+	if err := database.EnableTOTP(userID, codes); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to set up two-factor authentication")
+		return nil, false
+	}
+	return codes, true
+}
+
+// verifySecondFactor checks a TOTP or recovery code of an account with 2FA enabled and
+// answers the request itself when it is wrong
+func verifySecondFactor(w http.ResponseWriter, userID int64, code, recoveryCode string) bool {
+	ok, err := checkSecondFactor(userID, code, recoveryCode)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify two-factor code")
+		return false
+	}
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return false
+	}
+	return true
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery code, which is
+// consumed
+func checkSecondFactor(userID int64, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		return database.UseRecoveryCode(userID, normalizeRecoveryCode(recoveryCode))
+	}
+
+	secret, enabled, err := database.GetTOTPSecret(userID)
+	if err != nil || !enabled {
+		return false, err
+	}
+	return checkTOTP(userID, secret, code)
+}
+
+// checkTOTP validates a code and records its time step so it cannot be used again
+func checkTOTP(userID int64, secret, code string) (bool, error) {
+	step, ok := totp.Validate(secret, code, time.Now(), totpSkew)
+	if !ok {
+		return false, nil
+	}
+	return database.UseTOTPStep(userID, step)
+}
+
+// generateRecoveryCodes returns recoveryCodeCount codes formatted as xxxxx-xxxxx
+func generateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := randomString(recoveryCodeAlphabet, 10)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode accepts recovery codes typed with or without the dash and in any case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) == 10 && !strings.Contains(code, "-") {
+		code = code[:5] + "-" + code[5:]
+	}
+	return code
+}
+
+// generateChallengeToken signs a short-lived token proving the user's password was correct
+func generateChallengeToken(user *models.User) (string, error) {
+	claims := &tokens.Claims{
+		UserID: user.ID,
+		Role:   user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{tokens.AudienceTwoFactor},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.AppConfig.TwoFactorChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return tokens.Sign(claims)
+}
+
+// parseChallengeToken returns the user a challenge token was issued to
+func parseChallengeToken(tokenString string) (int64, error) {
+	claims, err := tokens.Parse(tokenString, tokens.AudienceTwoFactor)
+	if err != nil {
+		return 0, err
+	}
+	return claims.UserID, nil
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 
 	"komite-sekolah/config"
 	"komite-sekolah/database"
 	"komite-sekolah/handlers"
+	"komite-sekolah/messaging"
 	"komite-sekolah/middleware"
+	"komite-sekolah/models"
+	"komite-sekolah/tokens"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
@@ -19,34 +27,207 @@ func main() {
 	database.Init()
 	defer database.Close()
 
+	// Initialize the provider that delivers recovery codes
+	messaging.Init()
+
+	if len(os.Args) > 1 && os.Args[1] == "create-foundation-admin" {
+		createFoundationAdmin(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify-payment-chain" {
+		verifyPaymentChains(os.Args[2:])
+		return
+	}
+
+	// Load the token signing keys, creating the first one, and rotate them from now on
+	if err := tokens.Init(); err != nil {
+		log.Fatal("Failed to initialize signing keys:", err)
+	}
+
+	// Record the end of impersonations that ran out without being ended
+	go handlers.ExpireImpersonations()
+
 	// Public routes (no auth required)
 	http.HandleFunc("/", middleware.CORS(homeHandler))
 	http.HandleFunc("/health", middleware.CORS(healthHandler))
+	http.HandleFunc("/.well-known/jwks.json", middleware.CORS(tokens.JWKSHandler))
 
 	// Auth routes
 	http.HandleFunc("/api/auth/admin/login", middleware.CORS(handlers.LoginAdmin))
 	http.HandleFunc("/api/auth/student/login", middleware.CORS(handlers.LoginStudent))
-	http.HandleFunc("/api/auth/change-password", middleware.CORS(middleware.AuthMiddleware(handlers.ChangePassword)))
+	http.HandleFunc("/api/auth/parent/login", middleware.CORS(handlers.LoginParent))
+	http.HandleFunc("/api/auth/change-password", middleware.CORS(middleware.PasswordChangeAllowed(handlers.ChangePassword)))
+	http.HandleFunc("/api/auth/me", middleware.CORS(middleware.PasswordChangeAllowed(handlers.GetProfile)))
+	http.HandleFunc("/api/auth/refresh", middleware.CORS(handlers.RefreshToken))
+	http.HandleFunc("/api/auth/impersonation/end", middleware.CORS(middleware.ImpersonationAllowed(handlers.EndImpersonation)))
+	http.HandleFunc("/api/auth/admin/2fa/verify", middleware.CORS(handlers.VerifyTwoFactor))
+	http.HandleFunc("/api/auth/2fa/setup", middleware.CORS(middleware.AuthMiddleware(handlers.SetupTwoFactor)))
+	http.HandleFunc("/api/auth/2fa/enable", middleware.CORS(middleware.AuthMiddleware(handlers.EnableTwoFactor)))
+	http.HandleFunc("/api/auth/2fa/disable", middleware.CORS(middleware.AuthMiddleware(handlers.DisableTwoFactor)))
+	http.HandleFunc("/api/auth/2fa/recovery-codes", middleware.CORS(middleware.AuthMiddleware(handlers.RegenerateRecoveryCodes)))
+	http.HandleFunc("/api/auth/forgot-password", middleware.CORS(handlers.ForgotPassword))
+	http.HandleFunc("/api/auth/reset-password", middleware.CORS(handlers.ResetPasswordWithCode))
+	http.HandleFunc("/api/auth/logout", middleware.CORS(middleware.AuthMiddleware(handlers.Logout)))
+	http.HandleFunc("/api/auth/logout-all", middleware.CORS(middleware.AuthMiddleware(handlers.LogoutAll)))
 
-	// Admin routes (protected)
-	http.HandleFunc("/api/admin/students", middleware.CORS(middleware.AdminOnly(handleStudents)))
-	http.HandleFunc("/api/admin/students/reset-password", middleware.CORS(middleware.AdminOnly(handlers.ResetStudentPassword)))
+	// Admin routes (protected, each route requires a permission of the caller's role)
+	http.HandleFunc("/api/admin/students", middleware.CORS(middleware.ReadWrite(models.PermStudentsRead, models.PermStudentsWrite, handleStudents)))
+	http.HandleFunc("/api/admin/students/edit", middleware.CORS(middleware.RequirePermission(models.PermStudentsWrite, handlers.UpdateStudent)))
+	http.HandleFunc("/api/admin/students/status", middleware.CORS(middleware.RequirePermission(models.PermStudentsWrite, handlers.UpdateStudentStatus)))
+	http.HandleFunc("/api/admin/students/import", middleware.CORS(middleware.RequirePermission(models.PermStudentsWrite, handlers.ImportStudents)))
+	http.HandleFunc("/api/admin/students/reset-password", middleware.CORS(middleware.RequirePermission(models.PermStudentsWrite, handlers.ResetStudentPassword)))
+	http.HandleFunc("/api/admin/students/credentials", middleware.CORS(middleware.RequirePermission(models.PermStudentsWrite, handlers.GenerateCredentials)))
+	http.HandleFunc("/api/admin/parents", middleware.CORS(middleware.ReadWrite(models.PermStudentsRead, models.PermStudentsWrite, handleParents)))
+	http.HandleFunc("/api/admin/parents/link", middleware.CORS(middleware.RequirePermission(models.PermStudentsWrite, handlers.LinkParentStudent)))
 
 	// Payment routes (student - own payments)
 	http.HandleFunc("/api/payments/my-history", middleware.CORS(middleware.AuthMiddleware(handlers.GetMyPaymentHistory)))
 
-	// Payment routes (admin only)
-	http.HandleFunc("/api/admin/payments", middleware.CORS(middleware.AdminOnly(handleAdminPayments)))
-	http.HandleFunc("/api/admin/payments/by-user", middleware.CORS(middleware.AdminOnly(handlers.GetPaymentsByUser)))
-	http.HandleFunc("/api/admin/payments/by-nis", middleware.CORS(middleware.AdminOnly(handlers.GetPaymentsByNIS)))
-	http.HandleFunc("/api/admin/payments/delete", middleware.CORS(middleware.AdminOnly(handlers.DeletePayment)))
-	http.HandleFunc("/api/admin/payments/edit", middleware.CORS(middleware.AdminOnly(handlers.UpdatePayment)))
+	// Parent routes (own linked children only)
+	http.HandleFunc("/api/parent/children", middleware.CORS(middleware.AuthMiddleware(handlers.GetMyChildren)))
+	http.HandleFunc("/api/parent/children/history", middleware.CORS(middleware.AuthMiddleware(handlers.GetChildPaymentHistory)))
+
+	// Transparency report (any authenticated user, published periods only)
+	http.HandleFunc("/api/transparency", middleware.CORS(middleware.AuthMiddleware(handlers.GetTransparencyReport)))
+
+	// Payment routes (staff)
+	http.HandleFunc("/api/admin/payments", middleware.CORS(middleware.ReadWrite(models.PermPaymentsRead, models.PermPaymentsWrite, handleAdminPayments)))
+	http.HandleFunc("/api/admin/payments/by-user", middleware.CORS(middleware.RequirePermission(models.PermPaymentsRead, handlers.GetPaymentsByUser)))
+	http.HandleFunc("/api/admin/payments/by-nis", middleware.CORS(middleware.RequirePermission(models.PermPaymentsRead, handlers.GetPaymentsByNIS)))
+	http.HandleFunc("/api/admin/payments/delete", middleware.CORS(middleware.RequirePermission(models.PermPaymentsWrite, handlers.DeletePayment)))
+	http.HandleFunc("/api/admin/payments/edit", middleware.CORS(middleware.RequirePermission(models.PermPaymentsWrite, handlers.UpdatePayment)))
+	http.HandleFunc("/api/admin/payments/chain/verify", middleware.CORS(middleware.RequirePermission(models.PermPaymentsRead, handlers.VerifyPaymentChain)))
+	http.HandleFunc("/api/admin/payments/chain/anchors", middleware.CORS(middleware.RequirePermission(models.PermPaymentsRead, handlers.GetPaymentChainAnchors)))
+	http.HandleFunc("/api/admin/payments/chain/anchors/export", middleware.CORS(middleware.RequirePermission(models.PermPaymentsRead, handlers.ExportPaymentChainAnchors)))
+
+	// Class and billing routes (staff)
+	http.HandleFunc("/api/admin/academic-years", middleware.CORS(middleware.ReadWrite(models.PermStudentsRead, models.PermStudentsWrite, handleAcademicYears)))
+	http.HandleFunc("/api/admin/academic-years/activate", middleware.CORS(middleware.RequirePermission(models.PermStudentsWrite, handlers.ActivateAcademicYear)))
+	http.HandleFunc("/api/admin/classes", middleware.CORS(middleware.ReadWrite(models.PermStudentsRead, models.PermStudentsWrite, handleClasses)))
+	http.HandleFunc("/api/admin/enrollments", middleware.CORS(middleware.ReadWrite(models.PermStudentsRead, models.PermStudentsWrite, handleEnrollments)))
+	http.HandleFunc("/api/admin/fee-categories", middleware.CORS(middleware.ReadWrite(models.PermPaymentsRead, models.PermBillingWrite, handleFeeCategories)))
+	http.HandleFunc("/api/admin/bills/generate", middleware.CORS(middleware.RequirePermission(models.PermBillingWrite, handlers.GenerateBills)))
+	http.HandleFunc("/api/admin/bills/by-user", middleware.CORS(middleware.RequirePermission(models.PermPaymentsRead, handlers.GetBillsByUser)))
+
+	// Ledger routes (staff)
+	http.HandleFunc("/api/admin/ledger/accounts", middleware.CORS(middleware.ReadWrite(models.PermLedgerRead, models.PermLedgerWrite, handleAccounts)))
+	http.HandleFunc("/api/admin/ledger/journal", middleware.CORS(middleware.ReadWrite(models.PermLedgerRead, models.PermLedgerWrite, handleJournal)))
+	http.HandleFunc("/api/admin/ledger/refunds", middleware.CORS(middleware.RequirePermission(models.PermLedgerWrite, handlers.CreateRefund)))
+	http.HandleFunc("/api/admin/ledger/transfers", middleware.CORS(middleware.RequirePermission(models.PermLedgerWrite, handlers.CreateTransfer)))
+	http.HandleFunc("/api/admin/ledger/trial-balance", middleware.CORS(middleware.RequirePermission(models.PermLedgerRead, handlers.GetTrialBalance)))
+	http.HandleFunc("/api/admin/ledger/cash-book", middleware.CORS(middleware.RequirePermission(models.PermLedgerRead, handlers.GetCashBook)))
+
+	// Budget and expense routes (staff)
+	http.HandleFunc("/api/admin/budget-lines", middleware.CORS(middleware.ReadWrite(models.PermExpensesRead, models.PermExpensesWrite, handleBudgetLines)))
+	http.HandleFunc("/api/admin/expenses", middleware.CORS(middleware.ReadWrite(models.PermExpensesRead, models.PermExpensesWrite, handleExpenses)))
+	http.HandleFunc("/api/admin/expenses/detail", middleware.CORS(middleware.RequirePermission(models.PermExpensesRead, handlers.GetExpense)))
+	http.HandleFunc("/api/admin/expenses/decide", middleware.CORS(middleware.StaffOnly(handlers.DecideExpense)))
+	http.HandleFunc("/api/admin/expenses/disburse", middleware.CORS(middleware.RequirePermission(models.PermExpensesDisburse, handlers.DisburseExpense)))
+	http.HandleFunc("/api/admin/expenses/attachments", middleware.CORS(middleware.ReadWrite(models.PermExpensesRead, models.PermExpensesWrite, handleExpenseAttachments)))
+
+	// Staff account and role management
+	http.HandleFunc("/api/admin/users", middleware.CORS(middleware.RequirePermission(models.PermUsersManage, handleStaff)))
+	http.HandleFunc("/api/admin/users/status", middleware.CORS(middleware.RequirePermission(models.PermUsersManage, handlers.UpdateStaffStatus)))
+	http.HandleFunc("/api/admin/users/reset-password", middleware.CORS(middleware.RequirePermission(models.PermUsersManage, handlers.ResetStaffPassword)))
+	http.HandleFunc("/api/admin/roles", middleware.CORS(middleware.RequirePermission(models.PermUsersManage, handlers.GetRoles)))
+	http.HandleFunc("/api/admin/users/role", middleware.CORS(middleware.RequirePermission(models.PermUsersManage, handlers.AssignRole)))
+	http.HandleFunc("/api/admin/lockouts", middleware.CORS(middleware.RequirePermission(models.PermUsersManage, handlers.GetLockouts)))
+	http.HandleFunc("/api/admin/lockouts/clear", middleware.CORS(middleware.RequirePermission(models.PermUsersManage, handlers.ClearLockout)))
+	http.HandleFunc("/api/admin/users/reset-2fa", middleware.CORS(middleware.RequirePermission(models.PermUsersManage, handlers.ResetStaffTwoFactor)))
+	http.HandleFunc("/api/admin/impersonate", middleware.CORS(middleware.RequirePermission(models.PermImpersonate, handlers.StartImpersonation)))
+	http.HandleFunc("/api/admin/audit-logs", middleware.CORS(middleware.RequirePermission(models.PermAuditRead, handlers.GetAuditLogs)))
+	http.HandleFunc("/api/admin/audit-logs/export", middleware.CORS(middleware.RequirePermission(models.PermAuditRead, handlers.ExportAuditLogs)))
+	http.HandleFunc("/api/admin/api-keys", middleware.CORS(middleware.RequirePermission(models.PermSecurityManage, handleAPIKeys)))
+	http.HandleFunc("/api/admin/api-keys/revoke", middleware.CORS(middleware.RequirePermission(models.PermSecurityManage, handlers.RevokeAPIKey)))
+	http.HandleFunc("/api/admin/security/2fa-roles", middleware.CORS(middleware.RequirePermission(models.PermSecurityManage, handleTwoFactorPolicy)))
+
+	// Report routes (staff)
+	http.HandleFunc("/api/admin/reports/dashboard", middleware.CORS(middleware.RequirePermission(models.PermReportsRead, handlers.GetDashboard)))
+	http.HandleFunc("/api/admin/reports/aging", middleware.CORS(middleware.RequirePermission(models.PermReportsRead, handlers.GetAgingReport)))
+	http.HandleFunc("/api/admin/reports/budget", middleware.CORS(middleware.RequirePermission(models.PermReportsRead, handlers.GetBudgetReport)))
+	http.HandleFunc("/api/admin/reports/transparency/preview", middleware.CORS(middleware.RequirePermission(models.PermReportsRead, handlers.PreviewTransparencyReport)))
+	http.HandleFunc("/api/admin/reports/transparency/publish", middleware.CORS(middleware.RequirePermission(models.PermReportsPublish, handlers.PublishTransparencyReport)))
+
+	// Foundation routes (span every school)
+	http.HandleFunc("/api/foundation/schools", middleware.CORS(middleware.RequireFoundationPermission(models.PermSchoolsManage, handleSchools)))
+	http.HandleFunc("/api/foundation/reports/summary", middleware.CORS(middleware.RequireFoundationPermission(models.PermFoundationReports, handlers.GetFoundationReport)))
+	http.HandleFunc("/api/foundation/audit-logs", middleware.CORS(middleware.RequireFoundationPermission(models.PermFoundationAudit, handlers.GetFoundationAuditLogs)))
+	http.HandleFunc("/api/foundation/audit-logs/export", middleware.CORS(middleware.RequireFoundationPermission(models.PermFoundationAudit, handlers.ExportFoundationAuditLogs)))
 
 	port := ":" + config.AppConfig.ServerPort
 	log.Printf("Server starting on port %s", port)
 	log.Fatal(http.ListenAndServe(port, nil))
 }
 
+// createFoundationAdmin creates a foundation admin from the command line, reading the
+// initial password from stdin: komite-sekolah create-foundation-admin <username> <name>
+func createFoundationAdmin(args []string) {
+	if len(args) != 2 {
+		log.Fatal("Usage: create-foundation-admin <username> <name>")
+	}
+
+	fmt.Print("Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		log.Fatal("Failed to read password:", err)
+	}
+	password = strings.TrimSpace(password)
+	if len(password) < 6 {
+		log.Fatal("Password must be at least 6 characters")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Fatal("Failed to hash password:", err)
+	}
+
+	user, err := database.CreateFoundationAdmin(args[0], args[1], string(hashedPassword))
+	if err != nil {
+		log.Fatal("Failed to create foundation admin:", err)
+	}
+	log.Printf("Foundation admin %s created (id %d)", user.Username, user.ID)
+}
+
+// verifyPaymentChains checks the payment hash chain of one school, or of every school when no
+// code is given, and exits non-zero if any is broken:
+// komite-sekolah verify-payment-chain [school_code]
+func verifyPaymentChains(args []string) {
+	var schools []models.School
+	switch len(args) {
+	case 0:
+		all, err := database.GetAllSchools()
+		if err != nil {
+			log.Fatal("Failed to fetch schools:", err)
+		}
+		schools = all
+	case 1:
+		school, err := database.GetSchoolByCode(strings.ToUpper(args[0]))
+		if err != nil {
+			log.Fatal("School not found:", args[0])
+		}
+		schools = append(schools, *school)
+	default:
+		log.Fatal("Usage: verify-payment-chain [school_code]")
+	}
+
+	broken := false
+	for _, school := range schools {
+		result, err := database.VerifyPaymentChain(school.ID)
+		if err != nil {
+			log.Fatalf("Failed to verify payment chain of %s: %v", school.Code, err)
+		}
+		if result.Valid {
+			fmt.Printf("%s: OK, %d events, %d payments, last hash %s\n", school.Code, result.EventsChecked, result.PaymentsChecked, result.LastHash)
+			continue
+		}
+		broken = true
+		fmt.Printf("%s: BROKEN at event %d (payment %d): %s\n", school.Code, result.Broken.EventID, result.Broken.PaymentID, result.Broken.Reason)
+	}
+	if broken {
+		os.Exit(1)
+	}
+}
+
 func homeHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintf(w, `{"message": "Komite Sekolah API", "version": "1.0.0"}`)
@@ -70,6 +251,54 @@ func handleStudents(w http.ResponseWriter, r *http.Request) {
 	} 
 }
 
+// handleParents routes GET and POST for /api/admin/parents
+func handleParents(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetParents(w, r)
+	case http.MethodPost:
+		handlers.CreateParent(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleStaff routes GET and POST for /api/admin/users
+func handleStaff(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetStaff(w, r)
+	case http.MethodPost:
+		handlers.CreateStaff(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTwoFactorPolicy routes GET and PUT for /api/admin/security/2fa-roles
+func handleTwoFactorPolicy(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetTwoFactorPolicy(w, r)
+	case http.MethodPut:
+		handlers.SetTwoFactorPolicy(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPIKeys routes GET and POST for /api/admin/api-keys
+func handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetAPIKeys(w, r)
+	case http.MethodPost:
+		handlers.CreateAPIKey(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
 // handleAdminPayments routes GET and POST for /api/admin/payments
 func handleAdminPayments(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -81,3 +310,123 @@ func handleAdminPayments(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
 	} 
 }
+
+// handleAcademicYears routes GET and POST for /api/admin/academic-years
+func handleAcademicYears(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetAcademicYears(w, r)
+	case http.MethodPost:
+		handlers.CreateAcademicYear(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleEnrollments routes GET and POST for /api/admin/enrollments
+func handleEnrollments(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetEnrollments(w, r)
+	case http.MethodPost:
+		handlers.EnrollStudent(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleClasses routes GET and POST for /api/admin/classes
+func handleClasses(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetClasses(w, r)
+	case http.MethodPost:
+		handlers.CreateClass(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleFeeCategories routes GET and POST for /api/admin/fee-categories
+func handleFeeCategories(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetFeeCategories(w, r)
+	case http.MethodPost:
+		handlers.CreateFeeCategory(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAccounts routes GET and POST for /api/admin/ledger/accounts
+func handleAccounts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetAccounts(w, r)
+	case http.MethodPost:
+		handlers.CreateAccount(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleJournal routes GET (general ledger) and POST (manual entry) for /api/admin/ledger/journal
+func handleJournal(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetGeneralLedger(w, r)
+	case http.MethodPost:
+		handlers.CreateJournalEntry(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBudgetLines routes GET and POST for /api/admin/budget-lines
+func handleBudgetLines(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetBudgetLines(w, r)
+	case http.MethodPost:
+		handlers.CreateBudgetLine(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleExpenses routes GET and POST for /api/admin/expenses
+func handleExpenses(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetExpenses(w, r)
+	case http.MethodPost:
+		handlers.CreateExpense(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleExpenseAttachments routes GET (download) and POST (upload) for /api/admin/expenses/attachments
+func handleExpenseAttachments(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.DownloadExpenseAttachment(w, r)
+	case http.MethodPost:
+		handlers.UploadExpenseAttachment(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSchools routes GET and POST for /api/foundation/schools
+func handleSchools(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetSchools(w, r)
+	case http.MethodPost:
+		handlers.CreateSchool(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
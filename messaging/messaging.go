@@ -0,0 +1,130 @@
+// Package messaging delivers short text messages, such as one-time codes, to users by
+// email or WhatsApp. The provider is chosen with MESSAGING_PROVIDER:
+//   - log: messages are written to the server log (default, for development)
+//   - file: messages are appended as JSON lines to MESSAGING_OUTBOX, so flows can be
+//     tested offline by reading the file
+//   - webhook: messages are POSTed as JSON to MESSAGING_WEBHOOK_URL, where an email or
+//     WhatsApp gateway picks them up
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"komite-sekolah/config"
+)
+
+const (
+	ChannelEmail    = "email"
+	ChannelWhatsApp = "whatsapp"
+)
+
+type Message struct {
+	Channel string    `json:"channel"`
+	To      string    `json:"to"` // Email address or phone number, depending on Channel
+	Subject string    `json:"subject,omitempty"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// Provider sends a message through one delivery mechanism
+type Provider interface {
+	Send(msg Message) error
+}
+
+var provider Provider = LogProvider{}
+
+// Init selects the provider configured in config.AppConfig
+func Init() {
+	switch config.AppConfig.MessagingProvider {
+	case "file":
+		provider = &FileProvider{Path: config.AppConfig.MessagingOutbox}
+	case "webhook":
+		provider = &WebhookProvider{URL: config.AppConfig.MessagingWebhookURL, Client: &http.Client{Timeout: 10 * time.Second}}
+	case "log":
+		provider = LogProvider{}
+	default:
+		log.Printf("Unknown MESSAGING_PROVIDER %q, messages will be logged", config.AppConfig.MessagingProvider)
+		provider = LogProvider{}
+	}
+}
+
+// Send delivers a message through the configured provider
+func Send(msg Message) error {
+	if msg.Channel != ChannelEmail && msg.Channel != ChannelWhatsApp {
+		return fmt.Errorf("unsupported channel %q", msg.Channel)
+	}
+	if msg.To == "" {
+		return errors.New("message has no recipient")
+	}
+	msg.SentAt = time.Now()
+	return provider.Send(msg)
+}
+
+// LogProvider writes messages to the server log instead of delivering them
+type LogProvider struct{}
+
+func (LogProvider) Send(msg Message) error {
+	log.Printf("[messaging] %s to %s: %s %s", msg.Channel, msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileProvider appends messages as JSON lines to a local outbox file
+type FileProvider struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (p *FileProvider) Send(msg Message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, err := os.OpenFile(p.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// WebhookProvider POSTs messages as JSON to a gateway and expects a 2xx answer
+type WebhookProvider struct {
+	URL    string
+	Client *http.Client
+}
+
+func (p *WebhookProvider) Send(msg Message) error {
+	if p.URL == "" {
+		return errors.New("MESSAGING_WEBHOOK_URL is not set")
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.Client.Post(p.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("messaging webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -2,69 +2,269 @@ package middleware
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"komite-sekolah/config"
+	"komite-sekolah/database"
 	"komite-sekolah/models"
-
-	"github.com/golang-jwt/jwt/v5"
+	"komite-sekolah/tokens"
 )
 
-func getJWTSecret() []byte {
-	return []byte(config.AppConfig.JWTSecret)
+// ErrCodePasswordChangeRequired is returned in the "code" field when a token restricted to a
+// pending password change is used for anything else, so the frontend can send the user to
+// the change password screen
+const ErrCodePasswordChangeRequired = "PASSWORD_CHANGE_REQUIRED"
+
+// ErrCodeImpersonationReadOnly is returned in the "code" field when an impersonation token is
+// used for anything but reading
+const ErrCodeImpersonationReadOnly = "IMPERSONATION_READ_ONLY"
+
+// AuthMiddleware validates JWT token and adds user info to context. Tokens of users who still
+// have to change their password are refused, and impersonation tokens may only read.
+func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return authenticate("", next)
 }
 
-type Claims struct {
-	UserID int64           `json:"user_id"`
-	Role   models.UserRole `json:"role"`
-	jwt.RegisteredClaims
+// PasswordChangeAllowed is AuthMiddleware for the endpoints a user with a pending password
+// change may still call: changing the password and reading their own profile
+func PasswordChangeAllowed(next http.HandlerFunc) http.HandlerFunc {
+	return authenticate(models.TokenScopePasswordChange, next)
 }
 
-// AuthMiddleware validates JWT token and adds user info to context
-func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+// ImpersonationAllowed is AuthMiddleware for the one write an impersonation token may make:
+// ending the impersonation
+func ImpersonationAllowed(next http.HandlerFunc) http.HandlerFunc {
+	return authenticate(models.TokenScopeImpersonation, next)
+}
+
+// authenticate checks the token and its session. Tokens with a restricted scope are only
+// accepted where that scope is allowed, except that impersonation tokens may always read.
+func authenticate(allowedScope string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-		http.Error(w, `{"error": "Header Authorization diperlukan"}`, http.StatusUnauthorized)
-		return
-	}
+			http.Error(w, `{"error": "Header Authorization diperlukan"}`, http.StatusUnauthorized)
+			return
+		}
 
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	if tokenString == authHeader {
-		http.Error(w, `{"error": "Format Authorization tidak valid"}`, http.StatusUnauthorized)
-		return
-	}
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if tokenString == authHeader {
+			http.Error(w, `{"error": "Format Authorization tidak valid"}`, http.StatusUnauthorized)
+			return
+		}
 
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return getJWTSecret(), nil
-	})
+		claims, err := tokens.Parse(tokenString, "")
+		if err != nil {
+			http.Error(w, `{"error": "Token tidak valid"}`, http.StatusUnauthorized)
+			return
+		}
 
-	if err != nil || !token.Valid {
-		http.Error(w, `{"error": "Token tidak valid"}`, http.StatusUnauthorized)
+		// Tokens die with their session, so logout and password changes take effect at once
+		active, err := database.SessionActive(claims.SessionID, claims.UserID)
+		if err != nil || !active {
+			http.Error(w, `{"error": "Sesi telah berakhir, silakan masuk kembali"}`, http.StatusUnauthorized)
+			return
+		}
+
+		if claims.Scope == models.TokenScopePasswordChange && allowedScope != models.TokenScopePasswordChange {
+			http.Error(w, `{"error": "Kata sandi harus diganti terlebih dahulu", "code": "`+ErrCodePasswordChangeRequired+`"}`, http.StatusForbidden)
+			return
+		}
+
+		readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+		if claims.Scope == models.TokenScopeImpersonation && !readOnly && allowedScope != models.TokenScopeImpersonation {
+			http.Error(w, `{"error": "Sesi lihat sebagai siswa hanya dapat membaca data", "code": "`+ErrCodeImpersonationReadOnly+`"}`, http.StatusForbidden)
+			return
+		}
+
+		// A foundation admin belongs to no school and picks the one to work on per request
+		schoolID := claims.SchoolID
+		if claims.Role.FoundationWide() {
+			if v := r.URL.Query().Get("school_id"); v != "" {
+				id, err := strconv.ParseInt(v, 10, 64)
+				if err != nil || id <= 0 {
+					http.Error(w, `{"error": "school_id tidak valid"}`, http.StatusBadRequest)
+					return
+				}
+				if _, err := database.GetSchoolByID(id); err != nil {
+					http.Error(w, `{"error": "Sekolah tidak ditemukan"}`, http.StatusNotFound)
+					return
+				}
+				schoolID = id
+			}
 		}
 
 		// Add user info to context
 		ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
 		ctx = context.WithValue(ctx, "user_role", claims.Role)
+		ctx = context.WithValue(ctx, "school_id", schoolID)
+		ctx = context.WithValue(ctx, "session_id", claims.SessionID)
+		ctx = context.WithValue(ctx, "impersonated_by", claims.ImpersonatedBy)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// ClientIP returns the address the request came from. X-Forwarded-For is only trusted when
+// TRUST_PROXY_HEADERS says a reverse proxy sets it; otherwise clients could pick their own IP.
+// Clients can still send the header with made-up entries, which each proxy appends to, so
+// the address is read TRUSTED_PROXY_HOPS entries from the right, where our proxies wrote it.
+func ClientIP(r *http.Request) string {
+	if config.AppConfig.TrustProxyHeaders {
+		var hops []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(header, ",")...)
+		}
+		if len(hops) > 0 {
+			i := int64(len(hops)) - config.AppConfig.TrustedProxyHops
+			if i < 0 {
+				i = 0
+			}
+			return strings.TrimSpace(hops[i])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// userOrAPIKey is AuthMiddleware that also accepts an API key in place of the token
+func userOrAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	user := AuthMiddleware(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !strings.HasPrefix(key, models.APIKeyPrefix) {
+			user(w, r)
+			return
+		}
+
+		apiKey, err := database.GetAPIKeyByKey(key)
+		if err != nil {
+			http.Error(w, `{"error": "API key tidak valid"}`, http.StatusUnauthorized)
+			return
+		}
+		if apiKey.RevokedAt != nil {
+			http.Error(w, `{"error": "API key telah dicabut"}`, http.StatusUnauthorized)
+			return
+		}
+		if !time.Now().Before(apiKey.ExpiresAt) {
+			http.Error(w, `{"error": "API key telah kedaluwarsa"}`, http.StatusUnauthorized)
+			return
+		}
+		// A key acts for the admin who created it, so it stops working with their account
+		creator, err := database.GetUserByID(apiKey.CreatedBy)
+		if err != nil || creator.Status != models.StatusActive {
+			http.Error(w, `{"error": "Pembuat API key tidak lagi aktif"}`, http.StatusUnauthorized)
+			return
+		}
+		ip := ClientIP(r)
+		if !apiKey.AllowsIP(ip) {
+			http.Error(w, `{"error": "API key tidak boleh digunakan dari alamat ini"}`, http.StatusForbidden)
+			return
+		}
+
+		// Every accepted use is recorded; a key whose use cannot be recorded is not used
+		if err := database.RecordAPIKeyUse(apiKey.ID, ip); err != nil {
+			http.Error(w, `{"error": "Gagal mencatat penggunaan API key"}`, http.StatusInternalServerError)
+			return
+		}
+
+		// Changes made with a key are attributed to the admin who created it, with the
+		// api_key role so the audit trail tells them apart
+		ctx := context.WithValue(r.Context(), "user_id", apiKey.CreatedBy)
+		ctx = context.WithValue(ctx, "user_role", models.RoleAPIKey)
+		ctx = context.WithValue(ctx, "school_id", apiKey.SchoolID)
+		ctx = context.WithValue(ctx, "session_id", int64(0))
+		ctx = context.WithValue(ctx, "impersonated_by", int64(0))
+		ctx = context.WithValue(ctx, "api_key", apiKey)
+		ctx = context.WithValue(ctx, "api_key_creator_role", creator.Role)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
-// AdminOnly middleware ensures only admin can access the route
-func AdminOnly(next http.HandlerFunc) http.HandlerFunc {
+// hasPermission reports whether the caller may use perm: an API key by the permissions it
+// was created with, as far as its creator's current role still grants them, a user by their
+// role
+func hasPermission(r *http.Request, perm models.Permission) bool {
+	if apiKey, ok := r.Context().Value("api_key").(*models.APIKey); ok {
+		creatorRole, _ := r.Context().Value("api_key_creator_role").(models.UserRole)
+		return apiKey.HasPermission(perm) && creatorRole.HasPermission(perm)
+	}
+	role, ok := r.Context().Value("user_role").(models.UserRole)
+	return ok && role.HasPermission(perm)
+}
+
+// requireSchool rejects requests that are not tied to a school, which only happens for a
+// foundation admin who did not pick one with ?school_id=
+func requireSchool(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if schoolID, _ := r.Context().Value("school_id").(int64); schoolID == 0 {
+			http.Error(w, `{"error": "Sekolah belum dipilih: tambahkan parameter school_id"}`, http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequirePermission middleware ensures the caller's role, or the API key it authenticated
+// with, grants perm and the request is tied to a school
+func RequirePermission(perm models.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return userOrAPIKey(func(w http.ResponseWriter, r *http.Request) {
+		if !hasPermission(r, perm) {
+			http.Error(w, `{"error": "Akses ditolak: izin `+string(perm)+` diperlukan"}`, http.StatusForbidden)
+			return
+		}
+		requireSchool(next)(w, r)
+	})
+}
+
+// RequireFoundationPermission middleware ensures the caller's role grants perm, for routes
+// that span every school
+func RequireFoundationPermission(perm models.Permission, next http.HandlerFunc) http.HandlerFunc {
 	return AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		role, ok := r.Context().Value("user_role").(models.UserRole)
-		if !ok || role != models.RoleAdmin {
-			http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		if !ok || !role.HasPermission(perm) || !role.FoundationWide() {
+			http.Error(w, `{"error": "Akses ditolak: izin `+string(perm)+` diperlukan"}`, http.StatusForbidden)
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// ReadWrite middleware requires readPerm for GET requests and writePerm for every other
+// method, for routes that list and create on the same path
+func ReadWrite(readPerm, writePerm models.Permission, next http.HandlerFunc) http.HandlerFunc {
+	read := RequirePermission(readPerm, next)
+	write := RequirePermission(writePerm, next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			read(w, r)
+			return
+		}
+		write(w, r)
+	}
+}
+
+// StaffOnly middleware ensures the caller has a staff role. Handlers behind it check
+// finer permissions themselves.
+func StaffOnly(next http.HandlerFunc) http.HandlerFunc {
+	return AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		role, ok := r.Context().Value("user_role").(models.UserRole)
+		if !ok || !role.IsStaff() {
+			http.Error(w, `{"error": "Akses staf diperlukan"}`, http.StatusForbidden)
+			return
+		}
+		requireSchool(next)(w, r)
+	})
+}
+
 // CORS middleware for frontend requests
 func CORS(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"net"
+	"time"
+)
+
+// RoleAPIKey is the caller role of requests authenticated with an API key. It has no entry
+// in RolePermissions: what a key may do is the permissions it was created with.
+const RoleAPIKey UserRole = "api_key"
+
+// APIKeyPrefix starts every API key, so the auth middleware can tell keys from tokens
+const APIKeyPrefix = "ksk_"
+
+// APIKeyPermissions are the permissions an API key may be granted. Managing users and
+// security, impersonating, reading the audit trail and deciding expenses stay with people.
+var APIKeyPermissions = []Permission{
+	PermStudentsRead, PermStudentsWrite, PermPaymentsRead, PermPaymentsWrite, PermBillingWrite,
+	PermReportsRead, PermLedgerRead, PermLedgerWrite, PermExpensesRead, PermExpensesWrite,
+}
+
+// APIKey is a key a machine integration authenticates with. Only a hash of the key is
+// stored; Prefix is its first characters, to tell keys apart in the list.
+type APIKey struct {
+	ID          int64        `json:"id"`
+	SchoolID    int64        `json:"school_id"`
+	Name        string       `json:"name"`
+	Prefix      string       `json:"prefix"`
+	Permissions []Permission `json:"permissions"`
+	AllowedIPs  []string     `json:"allowed_ips"` // IPs or CIDR ranges; empty allows any
+	ExpiresAt   time.Time    `json:"expires_at"`
+	LastUsedAt  *time.Time   `json:"last_used_at,omitempty"`
+	LastUsedIP  string       `json:"last_used_ip,omitempty"`
+	UseCount    int64        `json:"use_count"`
+	CreatedBy   int64        `json:"created_by"`
+	CreatedAt   time.Time    `json:"created_at"`
+	RevokedAt   *time.Time   `json:"revoked_at,omitempty"`
+}
+
+// HasPermission reports whether the key was granted a permission
+func (k *APIKey) HasPermission(perm Permission) bool {
+	for _, p := range k.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsIP reports whether the key may be used from an address
+func (k *APIKey) AllowsIP(ip string) bool {
+	if len(k.AllowedIPs) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, allowed := range k.AllowedIPs {
+		if _, network, err := net.ParseCIDR(allowed); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKeyRequest creates a key valid through ExpiresOn (YYYY-MM-DD)
+type CreateAPIKeyRequest struct {
+	Name        string       `json:"name"`
+	Permissions []Permission `json:"permissions"`
+	AllowedIPs  []string     `json:"allowed_ips,omitempty"`
+	ExpiresOn   string       `json:"expires_on"`
+}
+
+// CreateAPIKeyResponse carries the key itself, which is only ever shown here
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type RevokeAPIKeyRequest struct {
+	ID int64 `json:"id"`
+}
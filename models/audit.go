@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audited actions. The entity type says what was changed, the action how.
+const (
+	AuditCreate           = "create"
+	AuditUpdate           = "update"
+	AuditDelete           = "delete"
+	AuditStatus           = "status"
+	AuditResetPassword    = "reset_password"
+	AuditAssignRole       = "assign_role"
+	AuditImport           = "import"
+	AuditLink             = "link"
+	AuditUnlink           = "unlink"
+	AuditActivate         = "activate"
+	AuditGenerate         = "generate"
+	AuditDecide           = "decide"
+	AuditDisburse         = "disburse"
+	AuditUpload           = "upload"
+	AuditPublish          = "publish"
+	AuditClearLockout     = "clear_lockout"
+	AuditResetTwoFactor   = "reset_2fa"
+	AuditImpersonateStart = "impersonate_start"
+	AuditImpersonateEnd   = "impersonate_end"
+	AuditRevoke           = "revoke"
+	AuditChangePassword   = "change_password"
+	AuditEnableTwoFactor  = "enable_2fa"
+	AuditDisableTwoFactor = "disable_2fa"
+	AuditRegenerateCodes  = "regenerate_recovery_codes"
+)
+
+// Audited entity types
+const (
+	EntityStudent            = "student"
+	EntityParent             = "parent"
+	EntityStaff              = "staff"
+	EntityPayment            = "payment"
+	EntityAcademicYear       = "academic_year"
+	EntityClass              = "class"
+	EntityEnrollment         = "enrollment"
+	EntityFeeCategory        = "fee_category"
+	EntityBill               = "bill"
+	EntityAccount            = "account"
+	EntityJournalEntry       = "journal_entry"
+	EntityRefund             = "refund"
+	EntityTransfer           = "transfer"
+	EntityBudgetLine         = "budget_line"
+	EntityExpense            = "expense"
+	EntityExpenseAttachment  = "expense_attachment"
+	EntityTransparencyReport = "transparency_report"
+	EntitySchool             = "school"
+	EntityLoginLockout       = "login_lockout"
+	EntityTwoFactorPolicy    = "two_factor_policy"
+	EntityAPIKey             = "api_key"
+)
+
+// AuditLog is one entry of the append-only audit trail. Before and After hold the entity as
+// JSON before and after the change; creates have no Before and deletes no After.
+type AuditLog struct {
+	ID         int64           `json:"id"`
+	SchoolID   *int64          `json:"school_id,omitempty"`
+	ActorID    int64           `json:"actor_id"`
+	ActorName  string          `json:"actor_name,omitempty"` // Populated when joining with users
+	ActorRole  UserRole        `json:"actor_role"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   *int64          `json:"entity_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows the audit trail; zero values mean any
+type AuditFilter struct {
+	ActorID    int64
+	Action     string
+	EntityType string
+	EntityID   int64
+	From       string // YYYY-MM-DD, inclusive
+	To         string // YYYY-MM-DD, inclusive
+	Page       int    // 1-based
+	PageSize   int    // 0 returns every matching entry, for exports
+}
+
+type AuditLogListResponse struct {
+	Data []AuditLog `json:"data"`
+	Meta ListMeta   `json:"meta"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// FeeCategory is a kind of charge students are billed for (e.g. iuran komite, study tour)
+type FeeCategory struct {
+	ID             int64     `json:"id"`
+	Code           string    `json:"code"`
+	Name           string    `json:"name"`
+	DefaultNominal int64     `json:"default_nominal"` // Default amount in Rupiah when generating bills
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type CreateFeeCategoryRequest struct {
+	Code           string `json:"code"`
+	Name           string `json:"name"`
+	DefaultNominal int64  `json:"default_nominal"`
+}
+
+// Bill is an amount a student is expected to pay for one fee category and period
+type Bill struct {
+	ID              int64     `json:"id"`
+	UserID          int64     `json:"user_id"`
+	FeeCategoryID   int64     `json:"fee_category_id"`
+	FeeCategoryName string    `json:"fee_category_name,omitempty"`
+	Periode         string    `json:"periode"`     // e.g. "2024-07" or "2024/2025"
+	Nominal         int64     `json:"nominal"`     // Amount in Rupiah
+	JatuhTempo      string    `json:"jatuh_tempo"` // Due date (YYYY-MM-DD)
+	Keterangan      string    `json:"keterangan,omitempty"`
+	Terbayar        int64     `json:"terbayar"` // Sum of payments linked to this bill
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type GenerateBillsRequest struct {
+	FeeCategoryID int64  `json:"fee_category_id"`
+	Periode       string `json:"periode"`
+	Nominal       int64  `json:"nominal,omitempty"` // Falls back to the category default
+	JatuhTempo    string `json:"jatuh_tempo"`
+	Keterangan    string `json:"keterangan,omitempty"`
+	ClassID       *int64 `json:"class_id,omitempty"` // Only bill students of this class
+}
+
+type GenerateBillsResponse struct {
+	Created int64 `json:"created"`
+	Skipped int64 `json:"skipped"` // Students that already had a bill for this category and period
+}
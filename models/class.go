@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// AcademicYear is a tahun ajaran, e.g. "2024/2025" running July to June
+type AcademicYear struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	StartDate string    `json:"start_date"` // YYYY-MM-DD
+	EndDate   string    `json:"end_date"`   // YYYY-MM-DD
+	IsActive  bool      `json:"is_active"`  // The year new classes and enrollments default to
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateAcademicYearRequest struct {
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	IsActive  bool   `json:"is_active"`
+}
+
+type Class struct {
+	ID               int64     `json:"id"`
+	Name             string    `json:"name"`        // e.g. "X IPA 1"
+	GradeLevel       int       `json:"grade_level"` // 10, 11, 12
+	AcademicYearID   *int64    `json:"academic_year_id,omitempty"`
+	AcademicYearName string    `json:"academic_year_name,omitempty"`
+	HomeroomTeacher  string    `json:"homeroom_teacher,omitempty"` // Wali kelas
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type CreateClassRequest struct {
+	Name            string `json:"name"`
+	GradeLevel      int    `json:"grade_level"`
+	AcademicYearID  *int64 `json:"academic_year_id,omitempty"` // Defaults to the active academic year
+	HomeroomTeacher string `json:"homeroom_teacher,omitempty"`
+}
+
+// Enrollment places a student in a class for part of an academic year. The current
+// enrollment has no end date; moving a student closes it and opens a new one.
+type Enrollment struct {
+	ID               int64     `json:"id"`
+	UserID           int64     `json:"user_id"`
+	ClassID          int64     `json:"class_id"`
+	ClassName        string    `json:"class_name"`
+	AcademicYearName string    `json:"academic_year_name,omitempty"`
+	StartDate        string    `json:"start_date"`
+	EndDate          *string   `json:"end_date,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type EnrollStudentRequest struct {
+	UserID    int64  `json:"user_id"`
+	ClassID   int64  `json:"class_id"`
+	StartDate string `json:"start_date,omitempty"` // Defaults to today
+}
@@ -0,0 +1,32 @@
+package models
+
+// GenerateCredentialsRequest gives new generated passwords to the students of a class or to
+// the listed students. OnlyPending skips students who already chose their own password.
+type GenerateCredentialsRequest struct {
+	ClassID     int64   `json:"class_id,omitempty"`
+	UserIDs     []int64 `json:"user_ids,omitempty"`
+	OnlyPending bool    `json:"only_pending,omitempty"`
+}
+
+// CredentialSlip is what a student needs for their first login. The password is the
+// plaintext generated password and is only ever returned in the response that set it.
+type CredentialSlip struct {
+	UserID     int64  `json:"user_id"`
+	Name       string `json:"name"`
+	NIS        string `json:"nis"`
+	ClassName  string `json:"class_name,omitempty"`
+	SchoolCode string `json:"school_code"`
+	Password   string `json:"password"`
+	LoginURL   string `json:"login_url"`
+}
+
+type CredentialSlipsResponse struct {
+	Slips []CredentialSlip `json:"slips"`
+}
+
+// NewStudentResponse is a created student, with the initial password when the server
+// generated it
+type NewStudentResponse struct {
+	User
+	GeneratedPassword string `json:"generated_password,omitempty"`
+}
@@ -0,0 +1,123 @@
+package models
+
+import "time"
+
+type ExpenseStatus string
+
+// An expense request moves through the approval chain treasurer -> chair -> principal,
+// and is disbursed once fully approved.
+const (
+	ExpensePendingTreasurer ExpenseStatus = "pending_treasurer"
+	ExpensePendingChair     ExpenseStatus = "pending_chair"
+	ExpensePendingPrincipal ExpenseStatus = "pending_principal"
+	ExpenseApproved         ExpenseStatus = "approved"
+	ExpenseRejected         ExpenseStatus = "rejected"
+	ExpenseDisbursed        ExpenseStatus = "disbursed"
+)
+
+// BudgetLine is a yearly budget allocation (pagu) for one kind of spending
+type BudgetLine struct {
+	ID          int64     `json:"id"`
+	TahunAjaran string    `json:"tahun_ajaran"` // e.g. "2024/2025"
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	Pagu        int64     `json:"pagu"`       // Budgeted amount in Rupiah
+	AccountID   int64     `json:"account_id"` // Expense account debited on disbursement
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CreateBudgetLineRequest struct {
+	TahunAjaran string `json:"tahun_ajaran"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Pagu        int64  `json:"pagu"`
+	AccountID   int64  `json:"account_id"`
+}
+
+// ExpenseRequest is a pengajuan dana against a budget line
+type ExpenseRequest struct {
+	ID             int64                `json:"id"`
+	BudgetLineID   int64                `json:"budget_line_id"`
+	BudgetLineName string               `json:"budget_line_name,omitempty"`
+	Judul          string               `json:"judul"`
+	Keterangan     string               `json:"keterangan,omitempty"`
+	Nominal        int64                `json:"nominal"`
+	Status         ExpenseStatus        `json:"status"`
+	RequestedBy    int64                `json:"requested_by"`
+	OverrideBy     *int64               `json:"override_by,omitempty"` // Set when approved over budget
+	Approvals      []ExpenseApproval    `json:"approvals,omitempty"`
+	Attachments    []ExpenseAttachment  `json:"attachments,omitempty"`
+	Disbursement   *ExpenseDisbursement `json:"disbursement,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+}
+
+type CreateExpenseRequest struct {
+	BudgetLineID int64  `json:"budget_line_id"`
+	Judul        string `json:"judul"`
+	Keterangan   string `json:"keterangan,omitempty"`
+	Nominal      int64  `json:"nominal"`
+}
+
+type ExpenseApproval struct {
+	ID         int64     `json:"id"`
+	Step       string    `json:"step"`     // "treasurer", "chair" or "principal"
+	Decision   string    `json:"decision"` // "approved" or "rejected"
+	ApproverID int64     `json:"approver_id"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ExpenseDecisionRequest struct {
+	ExpenseID      int64  `json:"expense_id"`
+	Approve        bool   `json:"approve"`
+	Note           string `json:"note,omitempty"`
+	OverrideBudget bool   `json:"override_budget,omitempty"` // Allow approving beyond the remaining budget
+}
+
+type ExpenseAttachment struct {
+	ID          int64     `json:"id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StoragePath string    `json:"-"`
+	UploadedBy  int64     `json:"uploaded_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ExpenseDisbursement struct {
+	ID             int64     `json:"id"`
+	Tanggal        string    `json:"tanggal"`
+	Nominal        int64     `json:"nominal"`
+	Metode         string    `json:"metode"`
+	JournalEntryID int64     `json:"journal_entry_id"`
+	DisbursedBy    int64     `json:"disbursed_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type DisburseExpenseRequest struct {
+	ExpenseID int64  `json:"expense_id"`
+	Tanggal   string `json:"tanggal"`
+	Metode    string `json:"metode"`
+}
+
+// BudgetRealization compares a budget line with what has been committed and spent
+type BudgetRealization struct {
+	BudgetLineID int64   `json:"budget_line_id"`
+	Code         string  `json:"code"`
+	Name         string  `json:"name"`
+	Pagu         int64   `json:"pagu"`
+	Komitmen     int64   `json:"komitmen"`   // Approved but not yet disbursed
+	Realisasi    int64   `json:"realisasi"`  // Disbursed
+	Sisa         int64   `json:"sisa"`       // Pagu - Komitmen - Realisasi
+	Persentase   float64 `json:"persentase"` // Realisasi / Pagu * 100
+}
+
+type BudgetReport struct {
+	TahunAjaran    string              `json:"tahun_ajaran"`
+	Lines          []BudgetRealization `json:"lines"`
+	TotalPagu      int64               `json:"total_pagu"`
+	TotalKomitmen  int64               `json:"total_komitmen"`
+	TotalRealisasi int64               `json:"total_realisasi"`
+	TotalSisa      int64               `json:"total_sisa"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// TokenScopeImpersonation marks a read-only access token a staff member uses to see the app
+// as a student sees it; the only write it allows is ending the impersonation
+const TokenScopeImpersonation = "impersonation"
+
+// Ways an impersonation ends
+const (
+	ImpersonationEnded   = "ended"
+	ImpersonationExpired = "expired"
+)
+
+type StartImpersonationRequest struct {
+	UserID int64 `json:"user_id"`
+}
+
+// Impersonation is one "view as student" session of a staff member
+type Impersonation struct {
+	ID        int64      `json:"id"`
+	SchoolID  int64      `json:"school_id"`
+	StaffID   int64      `json:"staff_id"`
+	StudentID int64      `json:"student_id"`
+	SessionID int64      `json:"session_id"`
+	StartedAt time.Time  `json:"started_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	EndReason string     `json:"end_reason,omitempty"`
+}
+
+// ImpersonationResponse carries the read-only token. There is no refresh token: when it
+// expires the staff member starts a new impersonation.
+type ImpersonationResponse struct {
+	Token           string    `json:"token"`
+	ExpiresIn       int64     `json:"expires_in"` // Seconds until token expires
+	ExpiresAt       time.Time `json:"expires_at"`
+	ImpersonationID int64     `json:"impersonation_id"`
+	User            User      `json:"user"`
+}
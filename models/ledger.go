@@ -0,0 +1,138 @@
+package models
+
+import "time"
+
+type AccountType string
+
+const (
+	AccountAsset     AccountType = "asset"
+	AccountLiability AccountType = "liability"
+	AccountEquity    AccountType = "equity"
+	AccountIncome    AccountType = "income"
+	AccountExpense   AccountType = "expense"
+)
+
+// Payment methods decide whether money lands in the cash or the bank account
+const (
+	MetodeTunai    = "tunai"    // Cash
+	MetodeTransfer = "transfer" // Bank transfer / virtual account
+)
+
+// Journal entry sources
+const (
+	SourcePayment         = "payment"
+	SourcePaymentReversal = "payment_reversal"
+	SourceOpening         = "opening"
+	SourceRefund          = "refund"
+	SourceTransfer        = "transfer"
+	SourceExpense         = "expense"
+	SourceManual          = "manual"
+)
+
+// Account is an entry in the chart of accounts
+type Account struct {
+	ID        int64       `json:"id"`
+	Code      string      `json:"code"`
+	Name      string      `json:"name"`
+	Type      AccountType `json:"type"`
+	IsCash    bool        `json:"is_cash"` // Cash and bank accounts get a cash book
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type CreateAccountRequest struct {
+	Code   string      `json:"code"`
+	Name   string      `json:"name"`
+	Type   AccountType `json:"type"`
+	IsCash bool        `json:"is_cash"`
+}
+
+type JournalLine struct {
+	ID          int64  `json:"id"`
+	AccountID   int64  `json:"account_id"`
+	AccountCode string `json:"account_code,omitempty"`
+	AccountName string `json:"account_name,omitempty"`
+	Debit       int64  `json:"debit"`
+	Credit      int64  `json:"credit"`
+	Memo        string `json:"memo,omitempty"`
+}
+
+type JournalEntry struct {
+	ID         int64         `json:"id"`
+	Tanggal    string        `json:"tanggal"`
+	Keterangan string        `json:"keterangan"`
+	SourceType string        `json:"source_type"`
+	SourceID   *int64        `json:"source_id,omitempty"`
+	Lines      []JournalLine `json:"lines"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+type CreateJournalEntryRequest struct {
+	Tanggal    string        `json:"tanggal"`
+	Keterangan string        `json:"keterangan"`
+	Lines      []JournalLine `json:"lines"`
+}
+
+type Refund struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	Tanggal    string    `json:"tanggal"`
+	Nominal    int64     `json:"nominal"`
+	Metode     string    `json:"metode"`
+	Keterangan string    `json:"keterangan,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type CreateRefundRequest struct {
+	UserID     int64  `json:"user_id"`
+	Tanggal    string `json:"tanggal"`
+	Nominal    int64  `json:"nominal"`
+	Metode     string `json:"metode"`
+	Keterangan string `json:"keterangan,omitempty"`
+}
+
+// CreateTransferRequest moves money between two cash accounts, e.g. depositing cash into the bank
+type CreateTransferRequest struct {
+	Tanggal       string `json:"tanggal"`
+	Nominal       int64  `json:"nominal"`
+	FromAccountID int64  `json:"from_account_id"`
+	ToAccountID   int64  `json:"to_account_id"`
+	Keterangan    string `json:"keterangan,omitempty"`
+}
+
+type TrialBalanceRow struct {
+	AccountID int64       `json:"account_id"`
+	Code      string      `json:"code"`
+	Name      string      `json:"name"`
+	Type      AccountType `json:"type"`
+	Debit     int64       `json:"debit"`
+	Credit    int64       `json:"credit"`
+	Saldo     int64       `json:"saldo"` // Debit - Credit
+}
+
+type TrialBalance struct {
+	AsOf        string            `json:"as_of"`
+	Rows        []TrialBalanceRow `json:"rows"`
+	TotalDebit  int64             `json:"total_debit"`
+	TotalCredit int64             `json:"total_credit"`
+	Balanced    bool              `json:"balanced"`
+}
+
+type CashBookRow struct {
+	EntryID    int64  `json:"entry_id"`
+	Tanggal    string `json:"tanggal"`
+	Keterangan string `json:"keterangan"`
+	SourceType string `json:"source_type"`
+	Masuk      int64  `json:"masuk"`  // Debit
+	Keluar     int64  `json:"keluar"` // Credit
+	Saldo      int64  `json:"saldo"`  // Running balance
+}
+
+// CashBook is the buku kas umum for one cash or bank account
+type CashBook struct {
+	Account       Account       `json:"account"`
+	DariTanggal   string        `json:"dari_tanggal"`
+	SampaiTanggal string        `json:"sampai_tanggal"`
+	SaldoAwal     int64         `json:"saldo_awal"`
+	Rows          []CashBookRow `json:"rows"`
+	SaldoAkhir    int64         `json:"saldo_akhir"`
+}
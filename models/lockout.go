@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Login throttle scopes: failures are counted per account and per client IP
+const (
+	LoginScopeAdmin   = "admin"
+	LoginScopeStudent = "student"
+	LoginScopeParent  = "parent"
+	LoginScopeIP      = "ip"
+)
+
+// LoginLockout is an account or client IP that is currently waiting after failed logins
+type LoginLockout struct {
+	ID            int64     `json:"id"`
+	Scope         string    `json:"scope"`
+	Subject       string    `json:"subject"` // Username, NIS, phone or email, or the IP address
+	Failures      int64     `json:"failures"`
+	Locked        bool      `json:"locked"` // False while only backing off between attempts
+	LastFailureAt time.Time `json:"last_failure_at"`
+	BlockedUntil  time.Time `json:"blocked_until"`
+}
+
+type ClearLockoutRequest struct {
+	ID int64 `json:"id"`
+}
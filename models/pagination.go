@@ -0,0 +1,19 @@
+package models
+
+// ListMeta describes one page of a paginated list. Total counts every row matching the
+// filters, not just the rows on this page.
+type ListMeta struct {
+	Total      int64 `json:"total"`
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// NewListMeta fills in TotalPages from the total row count
+func NewListMeta(total int64, page, pageSize int) ListMeta {
+	totalPages := 0
+	if pageSize > 0 {
+		totalPages = int((total + int64(pageSize) - 1) / int64(pageSize))
+	}
+	return ListMeta{Total: total, Page: page, PageSize: pageSize, TotalPages: totalPages}
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type CreateParentRequest struct {
+	Name     string `json:"name"`
+	Phone    string `json:"phone,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password"`
+}
+
+// LinkParentRequest links a parent account to one of their children
+type LinkParentRequest struct {
+	ParentID     int64  `json:"parent_id"`
+	StudentID    int64  `json:"student_id"`
+	Relationship string `json:"relationship,omitempty"` // e.g. "ayah", "ibu", "wali"
+}
+
+// ParentChild is a student linked to a parent account
+type ParentChild struct {
+	Student      User      `json:"student"`
+	Relationship string    `json:"relationship,omitempty"`
+	LinkedAt     time.Time `json:"linked_at"`
+}
+
+// ParentWithChildren is a parent account together with its linked students
+type ParentWithChildren struct {
+	Parent   User          `json:"parent"`
+	Children []ParentChild `json:"children"`
+}
+
+// ParentOverviewResponse lists the balance and payment history of every child of the
+// logged-in parent, one PaymentHistoryResponse per child
+type ParentOverviewResponse struct {
+	Parent   User                     `json:"parent"`
+	Children []PaymentHistoryResponse `json:"children"`
+}
@@ -3,12 +3,14 @@ package models
 import "time"
 
 type Payment struct {
-	ID         int64    `json:"id"`
-	UserID     int64    `json:"user_id"`
-	User       *User    `json:"user,omitempty"`       // Populated when joining with users table
-	Tanggal    string   `json:"tanggal"`              // Payment date (YYYY-MM-DD)
-	Nominal    int64    `json:"nominal"`              // Amount in Rupiah
-	Keterangan string   `json:"keterangan,omitempty"` // Description/notes
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	User       *User     `json:"user,omitempty"`       // Populated when joining with users table
+	Tanggal    string    `json:"tanggal"`              // Payment date (YYYY-MM-DD)
+	Nominal    int64     `json:"nominal"`              // Amount in Rupiah
+	Keterangan string    `json:"keterangan,omitempty"` // Description/notes
+	BillID     *int64    `json:"bill_id,omitempty"`    // Bill this payment settles, if any
+	Metode     string    `json:"metode"`               // "tunai" or "transfer"
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
@@ -18,6 +20,8 @@ type CreatePaymentRequest struct {
 	Tanggal    string `json:"tanggal"`
 	Nominal    int64  `json:"nominal"`
 	Keterangan string `json:"keterangan,omitempty"`
+	BillID     *int64 `json:"bill_id,omitempty"`
+	Metode     string `json:"metode,omitempty"` // Defaults to "tunai"
 }
 
 type UpdatePaymentRequest struct {
@@ -25,9 +29,10 @@ type UpdatePaymentRequest struct {
 	Tanggal    *string `json:"tanggal"`
 	Nominal    *int64  `json:"nominal"`
 	Keterangan *string `json:"keterangan,omitempty"`
+	BillID     *int64  `json:"bill_id,omitempty"`
+	Metode     *string `json:"metode,omitempty"`
 }
 
-
 type PaymentSummary struct {
 	TotalTagihan    int64 `json:"total_tagihan"`
 	TotalPembayaran int64 `json:"total_pembayaran"`
@@ -36,9 +41,41 @@ type PaymentSummary struct {
 }
 
 type PaymentHistoryResponse struct {
-	VirtualAccount string          `json:"virtual_account"`
-	Summary        PaymentSummary  `json:"summary"`
-	Payments       []Payment       `json:"payments"`
-	User           *User           `json:"user,omitempty"`
+	VirtualAccount string           `json:"virtual_account"`
+	Summary        PaymentSummary   `json:"summary"`
+	Payments       []Payment        `json:"payments"`
+	User           *User            `json:"user,omitempty"`
+	Meta           *PaymentListMeta `json:"meta,omitempty"` // Set when the payments were filtered or paged
+}
+
+// PaymentFilter narrows and orders a payment list. Zero values mean "no filter".
+type PaymentFilter struct {
+	From          string // Tanggal on or after (YYYY-MM-DD)
+	To            string // Tanggal on or before (YYYY-MM-DD)
+	MinNominal    int64
+	MaxNominal    int64
+	UserID        int64
+	ClassID       int64
+	FeeCategoryID int64
+	Metode        string
+	Search        string // Partial match on keterangan
+	Sort          string // "tanggal", "nominal" or "created_at", prefixed with "-" for descending
+	Cursor        string // next_cursor from the previous page
+	Limit         int
 }
 
+// PaymentListMeta carries totals over every payment matching the filter, plus the cursor
+// for the next page. NextCursor is empty on the last page.
+type PaymentListMeta struct {
+	Total         int64  `json:"total"`
+	TotalNominal  int64  `json:"total_nominal"`
+	TotalTunai    int64  `json:"total_tunai"`
+	TotalTransfer int64  `json:"total_transfer"`
+	Limit         int    `json:"limit"`
+	NextCursor    string `json:"next_cursor,omitempty"`
+}
+
+type PaymentListResponse struct {
+	Data []Payment       `json:"data"`
+	Meta PaymentListMeta `json:"meta"`
+}
@@ -0,0 +1,59 @@
+package models
+
+// Payment events recorded in the hash chain
+const (
+	PaymentEventCreate  = "create"
+	PaymentEventUpdate  = "update"
+	PaymentEventReverse = "reverse" // The payment was deleted
+)
+
+// Reasons a payment chain fails verification
+const (
+	ChainHashMismatch     = "hash_mismatch"      // An event's contents no longer match its hash
+	ChainPrevHashMismatch = "prev_hash_mismatch" // An event was removed, inserted or reordered
+	ChainPaymentChanged   = "payment_changed"    // A payment row differs from its last event
+	ChainPaymentMissing   = "payment_missing"    // A payment was deleted without a reverse event
+	ChainPaymentUnchained = "payment_unchained"  // A payment row has no event at all
+)
+
+// PaymentEvent is one link of a school's payment hash chain: a snapshot of the payment after
+// the event, hashed together with the previous event's hash
+type PaymentEvent struct {
+	ID         int64  `json:"id"`
+	SchoolID   int64  `json:"school_id"`
+	PaymentID  int64  `json:"payment_id"`
+	Event      string `json:"event"`
+	UserID     int64  `json:"user_id"`
+	Tanggal    string `json:"tanggal"`
+	Nominal    int64  `json:"nominal"`
+	Keterangan string `json:"keterangan"`
+	BillID     *int64 `json:"bill_id"`
+	Metode     string `json:"metode"`
+	RecordedAt string `json:"recorded_at"` // YYYY-MM-DD HH:MM:SS, server time
+	PrevHash   string `json:"prev_hash"`
+	Hash       string `json:"hash"`
+}
+
+// PaymentChainBreak is the first link of the chain that failed verification
+type PaymentChainBreak struct {
+	EventID   int64  `json:"event_id,omitempty"`
+	PaymentID int64  `json:"payment_id"`
+	Reason    string `json:"reason"`
+}
+
+type PaymentChainVerification struct {
+	Valid           bool               `json:"valid"`
+	EventsChecked   int                `json:"events_checked"`
+	PaymentsChecked int                `json:"payments_checked"`
+	LastHash        string             `json:"last_hash,omitempty"`
+	Broken          *PaymentChainBreak `json:"broken,omitempty"`
+}
+
+// PaymentChainAnchor is the chain hash at the end of a day. Printed in meeting minutes, it
+// lets the chain later be checked against a copy nobody could edit.
+type PaymentChainAnchor struct {
+	Tanggal     string `json:"tanggal"`
+	EventCount  int    `json:"event_count"` // Events recorded that day
+	LastEventID int64  `json:"last_event_id"`
+	Hash        string `json:"hash"`
+}
@@ -0,0 +1,134 @@
+package models
+
+// Staff roles. Admin rows are migrated to RoleSuperAdmin at startup; RoleAdmin keeps the
+// same permissions so tokens issued before the split work until they expire.
+const (
+	RoleSuperAdmin    UserRole = "super_admin"
+	RoleBendahara     UserRole = "bendahara"      // Treasurer
+	RoleKasir         UserRole = "kasir"          // Cashier, records payments only
+	RoleAuditor       UserRole = "auditor"        // Read-only access to everything
+	RoleWaliKelas     UserRole = "wali_kelas"     // Homeroom teacher, own class only
+	RoleKepalaSekolah UserRole = "kepala_sekolah" // Principal
+	RoleKetua         UserRole = "ketua"          // Committee chair
+
+	// Foundation admins oversee every school of the foundation and belong to none of them
+	RoleFoundationAdmin UserRole = "foundation_admin"
+)
+
+type Permission string
+
+const (
+	PermStudentsRead     Permission = "students.read"
+	PermStudentsWrite    Permission = "students.write" // Students, parents, classes and enrollment
+	PermPaymentsRead     Permission = "payments.read"
+	PermPaymentsWrite    Permission = "payments.write"
+	PermBillingWrite     Permission = "billing.write" // Fee categories and bill generation
+	PermReportsRead      Permission = "reports.read"
+	PermReportsPublish   Permission = "reports.publish"
+	PermLedgerRead       Permission = "ledger.read"
+	PermLedgerWrite      Permission = "ledger.write"
+	PermExpensesRead     Permission = "expenses.read"
+	PermExpensesWrite    Permission = "expenses.write" // Budget lines, expense requests and attachments
+	PermExpensesDisburse Permission = "expenses.disburse"
+	PermBudgetOverride   Permission = "budget.override" // Approve an expense beyond its remaining budget
+	PermUsersManage      Permission = "users.manage"
+	PermSecurityManage   Permission = "security.manage"   // Security policies such as required 2FA
+	PermAuditRead        Permission = "audit.read"        // Audit trail of admin changes
+	PermImpersonate      Permission = "users.impersonate" // View the app as a student, read-only
+
+	// One permission per step of the expense approval chain
+	PermApproveTreasurer Permission = "expenses.approve.treasurer"
+	PermApproveChair     Permission = "expenses.approve.chair"
+	PermApprovePrincipal Permission = "expenses.approve.principal"
+
+	// Foundation-wide permissions, not tied to one school
+	PermSchoolsManage     Permission = "schools.manage"
+	PermFoundationReports Permission = "foundation.reports"
+	PermFoundationAudit   Permission = "foundation.audit" // Audit entries that belong to no school
+)
+
+var readPermissions = []Permission{
+	PermStudentsRead, PermPaymentsRead, PermReportsRead, PermLedgerRead, PermExpensesRead,
+}
+
+// RolePermissions is the permission set of every staff role. Students and parents have
+// no staff permissions; their endpoints only check that they are logged in.
+var RolePermissions = map[UserRole][]Permission{
+	RoleSuperAdmin: allPermissions(),
+	RoleAdmin:      allPermissions(),
+	RoleBendahara: append([]Permission{
+		PermPaymentsWrite, PermBillingWrite, PermReportsPublish, PermLedgerWrite,
+		PermExpensesWrite, PermExpensesDisburse, PermApproveTreasurer,
+	}, readPermissions...),
+	RoleKasir:         {PermStudentsRead, PermPaymentsRead, PermPaymentsWrite},
+	RoleAuditor:       append([]Permission{PermAuditRead}, readPermissions...),
+	RoleWaliKelas:     {PermStudentsRead, PermPaymentsRead},
+	RoleKepalaSekolah: append([]Permission{PermApprovePrincipal, PermBudgetOverride, PermReportsPublish}, readPermissions...),
+	RoleKetua:         append([]Permission{PermApproveChair, PermBudgetOverride, PermReportsPublish}, readPermissions...),
+	// Foundation admins read any one school by passing ?school_id= and manage staff accounts
+	RoleFoundationAdmin: append([]Permission{PermSchoolsManage, PermFoundationReports, PermFoundationAudit, PermUsersManage}, readPermissions...),
+}
+
+// allPermissions leaves out PermAuditRead: the audit trail records the admins' own changes, so
+// only auditors read it
+func allPermissions() []Permission {
+	return []Permission{
+		PermStudentsRead, PermStudentsWrite, PermPaymentsRead, PermPaymentsWrite, PermBillingWrite,
+		PermReportsRead, PermReportsPublish, PermLedgerRead, PermLedgerWrite,
+		PermExpensesRead, PermExpensesWrite, PermExpensesDisburse, PermBudgetOverride, PermUsersManage,
+		PermSecurityManage, PermImpersonate, PermApproveTreasurer, PermApproveChair, PermApprovePrincipal,
+	}
+}
+
+// HasPermission reports whether a role grants a permission
+func (r UserRole) HasPermission(perm Permission) bool {
+	for _, p := range RolePermissions[r] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStaff reports whether a role logs in through the admin login
+func (r UserRole) IsStaff() bool {
+	_, ok := RolePermissions[r]
+	return ok
+}
+
+// FoundationWide reports whether a role spans every school instead of belonging to one
+func (r UserRole) FoundationWide() bool {
+	return r == RoleFoundationAdmin
+}
+
+// ClassScoped reports whether a role may only see students of its own class
+func (r UserRole) ClassScoped() bool {
+	return r == RoleWaliKelas
+}
+
+// ApprovalPermission returns the permission needed to decide the approval step an
+// expense in the given status is waiting for
+func ApprovalPermission(status ExpenseStatus) Permission {
+	switch status {
+	case ExpensePendingTreasurer:
+		return PermApproveTreasurer
+	case ExpensePendingChair:
+		return PermApproveChair
+	case ExpensePendingPrincipal:
+		return PermApprovePrincipal
+	}
+	return ""
+}
+
+// AssignRoleRequest changes a staff account's role. ClassID is required for wali_kelas.
+type AssignRoleRequest struct {
+	UserID  int64    `json:"user_id"`
+	Role    UserRole `json:"role"`
+	ClassID *int64   `json:"class_id,omitempty"`
+}
+
+// RoleInfo describes a role and its permissions for the role assignment UI
+type RoleInfo struct {
+	Role        UserRole     `json:"role"`
+	Permissions []Permission `json:"permissions"`
+}
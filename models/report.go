@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// CollectionPeriod compares money collected against what was billed in a date range
+type CollectionPeriod struct {
+	DariTanggal     string  `json:"dari_tanggal"`
+	SampaiTanggal   string  `json:"sampai_tanggal"`
+	Terkumpul       int64   `json:"terkumpul"` // Sum of payments in the period
+	Target          int64   `json:"target"`    // Sum of bills falling due in the period
+	JumlahTransaksi int     `json:"jumlah_transaksi"`
+	PersentaseTagih float64 `json:"persentase_tagih"` // Terkumpul / Target * 100
+}
+
+type CollectionBreakdown struct {
+	ID              int64   `json:"id"`
+	Name            string  `json:"name"`
+	Terkumpul       int64   `json:"terkumpul"`
+	Target          int64   `json:"target"`
+	PersentaseTagih float64 `json:"persentase_tagih"`
+}
+
+type ArrearsEntry struct {
+	UserID    int64  `json:"user_id"`
+	NIS       string `json:"nis"`
+	Name      string `json:"name"`
+	ClassName string `json:"class_name,omitempty"`
+	Tagihan   int64  `json:"tagihan"`   // Bills already due
+	Terbayar  int64  `json:"terbayar"`  // Payments linked to those bills, up to each bill's nominal
+	Tunggakan int64  `json:"tunggakan"` // Tagihan - Terbayar
+}
+
+type TrendPoint struct {
+	Periode         string `json:"periode"` // YYYY-MM-DD for daily, YYYY-MM for monthly
+	Terkumpul       int64  `json:"terkumpul"`
+	JumlahTransaksi int    `json:"jumlah_transaksi"`
+}
+
+type DashboardResponse struct {
+	HariIni      CollectionPeriod      `json:"hari_ini"`
+	BulanIni     CollectionPeriod      `json:"bulan_ini"`
+	TahunAjaran  CollectionPeriod      `json:"tahun_ajaran"`
+	PerKelas     []CollectionBreakdown `json:"per_kelas"`
+	PerKategori  []CollectionBreakdown `json:"per_kategori"`
+	TunggakanTop []ArrearsEntry        `json:"tunggakan_teratas"`
+	TrenHarian   []TrendPoint          `json:"tren_harian"`
+	TrenBulanan  []TrendPoint          `json:"tren_bulanan"`
+	GeneratedAt  time.Time             `json:"generated_at"`
+}
+
+// AgingBucket is an age range in days past due. MaxDays is nil for the open-ended last bucket.
+type AgingBucket struct {
+	Label   string `json:"label"`
+	MinDays int    `json:"min_days"`
+	MaxDays *int   `json:"max_days"`
+}
+
+// OpenBillBalance is the unpaid part of one bill as of a given date
+type OpenBillBalance struct {
+	BillID          int64
+	UserID          int64
+	NIS             string
+	StudentName     string
+	ClassID         int64
+	ClassName       string
+	FeeCategoryID   int64
+	FeeCategoryName string
+	Sisa            int64
+	DaysPastDue     int // Negative when the bill is not due yet
+}
+
+type AgingRow struct {
+	ID     int64   `json:"id"` // Class, fee category or student ID depending on grouping
+	Name   string  `json:"name"`
+	NIS    string  `json:"nis,omitempty"`
+	Jumlah []int64 `json:"jumlah"` // Overdue amount per bucket, same order as AgingReport.Buckets
+
+	BelumJatuhTempo int64 `json:"belum_jatuh_tempo"` // Open balance on bills not yet due
+	Total           int64 `json:"total"`
+}
+
+type AgingReport struct {
+	AsOf    string        `json:"as_of"`
+	GroupBy string        `json:"group_by"` // "class", "fee_category" or "student"
+	Buckets []AgingBucket `json:"buckets"`
+	Rows    []AgingRow    `json:"rows"`
+	Total   AgingRow      `json:"total"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// School is one tenant of the deployment. Every student, parent, staff account, payment,
+// bill and ledger entry belongs to exactly one school; foundation admins belong to none.
+type School struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"` // Short code students and parents give at login
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateSchoolRequest struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// SchoolCollection summarises one school's collections for the foundation report
+type SchoolCollection struct {
+	SchoolID        int64   `json:"school_id"`
+	Code            string  `json:"code"`
+	Name            string  `json:"name"`
+	SiswaAktif      int64   `json:"siswa_aktif"`
+	Terkumpul       int64   `json:"terkumpul"` // Sum of payments in the period
+	Target          int64   `json:"target"`    // Sum of bills falling due in the period
+	PersentaseTagih float64 `json:"persentase_tagih"`
+	Tunggakan       int64   `json:"tunggakan"` // Unpaid amount of bills due on or before the end of the period
+}
+
+// FoundationReport consolidates the collections of every school
+type FoundationReport struct {
+	DariTanggal   string             `json:"dari_tanggal"`
+	SampaiTanggal string             `json:"sampai_tanggal"`
+	Schools       []SchoolCollection `json:"schools"`
+	Total         SchoolCollection   `json:"total"`
+	GeneratedAt   time.Time          `json:"generated_at"`
+}
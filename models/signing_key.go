@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SigningKey is a key pair access tokens are signed with. A key signs new tokens between
+// NotBefore and NotAfter and is published for verification until ExpiresAt, so it is
+// announced before first use and outlives the last token it signed.
+type SigningKey struct {
+	KID        string
+	Algorithm  string // "EdDSA" or "RS256"
+	PrivateKey []byte // PKCS #8, DER encoded
+	PublicKey  []byte // PKIX, DER encoded
+	NotBefore  time.Time
+	NotAfter   time.Time
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// TransparencyLine is one aggregated row of the public report; it never identifies a student
+type TransparencyLine struct {
+	Name          string `json:"name"`
+	Jumlah        int64  `json:"jumlah"`
+	FeeCategoryID *int64 `json:"fee_category_id,omitempty"` // Income lines; nil for payments not linked to a bill
+}
+
+// TransparencyReport shows parents where committee money came from and went to in a period
+type TransparencyReport struct {
+	Periode          string             `json:"periode"`
+	DariTanggal      string             `json:"dari_tanggal"`
+	SampaiTanggal    string             `json:"sampai_tanggal"`
+	Pemasukan        []TransparencyLine `json:"pemasukan"` // Income per fee category
+	TotalPemasukan   int64              `json:"total_pemasukan"`
+	Pengembalian     int64              `json:"pengembalian"` // Refunds paid back to students
+	Pengeluaran      []TransparencyLine `json:"pengeluaran"`  // Disbursed expenses per budget line
+	TotalPengeluaran int64              `json:"total_pengeluaran"`
+	Selisih          int64              `json:"selisih"` // TotalPemasukan - Pengembalian - TotalPengeluaran
+	Published        bool               `json:"published"`
+	PublishedAt      *time.Time         `json:"published_at,omitempty"`
+}
+
+// PublishedReportSummary lists a frozen report without its figures
+type PublishedReportSummary struct {
+	Periode       string    `json:"periode"`
+	DariTanggal   string    `json:"dari_tanggal"`
+	SampaiTanggal string    `json:"sampai_tanggal"`
+	PublishedAt   time.Time `json:"published_at"`
+}
+
+type PublishTransparencyRequest struct {
+	Periode       string `json:"periode"` // e.g. "2024-07", "2024/2025 Semester 1"
+	DariTanggal   string `json:"dari_tanggal"`
+	SampaiTanggal string `json:"sampai_tanggal"`
+}
@@ -0,0 +1,50 @@
+package models
+
+// TwoFactorChallenge is the answer to a correct staff password when the account has 2FA or
+// its role requires it. The challenge token is exchanged for a session at the verify
+// endpoint together with a TOTP or recovery code.
+type TwoFactorChallenge struct {
+	TwoFactorRequired  bool            `json:"two_factor_required"`
+	ChallengeToken     string          `json:"challenge_token"`
+	ExpiresIn          int64           `json:"expires_in"`          // Seconds until the challenge token expires
+	EnrollmentRequired bool            `json:"enrollment_required"` // The role requires 2FA but the account has not set it up
+	Enrollment         *TOTPEnrollment `json:"enrollment,omitempty"`
+}
+
+// TOTPEnrollment is what an authenticator app needs; ProvisioningURI is usually shown as a QR code
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code,omitempty"`          // From the authenticator app
+	RecoveryCode   string `json:"recovery_code,omitempty"` // Instead of code when the device is lost
+}
+
+// TwoFactorCodeRequest confirms a 2FA change with a current TOTP or recovery code
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// RecoveryCodesResponse lists one-time recovery codes. They are only shown once.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorPolicy lists the staff roles of a school that must use 2FA
+type TwoFactorPolicy struct {
+	Roles []UserRole `json:"roles"`
+}
+
+type ResetTwoFactorRequest struct {
+	UserID int64 `json:"user_id"`
+}
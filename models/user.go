@@ -7,6 +7,18 @@ type UserRole string
 const (
 	RoleAdmin    UserRole = "admin"
 	RoleStudent  UserRole = "student"
+	RoleParent   UserRole = "parent" // Orang tua/wali, logs in by phone or email
+)
+
+type UserStatus string
+
+// Only active students can log in and receive new bills. Other states keep the account
+// and its payment history but take it out of day-to-day use.
+const (
+	StatusActive      UserStatus = "active"
+	StatusInactive    UserStatus = "inactive"
+	StatusTransferred UserStatus = "transferred" // Moved to another school
+	StatusGraduated   UserStatus = "graduated"
 )
 
 type User struct {
@@ -14,18 +26,41 @@ type User struct {
 	Username          string    `json:"username,omitempty"`  // For admin login
 	NIS               string    `json:"nis,omitempty"`       // For student login (Nomor Induk Siswa)
 	VirtualAccount    string    `json:"virtual_account,omitempty"`
+	Phone             string    `json:"phone,omitempty"`     // For parent login
+	Email             string    `json:"email,omitempty"`     // For parent login
 	Name              string    `json:"name"`
 	Password          string    `json:"-"`                   // Never expose in JSON
 	Role              UserRole  `json:"role"`
+	Status            UserStatus `json:"status"`
 	MustChangePassword bool     `json:"must_change_password"` // True for first login
+	TwoFactorEnabled  bool      `json:"two_factor_enabled"`
+	ClassID           *int64    `json:"class_id,omitempty"`
+	ClassName         string    `json:"class_name,omitempty"` // Populated when joining with classes table
+	SchoolID          *int64    `json:"school_id,omitempty"`  // Nil only for foundation admins
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
 }
 
+type UpdateStudentRequest struct {
+	UserID         int64   `json:"user_id"`
+	NIS            *string `json:"nis,omitempty"`
+	VirtualAccount *string `json:"virtual_account,omitempty"`
+	Name           *string `json:"name,omitempty"`
+	ClassID        *int64  `json:"class_id,omitempty"`
+}
+
+type UpdateStudentStatusRequest struct {
+	UserID int64      `json:"user_id"`
+	Status UserStatus `json:"status"`
+}
+
 type LoginRequest struct {
-	Username string `json:"username,omitempty"` // For admin
-	NIS      string `json:"nis,omitempty"`      // For student
-	Password string `json:"password"`
+	Username   string `json:"username,omitempty"`    // For admin
+	NIS        string `json:"nis,omitempty"`         // For student
+	Phone      string `json:"phone,omitempty"`       // For parent, either phone or email
+	Email      string `json:"email,omitempty"`       // For parent, either phone or email
+	SchoolCode string `json:"school_code,omitempty"` // For student and parent, optional while there is only one school
+	Password   string `json:"password"`
 }
 
 type ChangePasswordRequest struct {
@@ -33,10 +68,98 @@ type ChangePasswordRequest struct {
 	NewPassword string `json:"new_password"`
 }
 
+// ForgotPasswordRequest asks for a recovery code. Students give their NIS and the code goes to
+// their linked parents; parents give their phone or email.
+type ForgotPasswordRequest struct {
+	NIS        string `json:"nis,omitempty"`
+	Phone      string `json:"phone,omitempty"`
+	Email      string `json:"email,omitempty"`
+	SchoolCode string `json:"school_code,omitempty"`
+	Channel    string `json:"channel,omitempty"` // "email" or "whatsapp", defaults to what the account has
+}
+
+type ForgotPasswordResponse struct {
+	Message   string `json:"message"`
+	ExpiresIn int64  `json:"expires_in"` // Seconds the code stays valid
+}
+
+// ResetPasswordRequest exchanges a recovery code for a new password
+type ResetPasswordRequest struct {
+	NIS         string `json:"nis,omitempty"`
+	Phone       string `json:"phone,omitempty"`
+	Email       string `json:"email,omitempty"`
+	SchoolCode  string `json:"school_code,omitempty"`
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
+}
+
+// TokenScopePasswordChange marks an access token issued while the user still has to change
+// their password; it only works for changing the password and reading the profile
+const TokenScopePasswordChange = "password_change"
+
 type LoginResponse struct {
 	Token              string `json:"token"`
+	RefreshToken       string `json:"refresh_token"`
+	ExpiresIn          int64  `json:"expires_in"` // Seconds until token expires
 	User               User   `json:"user"`
 	MustChangePassword bool   `json:"must_change_password"`
+	RecoveryCodes      []string `json:"recovery_codes,omitempty"` // Only when 2FA was just set up at login
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+
+
+// StudentImportRow is one parsed row of a bulk student import
+type StudentImportRow struct {
+	Row               int      `json:"row"` // 1-based row number in the uploaded file
+	NIS               string   `json:"nis"`
+	Name              string   `json:"name"`
+	ClassName         string   `json:"class_name,omitempty"`
+	ClassID           *int64   `json:"class_id,omitempty"`
+	VirtualAccount    string   `json:"virtual_account"`
+	Password          string   `json:"-"`
+	GeneratedPassword string   `json:"generated_password,omitempty"` // Only returned once, after commit
+	Errors            []string `json:"errors,omitempty"`
 }
 
+type StudentImportResult struct {
+	Committed   bool               `json:"committed"`
+	TotalRows   int                `json:"total_rows"`
+	ValidRows   int                `json:"valid_rows"`
+	InvalidRows int                `json:"invalid_rows"`
+	Rows        []StudentImportRow `json:"rows"`
+}
 
+// StudentFilter narrows and orders the admin student list
+type StudentFilter struct {
+	Search             string     // Partial match on name, NIS or virtual account
+	ClassID            int64      // 0 means any class
+	Status             UserStatus // Empty means any status
+	MustChangePassword *bool
+	Sort               []string // Field names, prefixed with "-" for descending
+	Page               int      // 1-based
+	PageSize           int
+}
+
+type StudentListResponse struct {
+	Data []User   `json:"data"`
+	Meta ListMeta `json:"meta"`
+}
+
+// CreateStaffRequest creates an admin or staff account. ClassID is required for wali_kelas.
+type CreateStaffRequest struct {
+	Username string   `json:"username"`
+	Name     string   `json:"name"`
+	Role     UserRole `json:"role"`
+	ClassID  *int64   `json:"class_id,omitempty"`
+	Password string   `json:"password"` // Initial password, must be changed on first login
+}
+
+// UpdateStaffStatusRequest disables (inactive) or re-enables (active) a staff account
+type UpdateStaffStatusRequest struct {
+	UserID int64      `json:"user_id"`
+	Status UserStatus `json:"status"`
+}
@@ -0,0 +1,227 @@
+// Package spreadsheet reads tabular uploads (CSV and XLSX) into rows of strings.
+// Only the first worksheet of an XLSX workbook is read, and only cell values are
+// kept; formatting and formulas are ignored. Blank rows are returned as empty rows, so
+// row i of the result is row i+1 of the spreadsheet.
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported file format, expected .csv or .xlsx")
+	ErrTooManyColumns    = errors.New("worksheet has cells beyond column XFD")
+	ErrEntryTooLarge     = errors.New("workbook part is too large")
+	ErrTooManyRows       = errors.New("worksheet has rows beyond row 1048576")
+)
+
+const (
+	// maxColumns is the column limit of Excel (XFD); references beyond it are crafted
+	maxColumns = 16384
+	// maxRows is the row limit of Excel
+	maxRows = 1048576
+	// maxEntrySize bounds the decompressed size of a workbook part, so a small upload
+	// cannot expand into gigabytes
+	maxEntrySize = 64 << 20
+)
+
+// Read parses data as CSV or XLSX depending on the file name extension
+func Read(fileName string, data []byte) ([][]string, error) {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".csv":
+		return ReadCSV(bytes.NewReader(data))
+	case ".xlsx":
+		return ReadXLSX(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// ReadCSV parses comma or semicolon separated values. Spreadsheet programs with an
+// Indonesian locale export CSV with semicolons, so the separator is detected from the header.
+func ReadCSV(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	// The reader skips blank lines; they are put back so rows keep their line numbers
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		for len(rows) < line-1 {
+			rows = append(rows, nil)
+		}
+		rows = append(rows, record)
+	}
+}
+
+type xlsxSharedStrings struct {
+	Items []struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Ref   string `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline struct {
+				Text string `xml:"t"`
+			} `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadXLSX reads the cell values of the first worksheet of an XLSX workbook
+func ReadXLSX(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	var shared xlsxSharedStrings
+	if f := findZipFile(zr, "xl/sharedStrings.xml"); f != nil {
+		if err := decodeZipXML(f, &shared); err != nil {
+			return nil, err
+		}
+	}
+	strs := make([]string, len(shared.Items))
+	for i, si := range shared.Items {
+		if len(si.Runs) == 0 {
+			strs[i] = si.Text
+			continue
+		}
+		var sb strings.Builder
+		for _, run := range si.Runs {
+			sb.WriteString(run.Text)
+		}
+		strs[i] = sb.String()
+	}
+
+	sheet := findZipFile(zr, "xl/worksheets/sheet1.xml")
+	if sheet == nil {
+		return nil, ErrUnsupportedFormat
+	}
+	var ws xlsxWorksheet
+	if err := decodeZipXML(sheet, &ws); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(ws.Rows))
+	for _, row := range ws.Rows {
+		// Excel leaves blank rows out of the sheet; r says which row this is
+		if r, err := strconv.Atoi(row.Ref); err == nil && r > len(rows) {
+			if r > maxRows {
+				return nil, ErrTooManyRows
+			}
+			for len(rows) < r-1 {
+				rows = append(rows, nil)
+			}
+		}
+
+		var values []string
+		for i, cell := range row.Cells {
+			col := columnIndex(cell.Ref)
+			if col < 0 {
+				col = i
+			}
+			if col >= maxColumns {
+				return nil, ErrTooManyColumns
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				idx, err := strconv.Atoi(cell.Value)
+				if err == nil && idx >= 0 && idx < len(strs) {
+					values[col] = strs[idx]
+				}
+			case "inlineStr":
+				values[col] = cell.Inline.Text
+			default:
+				values[col] = cell.Value
+			}
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+// columnIndex converts the column letters of a cell reference ("C7") to a zero-based index.
+// Columns past maxColumns are reported as maxColumns.
+func columnIndex(ref string) int {
+	col := 0
+	n := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		if col <= maxColumns {
+			col = col*26 + int(ch-'A'+1)
+		}
+		n++
+	}
+	if col > maxColumns {
+		return maxColumns
+	}
+	if n == 0 {
+		return -1
+	}
+	return col - 1
+}
+
+func findZipFile(zr *zip.Reader, name string) *zip.File {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// decodeZipXML decodes one workbook part. The size in the zip header is checked first and
+// the read is capped as well, since the header can lie.
+func decodeZipXML(f *zip.File, v any) error {
+	if f.UncompressedSize64 > maxEntrySize {
+		return ErrEntryTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(io.LimitReader(rc, maxEntrySize)).Decode(v)
+}
@@ -0,0 +1,203 @@
+package tokens
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"time"
+
+	"komite-sekolah/config"
+	"komite-sekolah/database"
+	"komite-sekolah/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// publishLead is how long a new key is published in the JWKS before it signs anything,
+	// so services that cache the JWKS know it by the time they see tokens signed with it
+	publishLead = 24 * time.Hour
+
+	// rotationCheckInterval is how often keys are reloaded and rotated when due
+	rotationCheckInterval = 5 * time.Minute
+
+	reloadInterval = 10 * time.Second
+	rsaKeyBits     = 2048
+)
+
+type loadedKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+// Init makes sure a signing key exists, loads the keys and starts rotating them in the
+// background. It must be called after database.Init.
+func Init() error {
+	if err := rotate(); err != nil {
+		return err
+	}
+	go func() {
+		for range time.Tick(rotationCheckInterval) {
+			if err := rotate(); err != nil {
+				log.Printf("Failed to rotate signing keys: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// rotate creates the next signing key when the current one is about to stop signing (or
+// there is none), drops expired keys and reloads the rest
+func rotate() error {
+	unlock, err := database.LockSigningKeys(30 * time.Second)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, err := database.GetSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var last *models.SigningKey
+	for i := range stored {
+		if last == nil || stored[i].NotAfter.After(last.NotAfter) {
+			last = &stored[i]
+		}
+	}
+
+	switch {
+	case last == nil || !last.NotAfter.After(now):
+		// First start, or the server was down past the end of the last key: sign at once
+		err = createKey(now)
+	case last.NotAfter.Sub(now) < publishLead:
+		err = createKey(last.NotAfter)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := database.DeleteExpiredSigningKeys(); err != nil {
+		return err
+	}
+	return loadKeys()
+}
+
+// createKey generates a key of the configured algorithm that signs from notBefore for one
+// rotation period and stays published until every token it signed has expired
+func createKey(notBefore time.Time) error {
+	var private crypto.Signer
+	var err error
+	algorithm := config.AppConfig.JWTAlgorithm
+	switch algorithm {
+	case jwt.SigningMethodEdDSA.Alg():
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case jwt.SigningMethodRS256.Alg():
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	default:
+		return fmt.Errorf("unsupported JWT_ALGORITHM %q, expected EdDSA or RS256", algorithm)
+	}
+	if err != nil {
+		return err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return err
+	}
+
+	notAfter := notBefore.Add(config.AppConfig.JWTKeyRotation)
+	return database.CreateSigningKey(models.SigningKey{
+		KID:        keyID(publicDER),
+		Algorithm:  algorithm,
+		PrivateKey: privateDER,
+		PublicKey:  publicDER,
+		NotBefore:  notBefore,
+		NotAfter:   notAfter,
+		ExpiresAt:  notAfter.Add(maxTokenLifetime()),
+	})
+}
+
+// keyID derives a kid from the public key (its SHA-256, shortened), like a JWK thumbprint
+func keyID(publicDER []byte) string {
+	sum := sha256.Sum256(publicDER)
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+// maxTokenLifetime is the longest a signed token stays valid: an access token, a 2FA
+// challenge or a "view as student" token
+func maxTokenLifetime() time.Duration {
+	lifetime := config.AppConfig.AccessTokenTTL
+	for _, ttl := range []time.Duration{config.AppConfig.TwoFactorChallengeTTL, config.AppConfig.ImpersonationTTL} {
+		if ttl > lifetime {
+			lifetime = ttl
+		}
+	}
+	return lifetime
+}
+
+// loadKeys replaces the cached keys with the ones in the database
+func loadKeys() error {
+	stored, err := database.GetSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	loaded := make([]*loadedKey, 0, len(stored))
+	for _, k := range stored {
+		key, err := parseKey(k)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", k.KID, err)
+			continue
+		}
+		loaded = append(loaded, key)
+	}
+	keys.replace(loaded)
+	return nil
+}
+
+func parseKey(k models.SigningKey) (*loadedKey, error) {
+	parsed, err := x509.ParsePKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+
+	var method jwt.SigningMethod
+	switch k.Algorithm {
+	case jwt.SigningMethodEdDSA.Alg():
+		method = jwt.SigningMethodEdDSA
+	case jwt.SigningMethodRS256.Alg():
+		method = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", k.Algorithm)
+	}
+
+	return &loadedKey{
+		kid:       k.KID,
+		method:    method,
+		private:   private,
+		public:    private.Public(),
+		notBefore: k.NotBefore,
+		notAfter:  k.NotAfter,
+	}, nil
+}
@@ -0,0 +1,159 @@
+// Package tokens signs and verifies the JWTs issued by this service. Tokens are signed with
+// an asymmetric key (EdDSA or RS256) identified by the kid header. Keys live in the database
+// so every instance signs with the same ones, are rotated on a schedule, and their public
+// halves are published at /.well-known/jwks.json for other services to verify tokens with.
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"komite-sekolah/config"
+	"komite-sekolah/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AudienceTwoFactor marks the challenge tokens of the staff 2FA login step, which cannot be
+// used as access tokens
+const AudienceTwoFactor = "2fa-challenge"
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+type Claims struct {
+	UserID         int64           `json:"user_id"`
+	Role           models.UserRole `json:"role"`
+	SchoolID       int64           `json:"school_id,omitempty"` // Zero for foundation admins
+	SessionID      int64           `json:"sid"`
+	Scope          string          `json:"scope,omitempty"`           // models.TokenScopePasswordChange, models.TokenScopeImpersonation or empty for full access
+	ImpersonatedBy int64           `json:"impersonated_by,omitempty"` // Staff user viewing the app as this user
+	jwt.RegisteredClaims
+}
+
+// Sign signs claims with the current signing key, filling in the issuer
+func Sign(claims *Claims) (string, error) {
+	key, err := keys.signing()
+	if err != nil {
+		return "", err
+	}
+
+	claims.Issuer = config.AppConfig.JWTIssuer
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// Parse verifies a token and returns its claims. audience is the audience the token must be
+// issued for; access tokens have none, and tokens that carry one are refused as access tokens.
+func Parse(tokenString, audience string) (*Claims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(config.AppConfig.JWTIssuer),
+		jwt.WithExpirationRequired(),
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, verificationKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if audience == "" && len(claims.Audience) > 0 {
+		return nil, fmt.Errorf("token is meant for %v", claims.Audience)
+	}
+	return claims, nil
+}
+
+// verificationKey finds the public key a token names in its kid header. A kid this instance
+// has not seen yet may have been created by another instance, so the keys are reloaded once.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownKey
+	}
+
+	key, ok := keys.get(kid)
+	if !ok {
+		if err := keys.reloadIfStale(); err != nil {
+			return nil, err
+		}
+		if key, ok = keys.get(kid); !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("key %s is not a %s key", kid, token.Method.Alg())
+	}
+	return key.public, nil
+}
+
+// keySet caches the signing keys loaded from the database
+type keySet struct {
+	mu       sync.RWMutex
+	keys     []*loadedKey
+	byKID    map[string]*loadedKey
+	loadedAt time.Time
+}
+
+var keys = &keySet{byKID: map[string]*loadedKey{}}
+
+func (s *keySet) get(kid string) (*loadedKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.byKID[kid]
+	return key, ok
+}
+
+// signing returns the key to sign with now: the newest one whose signing period has started
+// and not ended
+func (s *keySet) signing() (*loadedKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var current *loadedKey
+	for _, k := range s.keys {
+		if !now.Before(k.notBefore) && now.Before(k.notAfter) {
+			current = k
+		}
+	}
+	if current == nil {
+		return nil, errors.New("no active signing key")
+	}
+	return current, nil
+}
+
+func (s *keySet) all() []*loadedKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys
+}
+
+func (s *keySet) replace(loaded []*loadedKey) {
+	byKID := make(map[string]*loadedKey, len(loaded))
+	for _, k := range loaded {
+		byKID[k.kid] = k
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = loaded
+	s.byKID = byKID
+	s.loadedAt = time.Now()
+}
+
+// reloadIfStale reloads the keys unless that happened in the last few seconds, so tokens
+// with made-up kids cannot make every request query the database
+func (s *keySet) reloadIfStale() error {
+	s.mu.RLock()
+	fresh := time.Since(s.loadedAt) < reloadInterval
+	s.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+	return loadKeys()
+}